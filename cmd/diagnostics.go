@@ -9,8 +9,9 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"os"
 	"os/exec"
-	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -27,21 +28,29 @@ import (
 
 	"github.com/pelican-dev/wings/config"
 	"github.com/pelican-dev/wings/environment"
+	"github.com/pelican-dev/wings/internal/database"
+	"github.com/pelican-dev/wings/internal/models"
 	"github.com/pelican-dev/wings/loggers/cli"
+	"github.com/pelican-dev/wings/server"
 	"github.com/pelican-dev/wings/system"
 )
 
 const (
 	DefaultHastebinUrl = "https://paste.pelistuff.com"
 	DefaultLogLines    = 200
+	DefaultTimeout     = 15
+	DefaultHistory     = 10
 )
 
 var diagnosticsArgs struct {
 	IncludeEndpoints   bool
 	IncludeLogs        bool
+	IncludeHistory     bool
 	ReviewBeforeUpload bool
 	HastebinURL        string
 	LogLines           int
+	Timeout            int
+	History            int
 }
 
 func newDiagnosticsCommand() *cobra.Command {
@@ -57,6 +66,8 @@ func newDiagnosticsCommand() *cobra.Command {
 
 	command.Flags().StringVar(&diagnosticsArgs.HastebinURL, "hastebin-url", DefaultHastebinUrl, "the url of the hastebin instance to use")
 	command.Flags().IntVar(&diagnosticsArgs.LogLines, "log-lines", DefaultLogLines, "the number of log lines to include in the report")
+	command.Flags().IntVar(&diagnosticsArgs.History, "history-events", DefaultHistory, "the number of recent crash and power events to include for each server")
+	command.Flags().IntVar(&diagnosticsArgs.Timeout, "timeout", DefaultTimeout, "the number of seconds to wait for each docker or system command before giving up")
 
 	return command
 }
@@ -67,6 +78,7 @@ func newDiagnosticsCommand() *cobra.Command {
 // - relevant parts of daemon configuration
 // - the docker debug output
 // - running docker containers
+// - recent server crash and power events
 // - logs
 func diagnosticsCmdRun(*cobra.Command, []string) {
 	questions := []*survey.Question{
@@ -78,6 +90,10 @@ func diagnosticsCmdRun(*cobra.Command, []string) {
 			Name:   "IncludeLogs",
 			Prompt: &survey.Confirm{Message: "Do you want to include the latest logs?", Default: true},
 		},
+		{
+			Name:   "IncludeHistory",
+			Prompt: &survey.Confirm{Message: "Do you want to include recent server crash and power events?", Default: true},
+		},
 		{
 			Name: "ReviewBeforeUpload",
 			Prompt: &survey.Confirm{
@@ -133,6 +149,13 @@ func diagnosticsCmdRun(*cobra.Command, []string) {
 	fmt.Fprintln(output, "            Username:", cfg.System.Username)
 	fmt.Fprintln(output, "         Server Time:", time.Now().Format(time.RFC1123Z))
 	fmt.Fprintln(output, "          Debug Mode:", cfg.Debug)
+	fmt.Fprintln(output, "         Openat Mode:", config.OpenatModeInUse(), "(configured:", cfg.System.OpenatMode+")")
+	for name, r := range cfg.Docker.Registries {
+		fmt.Fprintln(output, "            Registry:", name, "using", r.String())
+	}
+	for name := range cfg.Docker.ContainerEnv {
+		fmt.Fprintln(output, "       Container Env:", strings.ToUpper(name)+"={redacted}")
+	}
 
 	printHeader(output, "Docker: Info")
 	if dockerErr == nil {
@@ -150,6 +173,10 @@ func diagnosticsCmdRun(*cobra.Command, []string) {
 		}
 		fmt.Fprintln(output, "LoggingDriver:", dockerInfo.LoggingDriver)
 		fmt.Fprintln(output, " CgroupDriver:", dockerInfo.CgroupDriver)
+		fmt.Fprintln(output, "CgroupVersion:", dockerInfo.CgroupVersion)
+		for _, w := range system.CgroupWarnings(dockerInfo) {
+			fmt.Fprintln(output, "WARNING:", w)
+		}
 		if len(dockerInfo.Warnings) > 0 {
 			for _, w := range dockerInfo.Warnings {
 				fmt.Fprintln(output, w)
@@ -160,20 +187,32 @@ func diagnosticsCmdRun(*cobra.Command, []string) {
 	}
 
 	printHeader(output, "Docker: Running Containers")
-	c := exec.Command("docker", "ps")
-	if co, err := c.Output(); err == nil {
+	if co, err := runDiagnosticsCommand("docker", "ps"); err == nil {
 		output.Write(co)
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		fmt.Fprintln(output, "docker command timed out")
 	} else {
 		fmt.Fprint(output, "Couldn't list containers: ", err)
 	}
 
+	printHeader(output, "Recent Server Events")
+	if diagnosticsArgs.IncludeHistory && diagnosticsArgs.History > 0 {
+		if err := printServerHistory(output, cfg.System.GetDatabasePath(), diagnosticsArgs.History); err != nil {
+			fmt.Fprintln(output, "Couldn't read server events:", err)
+		}
+	} else {
+		fmt.Fprintln(output, "Server events redacted.")
+	}
+
 	printHeader(output, "Latest Wings Logs")
 	if diagnosticsArgs.IncludeLogs {
 		p := "/var/log/pelican/wings.log"
 		if cfg != nil {
-			p = path.Join(cfg.System.LogDirectory, "wings.log")
+			p = cfg.System.GetLogPath()
 		}
-		if c, err := exec.Command("tail", "-n", strconv.Itoa(diagnosticsArgs.LogLines), p).Output(); err != nil {
+		if c, err := runDiagnosticsCommand("tail", "-n", strconv.Itoa(diagnosticsArgs.LogLines), p); errors.Is(err, context.DeadlineExceeded) {
+			fmt.Fprintln(output, "tail command timed out")
+		} else if err != nil {
 			fmt.Fprintln(output, "No logs found or an error occurred.")
 		} else {
 			fmt.Fprintf(output, "%s\n", string(c))
@@ -185,12 +224,7 @@ func diagnosticsCmdRun(*cobra.Command, []string) {
 	if !diagnosticsArgs.IncludeEndpoints {
 		s := output.String()
 		output.Reset()
-		s = strings.ReplaceAll(s, cfg.PanelLocation, "{redacted}")
-		s = strings.ReplaceAll(s, cfg.Api.Host, "{redacted}")
-		s = strings.ReplaceAll(s, cfg.Api.Ssl.CertificateFile, "{redacted}")
-		s = strings.ReplaceAll(s, cfg.Api.Ssl.KeyFile, "{redacted}")
-		s = strings.ReplaceAll(s, cfg.System.Sftp.Address, "{redacted}")
-		output.WriteString(s)
+		output.WriteString(cfg.Redact(s))
 	}
 
 	fmt.Println("\n---------------  generated report  ---------------")
@@ -209,17 +243,112 @@ func diagnosticsCmdRun(*cobra.Command, []string) {
 	}
 }
 
+// printServerHistory writes the most recent crash and power events recorded for each
+// server to the report, along with a summary of how many times each server crashed
+// in the last hour.
+func printServerHistory(w io.Writer, path string, n int) error {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			fmt.Fprintln(w, "No server events have been recorded.")
+			return nil
+		}
+		return err
+	}
+	db, err := database.OpenReadOnly(path)
+	if err != nil {
+		return err
+	}
+	if sql, err := db.DB(); err == nil {
+		defer sql.Close()
+	}
+	if !db.Migrator().HasTable(&models.ServerHistory{}) {
+		fmt.Fprintln(w, "No server events have been recorded.")
+		return nil
+	}
+
+	var servers []string
+	if err := db.Model(&models.ServerHistory{}).Distinct().Order("server").Pluck("server", &servers).Error; err != nil {
+		return err
+	}
+	if len(servers) == 0 {
+		fmt.Fprintln(w, "No server events have been recorded.")
+		return nil
+	}
+
+	since := time.Now().Add(-time.Hour)
+	for _, s := range servers {
+		var events []models.ServerHistory
+		if err := db.Where("server = ?", s).Order("id DESC").Limit(n).Find(&events).Error; err != nil {
+			return err
+		}
+		var crashes, oom int
+		for _, e := range events {
+			if e.Event == server.ActivityServerCrashed && e.Timestamp.After(since) {
+				crashes++
+				if v, ok := e.Metadata["oomkilled"].(bool); ok && v {
+					oom++
+				}
+			}
+		}
+		fmt.Fprintf(w, "%s: crashed %d times (%d out of memory) in the last hour\n", s, crashes, oom)
+		for _, e := range events {
+			fmt.Fprintf(w, "  %s  %-22s", e.Timestamp.Local().Format(time.RFC3339), e.Event)
+			keys := make([]string, 0, len(e.Metadata))
+			for k := range e.Metadata {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Fprintf(w, " %s=%v", k, e.Metadata[k])
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	return nil
+}
+
+// diagnosticsTimeout returns the maximum amount of time any single external call
+// made while generating the report is allowed to take.
+func diagnosticsTimeout() time.Duration {
+	if diagnosticsArgs.Timeout <= 0 {
+		return DefaultTimeout * time.Second
+	}
+	return time.Duration(diagnosticsArgs.Timeout) * time.Second
+}
+
+// runDiagnosticsCommand executes the given command and returns its output. If the
+// command does not complete within the configured timeout it is killed and an error
+// wrapping context.DeadlineExceeded is returned, so that a wedged Docker daemon does
+// not hang the entire report.
+func runDiagnosticsCommand(name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout())
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("%s: %w", name, ctx.Err())
+	}
+	return out, err
+}
+
 func getDockerInfo() (types.Version, dockerSystem.Info, error) {
 	client, err := environment.Docker()
 	if err != nil {
 		return types.Version{}, dockerSystem.Info{}, err
 	}
-	dockerVersion, err := client.ServerVersion(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout())
+	defer cancel()
+	dockerVersion, err := client.ServerVersion(ctx)
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return types.Version{}, dockerSystem.Info{}, errors.New("docker command timed out")
+		}
 		return types.Version{}, dockerSystem.Info{}, err
 	}
-	dockerInfo, err := client.Info(context.Background())
+	dockerInfo, err := client.Info(ctx)
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return types.Version{}, dockerSystem.Info{}, errors.New("docker command timed out")
+		}
 		return types.Version{}, dockerSystem.Info{}, err
 	}
 	return dockerVersion, dockerInfo, nil
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pelican-dev/wings/config"
+	"github.com/pelican-dev/wings/server"
+)
+
+var drainArgs struct {
+	url         string
+	clientCert  string
+	clientKey   string
+	insecure    bool
+	stopServers bool
+	wait        bool
+	cancel      bool
+}
+
+func newDrainCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "drain",
+		Short: "Drain the running wings instance before performing node maintenance",
+		Long: "Places the running wings instance into drain mode, preventing servers from being started and new " +
+			"websocket connections from being accepted. Running servers can optionally be stopped gracefully, " +
+			"and the command can wait until the node reports that it is safe to reboot.",
+		Run: drainCmdRun,
+	}
+
+	command.Flags().StringVar(&drainArgs.url, "url", "", "the URL of the wings API, defaults to the local instance defined in the configuration file")
+	command.Flags().StringVar(&drainArgs.clientCert, "client-cert", "", "the client certificate to present when mutual TLS is required, defaults to api.ssl.client_cert")
+	command.Flags().StringVar(&drainArgs.clientKey, "client-key", "", "the client certificate key to present when mutual TLS is required, defaults to api.ssl.client_key")
+	command.Flags().BoolVar(&drainArgs.insecure, "insecure", false, "skip verification of the wings API certificate, this is always skipped for loopback addresses")
+	command.Flags().BoolVar(&drainArgs.stopServers, "stop-servers", false, "gracefully stop all running servers")
+	command.Flags().BoolVar(&drainArgs.wait, "wait", false, "wait until the node is safe to reboot before exiting")
+	command.Flags().BoolVar(&drainArgs.cancel, "cancel", false, "remove the node from drain mode")
+
+	return command
+}
+
+func drainCmdRun(_ *cobra.Command, _ []string) {
+	if err := config.FromFile(configPath); err != nil {
+		fmt.Printf("Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	api := config.Get().Api
+	url := drainArgs.url
+	if url == "" {
+		url = drainDefaultURL()
+	}
+
+	tlsConfig, err := drainTLSConfig(url)
+	if err != nil {
+		fmt.Printf("Failed to configure TLS: %v\n", err)
+		os.Exit(1)
+	}
+	if api.Ssl.RequireClientCertificate {
+		cert, err := drainClientCertificate()
+		if err != nil {
+			fmt.Printf("Failed to load client certificate: %v\n", err)
+			os.Exit(1)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	client := &http.Client{
+		Timeout:   time.Second * 30,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+
+	if drainArgs.cancel {
+		if _, err := drainRequest(client, http.MethodDelete, url, nil); err != nil {
+			fmt.Printf("Failed to remove node from drain mode: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Node is no longer draining.")
+		return
+	}
+
+	body, _ := json.Marshal(map[string]bool{"stop_servers": drainArgs.stopServers})
+	st, err := drainRequest(client, http.MethodPost, url, body)
+	if err != nil {
+		fmt.Printf("Failed to drain node: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Node is now draining.")
+
+	for drainArgs.wait && !st.SafeToReboot {
+		fmt.Printf("Waiting for %d running and %d busy server(s)...\n", st.RunningServers, st.BusyServers)
+		time.Sleep(time.Second * 5)
+		if st, err = drainRequest(client, http.MethodGet, url, nil); err != nil {
+			fmt.Printf("Failed to get drain status: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if st.SafeToReboot {
+		fmt.Println("Node is safe to reboot.")
+	} else {
+		fmt.Printf("Node has %d running and %d busy server(s) remaining.\n", st.RunningServers, st.BusyServers)
+	}
+}
+
+// Returns the URL of the local wings API based on the address the API is bound
+// to. The loopback address is used if the API is listening on all interfaces.
+func drainDefaultURL() string {
+	api := config.Get().Api
+	scheme := "http"
+	if api.Ssl.Enabled {
+		scheme = "https"
+	}
+	host := api.Host
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(api.Port))
+}
+
+// Returns the TLS configuration used to connect to the wings API. The certificate
+// for the node will not be valid for a loopback address, and the request never
+// leaves the machine in that case, so verification is only skipped for loopback
+// addresses or when --insecure is passed. Otherwise the certificate is verified
+// against the system roots and the certificate configured for the API, since the
+// node's authentication token is sent with every request.
+func drainTLSConfig(u string) (*tls.Config, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	host := parsed.Hostname()
+	if ip := net.ParseIP(host); drainArgs.insecure || host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if f := config.Get().Api.Ssl.CertificateFile; f != "" {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		pool.AppendCertsFromPEM(b)
+	}
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+// Loads the client certificate to present to the wings API when mutual TLS is
+// required for the control endpoints. The certificate passed on the command
+// line takes priority over the one defined in the configuration file.
+func drainClientCertificate() (tls.Certificate, error) {
+	api := config.Get().Api
+	certFile, keyFile := drainArgs.clientCert, drainArgs.clientKey
+	if certFile == "" {
+		certFile = api.Ssl.ClientCertificateFile
+	}
+	if keyFile == "" {
+		keyFile = api.Ssl.ClientKeyFile
+	}
+	if certFile == "" || keyFile == "" {
+		return tls.Certificate{}, errors.New("a client certificate and key must be provided using --client-cert and --client-key, or api.ssl.client_cert and api.ssl.client_key, when api.ssl.require_client_certificate is enabled")
+	}
+	return tls.LoadX509KeyPair(certFile, keyFile)
+}
+
+// Performs a request against the drain endpoint of the wings API and returns
+// the resulting drain status.
+func drainRequest(client *http.Client, method, url string, body []byte) (server.DrainStatus, error) {
+	var st server.DrainStatus
+
+	req, err := http.NewRequest(method, url+"/api/system/drain", bytes.NewReader(body))
+	if err != nil {
+		return st, err
+	}
+	req.Header.Set("Authorization", "Bearer "+config.Get().AuthenticationToken)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return st, err
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return st, err
+	}
+	if res.StatusCode >= 300 {
+		return st, fmt.Errorf("unexpected status code %d: %s", res.StatusCode, string(b))
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &st); err != nil {
+			return st, err
+		}
+	}
+	return st, nil
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+
+	"github.com/pelican-dev/wings/config"
+	"github.com/pelican-dev/wings/internal/ufs"
+)
+
+var fsBenchmarkArgs struct {
+	server string
+}
+
+func newFsBenchmarkCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "fs-benchmark",
+		Short: "Benchmark path resolution within a server's filesystem",
+		Long: "Times resolving paths, reading directories, and walking the entire directory tree of a server's " +
+			"filesystem using both the openat and openat2 modes. This can be used to determine which openat " +
+			"mode performs best on the node, and whether path resolution is the cause of slow file operations.",
+		Run: fsBenchmarkCmdRun,
+	}
+
+	command.Flags().StringVar(&fsBenchmarkArgs.server, "server", "", "the uuid of the server to benchmark")
+	_ = command.MarkFlagRequired("server")
+
+	return command
+}
+
+func fsBenchmarkCmdRun(_ *cobra.Command, _ []string) {
+	if err := config.FromFile(configPath); err != nil {
+		fmt.Printf("Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := uuid.Parse(fsBenchmarkArgs.server); err != nil {
+		fmt.Printf("Invalid server uuid provided: %v\n", err)
+		os.Exit(1)
+	}
+
+	root := filepath.Join(config.Get().System.Data, fsBenchmarkArgs.server)
+	if _, err := os.Stat(root); err != nil {
+		fmt.Printf("Failed to stat server data directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Benchmarking filesystem of server", fsBenchmarkArgs.server)
+	fmt.Println("Configured openat mode:", config.Get().System.OpenatMode)
+	for _, openat2 := range []bool{false, true} {
+		mode := "openat"
+		if openat2 {
+			mode = "openat2"
+			if fd, err := unix.Openat2(unix.AT_FDCWD, "/", &unix.OpenHow{}); err != nil {
+				fmt.Printf("\nSkipping openat2: not supported by the kernel (%v)\n", err)
+				continue
+			} else {
+				_ = unix.Close(fd)
+			}
+		}
+
+		printHeader(os.Stdout, "Mode: "+mode)
+		if err := fsBenchmark(root, openat2); err != nil {
+			fmt.Printf("Failed to benchmark using %s: %v\n", mode, err)
+		}
+	}
+}
+
+// fsBenchmark times walking the entire directory tree at root, reading every
+// directory, and resolving the path of every file found using the given mode.
+func fsBenchmark(root string, openat2 bool) error {
+	fs, err := ufs.NewUnixFS(root, openat2)
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	var dirs, files []string
+	start := time.Now()
+	dirfd, name, closeFd, err := fs.SafePath("/")
+	defer closeFd()
+	if err != nil {
+		return err
+	}
+	err = fs.WalkDirat(dirfd, name, func(_ int, _, relative string, d ufs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		p := path.Join("/", relative)
+		if d.IsDir() {
+			dirs = append(dirs, p)
+		} else {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	printFsBenchmarkResult("WalkDirat", len(dirs)+len(files), time.Since(start))
+
+	start = time.Now()
+	for _, p := range dirs {
+		if _, err := fs.ReadDir(p); err != nil {
+			return err
+		}
+	}
+	printFsBenchmarkResult("ReadDir", len(dirs), time.Since(start))
+
+	start = time.Now()
+	for _, p := range files {
+		_, _, closeFd, err := fs.SafePath(p)
+		closeFd()
+		if err != nil {
+			return err
+		}
+	}
+	printFsBenchmarkResult("SafePath", len(files), time.Since(start))
+
+	return nil
+}
+
+func printFsBenchmarkResult(op string, n int, d time.Duration) {
+	var rate float64
+	if d > 0 {
+		rate = float64(n) / d.Seconds()
+	}
+	fmt.Printf("%10s: %d entries in %s (%.0f/s)\n", op, n, d.Round(time.Microsecond), rate)
+}
@@ -30,7 +30,9 @@ import (
 	"github.com/pelican-dev/wings/environment"
 	"github.com/pelican-dev/wings/internal/cron"
 	"github.com/pelican-dev/wings/internal/database"
+	"github.com/pelican-dev/wings/internal/logforward"
 	"github.com/pelican-dev/wings/loggers/cli"
+	jsonlog "github.com/pelican-dev/wings/loggers/json"
 	"github.com/pelican-dev/wings/remote"
 	"github.com/pelican-dev/wings/router"
 	"github.com/pelican-dev/wings/server"
@@ -89,6 +91,8 @@ func init() {
 	rootCommand.AddCommand(configureCmd)
 	rootCommand.AddCommand(newDiagnosticsCommand())
 	rootCommand.AddCommand(newSelfupdateCommand())
+	rootCommand.AddCommand(newDrainCommand())
+	rootCommand.AddCommand(newFsBenchmarkCommand())
 }
 
 func isDockerSnap() bool {
@@ -149,6 +153,7 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 	pclient := remote.New(
 		config.Get().PanelLocation,
 		remote.WithCredentials(config.Get().AuthenticationTokenId, config.Get().AuthenticationToken),
+		remote.WithFallbacks(config.Get().PanelFallbackLocations...),
 		remote.WithHttpClient(&http.Client{
 			Timeout: time.Second * time.Duration(config.Get().RemoteQuery.Timeout),
 		}),
@@ -158,6 +163,20 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		log.WithField("error", err).Fatal("failed to initialize database")
 	}
 
+	if err := logforward.Initialize(cmd.Context()); err != nil {
+		log.WithField("error", err).Fatal("failed to initialize console forwarding")
+	}
+
+	// Determine which openat mode is being used before loading any servers so
+	// that any warning about falling back to openat is logged at startup.
+	log.WithField("openat_mode", config.OpenatModeInUse()).Debug("determined openat mode for server filesystems")
+
+	// Resolve the block device used for I/O limits once, rather than every time a
+	// container is created.
+	if device, err := environment.ResolveIoThrottleDevice(); err == nil {
+		log.WithField("device", device).Debug("determined block device for container I/O limits")
+	}
+
 	manager, err := server.NewManager(cmd.Context(), pclient)
 	if err != nil {
 		log.WithField("error", err).Fatal("failed to load server configurations")
@@ -167,6 +186,16 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		log.WithField("error", err).Fatal("failed to configure docker environment")
 	}
 
+	// Warn about any cgroup limitations on the node so that operators understand why
+	// certain resource limits are not being enforced for servers.
+	if _, info, err := system.GetDockerInfo(cmd.Context()); err != nil {
+		log.WithField("error", err).Warn("failed to check cgroup configuration of the node")
+	} else {
+		for _, w := range system.CgroupWarnings(info) {
+			log.WithField("cgroup_version", info.CgroupVersion).Warn(w)
+		}
+	}
+
 	if err := config.WriteToDisk(config.Get()); err != nil {
 		log.WithField("error", err).Fatal("failed to write configuration to disk")
 	}
@@ -336,12 +365,18 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		"host_port":    api.Port,
 	}).Info("configuring internal webserver")
 
+	tlsConfig, err := api.TLSConfig()
+	if err != nil {
+		log.WithField("error", err).Fatal("failed to configure TLS for internal webserver")
+		return
+	}
+
 	// Create a new HTTP server instance to handle inbound requests from the Panel
 	// and external clients.
 	s := &http.Server{
 		Addr:      api.Host + ":" + strconv.Itoa(api.Port),
 		Handler:   router.Configure(manager, pclient),
-		TLSConfig: config.DefaultTLSConfig,
+		TLSConfig: tlsConfig,
 	}
 
 	profile, _ := cmd.Flags().GetBool("pprof")
@@ -437,7 +472,12 @@ func initLogging() {
 	if config.Get().Debug {
 		log.SetLevel(log.DebugLevel)
 	}
-	log.SetHandler(multi.New(cli.Default, cli.New(w.File, false)))
+	switch config.Get().System.LogFormat {
+	case "json":
+		log.SetHandler(multi.New(jsonlog.New(os.Stderr), jsonlog.New(w.File)))
+	default:
+		log.SetHandler(multi.New(cli.Default, cli.New(w.File, false)))
+	}
 	log.WithField("path", p).Info("writing log files to disk")
 }
 
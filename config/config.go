@@ -3,13 +3,16 @@ package config
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"os"
 	"os/exec"
 	"os/user"
 	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -50,6 +53,59 @@ var DefaultTLSConfig = &tls.Config{
 	CurvePreferences:         []tls.CurveID{tls.X25519, tls.CurveP256},
 }
 
+// TLSConfig returns the TLS configuration to use for the internal webserver,
+// applying the minimum version and cipher suites configured for the API on top
+// of the DefaultTLSConfig values.
+func (c *ApiConfiguration) TLSConfig() (*tls.Config, error) {
+	cfg := DefaultTLSConfig.Clone()
+
+	switch c.Ssl.MinVersion {
+	case "", "1.2":
+		cfg.MinVersion = tls.VersionTLS12
+	case "1.3":
+		cfg.MinVersion = tls.VersionTLS13
+	default:
+		return nil, errors.Errorf("config: invalid api.ssl.min_version \"%s\": must be one of \"1.2\" or \"1.3\"", c.Ssl.MinVersion)
+	}
+
+	if len(c.Ssl.CipherSuites) > 0 {
+		suites := make(map[string]uint16)
+		for _, s := range tls.CipherSuites() {
+			suites[s.Name] = s.ID
+		}
+
+		cfg.CipherSuites = make([]uint16, 0, len(c.Ssl.CipherSuites))
+		for _, name := range c.Ssl.CipherSuites {
+			id, ok := suites[name]
+			if !ok {
+				return nil, errors.Errorf("config: invalid api.ssl.cipher_suites value \"%s\": unknown or insecure cipher suite", name)
+			}
+			cfg.CipherSuites = append(cfg.CipherSuites, id)
+		}
+	}
+
+	// Client certificates are verified if they are presented, but are not required
+	// during the handshake since some endpoints are accessed directly by users. The
+	// control endpoints then enforce their presence in the router middleware.
+	if c.Ssl.RequireClientCertificate {
+		if c.Ssl.ClientCAFile == "" {
+			return nil, errors.New("config: api.ssl.client_ca must be set when api.ssl.require_client_certificate is enabled")
+		}
+		b, err := os.ReadFile(c.Ssl.ClientCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "config: failed to read api.ssl.client_ca")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.New("config: api.ssl.client_ca does not contain any valid PEM encoded certificates")
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return cfg, nil
+}
+
 var (
 	mu            sync.RWMutex
 	_config       *Configuration
@@ -69,6 +125,13 @@ type SftpConfiguration struct {
 	Port int `default:"2022" json:"bind_port" yaml:"bind_port"`
 	// If set to true, no write actions will be allowed on the SFTP server.
 	ReadOnly bool `default:"false" yaml:"read_only"`
+	// AllowedCIDRs restricts the IP addresses that may connect to the SFTP server. If
+	// set, connections from addresses outside these ranges are closed before any
+	// authentication is attempted. Single IP addresses may also be provided.
+	AllowedCIDRs []string `json:"allowed_cidrs" yaml:"allowed_cidrs"`
+	// The maximum number of SFTP sessions that can be open for a single server at the
+	// same time. Set to 0 to disable this limit.
+	MaxSessionsPerServer int `default:"0" json:"max_sessions_per_server" yaml:"max_sessions_per_server"`
 }
 
 // ApiConfiguration defines the configuration for the internal API that is
@@ -85,6 +148,34 @@ type ApiConfiguration struct {
 		Enabled         bool   `json:"enabled" yaml:"enabled"`
 		CertificateFile string `json:"cert" yaml:"cert"`
 		KeyFile         string `json:"key" yaml:"key"`
+
+		// MinVersion is the minimum version of TLS that will be accepted by the
+		// webserver. Supported values are "1.2" and "1.3".
+		MinVersion string `default:"1.2" json:"min_version" yaml:"min_version"`
+
+		// CipherSuites restricts the cipher suites that may be negotiated by clients
+		// using TLS 1.2, using their standard names (for example,
+		// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"). If left empty a secure default set
+		// of cipher suites is used. Cipher suites cannot be configured for TLS 1.3.
+		CipherSuites []string `json:"cipher_suites" yaml:"cipher_suites"`
+
+		// RequireClientCertificate enables mutual TLS for the control API used by the
+		// Panel. When enabled, requests to those endpoints must present a client
+		// certificate signed by the certificate authority defined in ClientCAFile.
+		// Endpoints accessed by end users with a signed JWT, such as the websocket and
+		// file downloads, do not require a client certificate.
+		RequireClientCertificate bool `json:"require_client_certificate" yaml:"require_client_certificate"`
+
+		// ClientCAFile is the path to a PEM encoded certificate authority bundle used
+		// to verify client certificates when RequireClientCertificate is enabled.
+		ClientCAFile string `json:"client_ca" yaml:"client_ca"`
+
+		// ClientCertificateFile and ClientKeyFile are the client certificate and key
+		// presented by local commands that call the control API, such as "wings drain",
+		// when RequireClientCertificate is enabled. The certificate must be signed by
+		// the certificate authority defined in ClientCAFile.
+		ClientCertificateFile string `json:"client_cert" yaml:"client_cert"`
+		ClientKeyFile         string `json:"client_key" yaml:"client_key"`
 	}
 
 	// Determines if functionality for allowing remote download of files into server directories
@@ -92,11 +183,50 @@ type ApiConfiguration struct {
 	// servers.
 	DisableRemoteDownload bool `json:"-" yaml:"disable_remote_download"`
 
-	// The maximum size for files uploaded through the Panel in MiB.
+	// The maximum size for files uploaded through the Panel in MiB. Requests to the file
+	// write and upload endpoints with a larger body are rejected before it is read.
 	UploadLimit int64 `default:"100" json:"upload_limit" yaml:"upload_limit"`
 
 	// A list of IP address of proxies that may send a X-Forwarded-For header to set the true clients IP
 	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies"`
+
+	// TokenRateLimit configures the per-IP rate limiting applied to the publicly
+	// reachable endpoints that are authenticated using signed tokens, such as file
+	// downloads, uploads, and incoming server transfers.
+	TokenRateLimit TokenRateLimitConfiguration `json:"token_rate_limit" yaml:"token_rate_limit"`
+
+	// SSEKeepaliveSeconds is the number of seconds between the keepalive comments
+	// sent to clients connected to a server-sent events endpoint. This should be
+	// lower than the idle timeout of any proxy sitting in front of Wings.
+	SSEKeepaliveSeconds int `default:"15" json:"sse_keepalive_seconds" yaml:"sse_keepalive_seconds"`
+
+	// SSERetrySeconds is the number of seconds a client should wait before trying
+	// to reconnect to a server-sent events endpoint after being disconnected. If
+	// set to 0 no retry value is sent and the client default is used.
+	SSERetrySeconds int `default:"0" json:"sse_retry_seconds" yaml:"sse_retry_seconds"`
+}
+
+// TokenRateLimitConfiguration defines the per-IP rate limits for endpoints that
+// are authenticated using signed tokens.
+type TokenRateLimitConfiguration struct {
+	// Enabled determines if rate limiting is applied to these endpoints. This is
+	// disabled by default since all requests proxied through the Panel, or made by
+	// a single user uploading many files, will share the same IP address.
+	Enabled bool `default:"false" json:"enabled" yaml:"enabled"`
+
+	// RequestsPerMinute is the number of requests a single IP address may make
+	// to these endpoints each minute.
+	RequestsPerMinute int `default:"60" json:"requests_per_minute" yaml:"requests_per_minute"`
+
+	// Burst is the number of requests a single IP address may make at once before
+	// being limited.
+	Burst int `default:"20" json:"burst" yaml:"burst"`
+
+	// FailurePenalty is the number of requests that are counted against an IP
+	// address when a request is made with a missing or invalid token. This allows
+	// other requests to continue while quickly blocking any attempts to guess valid
+	// tokens.
+	FailurePenalty int `default:"5" json:"failure_penalty" yaml:"failure_penalty"`
 }
 
 // RemoteQueryConfiguration defines the configuration settings for remote requests
@@ -132,6 +262,19 @@ type SystemConfiguration struct {
 	// Directory where the server data is stored at.
 	Data string `default:"/var/lib/pelican/volumes" json:"-" yaml:"data"`
 
+	// AllowSymlinkedServerData controls whether a server's data directory within the
+	// data path may itself be a symlink, for example to place some servers on a
+	// different physical disk. When enabled the symlink is resolved once when the
+	// server is loaded and the resolved directory is used as the server's root for
+	// all file operations, container mounts and deletion. Symlinks within the server
+	// root are always restricted to the root regardless of this setting.
+	//
+	// Anyone able to create or change a symlink in the data directory can point a
+	// server at any directory on the host, so only enable this if the data directory
+	// is writable solely by trusted users. When disabled a server whose data
+	// directory is a symlink will fail to load.
+	AllowSymlinkedServerData bool `default:"true" json:"-" yaml:"allow_symlinked_server_data"`
+
 	// Directory where server archives for transferring will be stored.
 	ArchiveDirectory string `default:"/var/lib/pelican/archives" json:"-" yaml:"archive_directory"`
 
@@ -188,6 +331,12 @@ type SystemConfiguration struct {
 	// disk usage is not a concern.
 	DiskCheckInterval int64 `default:"150" yaml:"disk_check_interval"`
 
+	// WalkConcurrency is the number of directories that are read at the same time
+	// when walking a server's files to calculate its disk usage. Deep directory
+	// trees are considerably faster to walk in parallel on fast storage. If set
+	// to 0 the number of CPUs available on the system is used.
+	WalkConcurrency int `default:"0" yaml:"walk_concurrency"`
+
 	// ActivitySendInterval is the amount of time that should ellapse between aggregated server activity
 	// being sent to the Panel. By default this will send activity collected over the last minute. Keep
 	// in mind that only a fixed number of activity log entries, defined by ActivitySendCount, will be sent
@@ -197,6 +346,18 @@ type SystemConfiguration struct {
 	// ActivitySendCount is the number of activity events to send per batch.
 	ActivitySendCount int `default:"100" yaml:"activity_send_count"`
 
+	// ActivityCoalesceWindow is the number of seconds within which repeated activity events
+	// with the same event, server, user, and metadata are collapsed into a single event with
+	// a count before being sent to the Panel. SFTP events are always merged separately. Set
+	// to 0 to send every event individually.
+	ActivityCoalesceWindow int `default:"10" yaml:"activity_coalesce_window"`
+
+	// ContainerReconcileInterval is the number of seconds between each check for
+	// server containers that have drifted from the state tracked by Wings, such as
+	// containers left behind for servers that were deleted. The check also runs when
+	// Wings is started. Set to 0 to disable this check.
+	ContainerReconcileInterval int `default:"300" yaml:"container_reconcile_interval"`
+
 	// If set to true, file permissions for a server will be checked when the process is
 	// booted. This can cause boot delays if the server has a large amount of files. In most
 	// cases disabling this should not have any major impact unless external processes are
@@ -210,6 +371,47 @@ type SystemConfiguration struct {
 	// The number of lines to send when a server connects to the websocket.
 	WebsocketLogCount int `default:"150" yaml:"websocket_log_count"`
 
+	// ConsoleHistoryLines is the maximum number of lines of console history that can be
+	// requested for a server through the API. This cannot be set higher than 10,000.
+	ConsoleHistoryLines int `default:"100" yaml:"console_history_lines"`
+
+	// CommandRateLimit is the number of commands per second that can be sent to a single
+	// server through the API, with bursts of up to twice as many allowed. Set to 0 to not
+	// limit the rate at which commands can be sent.
+	CommandRateLimit int `default:"10" yaml:"command_rate_limit"`
+
+	// WebsocketMaxDroppedLines is the number of console lines that may be dropped for
+	// a websocket client that is not reading output fast enough before the client is
+	// disconnected. Set to 0 to never disconnect slow clients.
+	WebsocketMaxDroppedLines int `default:"0" yaml:"websocket_max_dropped_lines"`
+
+	// DiskUsageAlertThresholds are the percentages of a server's disk limit at which
+	// an event is sent to the websocket to warn the user that they are running out
+	// of space. Each alert is only sent once until the usage drops back below it.
+	DiskUsageAlertThresholds []int `default:"[80, 90, 95]" yaml:"disk_usage_alert_thresholds"`
+
+	// StopOnPidsLimit controls if a server is stopped when its container reaches the
+	// maximum number of processes it may run. A warning is always sent to the console
+	// when the limit is reached, but the server is otherwise left running since it may
+	// recover once some of its processes exit.
+	StopOnPidsLimit bool `default:"false" yaml:"stop_on_pids_limit"`
+
+	// DiskLimiterGraceSamples is the number of consecutive resource samples a running
+	// server must exceed its disk space limit for before it is stopped. This prevents
+	// servers from being stopped by a short spike in disk usage that corrects itself,
+	// such as while a world is being saved. Set to 0 to stop servers immediately.
+	DiskLimiterGraceSamples int `default:"0" yaml:"disk_limiter_grace_samples"`
+
+	// ConfigParserWorkers is the maximum number of server configuration files that will
+	// be processed at the same time across the entire node when servers are booting. If
+	// set to 0 the number of CPUs available on the system is used.
+	ConfigParserWorkers int `default:"0" yaml:"config_parser_workers"`
+
+	// LogFormat controls the format of the logs written by Wings. The default "text" format
+	// is easy to read when running interactively, while "json" will emit a structured JSON
+	// object for each line, which is much easier for log aggregation pipelines to index.
+	LogFormat string `default:"text" yaml:"log_format"`
+
 	Sftp SftpConfiguration `yaml:"sftp"`
 
 	CrashDetection CrashDetection `yaml:"crash_detection"`
@@ -221,7 +423,42 @@ type SystemConfiguration struct {
 
 	Transfers Transfers `yaml:"transfers"`
 
+	Database Database `yaml:"database"`
+
+	ConsoleForwarding ConsoleForwarding `yaml:"console_forwarding"`
+
 	OpenatMode string `default:"auto" yaml:"openat_mode"`
+
+	// MaxDecompressionRatio is the maximum ratio between the uncompressed size of an
+	// archive's contents and the size of the archive itself that will be allowed when
+	// decompressing files for a server. Archives exceeding this ratio are refused to
+	// protect the node against decompression bombs. Set to 0 to disable this check.
+	MaxDecompressionRatio int64 `default:"0" yaml:"max_decompression_ratio"`
+
+	// DecompressionConcurrency is the number of files that are extracted at the same
+	// time when decompressing a zip archive for a server. Extracting files in
+	// parallel is considerably faster for archives with many small files on fast
+	// storage. A value of 1 extracts files sequentially.
+	DecompressionConcurrency int `default:"1" yaml:"decompression_concurrency"`
+
+	// AllowedArchiveFormats is the list of archive formats that servers are allowed to
+	// decompress, named by their file extension without the leading dot, such as "zip",
+	// "tar", "tar.gz", "tar.xz", "rar" or "7z". Archives in any other format are refused
+	// before they are extracted. When empty every format Wings understands is allowed.
+	AllowedArchiveFormats []string `yaml:"allowed_archive_formats"`
+
+	// ProjectQuotas enables enforcing the disk limit of servers using project quotas on
+	// the filesystem their data directories are stored on, rather than only relying on
+	// the disk usage being checked periodically. This requires an xfs or ext4 filesystem
+	// mounted with project quotas enabled, if they are not available the existing disk
+	// limiter continues to be used on its own.
+	ProjectQuotas bool `default:"false" yaml:"project_quotas"`
+
+	// MaxDownloadsPerServer is the maximum number of files, backups and archives that
+	// can be downloaded from a single server at the same time. Each download holds an
+	// open file handle for its duration, so this prevents a single server exhausting
+	// the file descriptors available to Wings. Set to 0 to disable this limit.
+	MaxDownloadsPerServer int `default:"0" yaml:"max_downloads_per_server"`
 }
 
 type CrashDetection struct {
@@ -261,6 +498,58 @@ type Backups struct {
 
 	// RemoveBackupsOnServerDelete deletes backups associated with a server when the server is deleted
 	RemoveBackupsOnServerDelete bool `default:"true" yaml:"remove_backups_on_server_delete"`
+
+	// S3Streaming causes backups using the S3 adapter to be uploaded as they are being
+	// generated, rather than first writing the entire archive to the disk. Only the
+	// parts currently being uploaded are stored on the disk at any given time.
+	S3Streaming bool `default:"false" yaml:"s3_streaming"`
+
+	// S3PartSize is the preferred size in MiB of each part uploaded when using the S3
+	// adapter, which is sent to the Panel when requesting the upload URLs. The value
+	// must be between 5 MiB and 5 GiB. If set to 0 the part size configured on the
+	// Panel is used. The part size returned by the Panel is always used for uploads.
+	S3PartSize int64 `default:"0" yaml:"s3_part_size"`
+
+	// S3UploadConcurrency is the number of parts that are uploaded to S3 at the same
+	// time. When streaming backups, each part being uploaded is stored temporarily
+	// on the disk, so this also controls the disk space used by those backups.
+	S3UploadConcurrency int `default:"4" yaml:"s3_upload_concurrency"`
+
+	// ChecksumAlgorithm is the algorithm used to generate the checksum of a backup
+	// that is reported to the Panel. Supported values are "sha256", "sha1" and
+	// "blake3", the latter being considerably faster for very large backups.
+	ChecksumAlgorithm string `default:"sha256" yaml:"checksum_algorithm"`
+
+	// RestoreDownloadRetries is the number of times downloading a remote backup to
+	// restore it is retried if the connection fails. Where the remote location
+	// supports it the download is resumed from where it failed, otherwise the
+	// restore fails.
+	RestoreDownloadRetries int `default:"3" yaml:"restore_download_retries"`
+
+	// ZfsSnapshots creates local backups of servers whose data directory is the root
+	// of its own ZFS dataset by taking a snapshot of the dataset and storing the
+	// output of "zfs send", which is considerably faster than creating an archive.
+	// Restoring one of these backups replaces the entire dataset using "zfs receive".
+	// Backups with ignored files, and servers not stored on their own dataset, always
+	// use a regular archive.
+	ZfsSnapshots bool `default:"false" yaml:"zfs_snapshots"`
+}
+
+// Validate checks that the backup configuration values are within the limits
+// allowed by the storage providers.
+func (b Backups) Validate() error {
+	if b.S3PartSize != 0 && (b.S3PartSize < 5 || b.S3PartSize > 5120) {
+		return errors.Errorf("config: invalid system.backups.s3_part_size %d: must be between 5 and 5120 MiB", b.S3PartSize)
+	}
+	switch b.ChecksumAlgorithm {
+	case "sha256", "sha1", "blake3":
+	default:
+		return errors.Errorf("config: invalid system.backups.checksum_algorithm %q: must be one of sha256, sha1 or blake3", b.ChecksumAlgorithm)
+	}
+	if b.S3UploadConcurrency < 1 {
+		return errors.Errorf("config: invalid system.backups.s3_upload_concurrency %d: must be at least 1", b.S3UploadConcurrency)
+	}
+	return nil
 }
 
 type Transfers struct {
@@ -273,6 +562,53 @@ type Transfers struct {
 	DownloadLimit int `default:"0" yaml:"download_limit"`
 }
 
+// Database defines the configuration for the local SQLite database that is used
+// to store server activity before it is sent to the Panel.
+type Database struct {
+	// Path is the location of the database file. If left empty the database is
+	// stored as "wings.db" in the root directory.
+	Path string `default:"" yaml:"path"`
+
+	// JournalMode is the SQLite journal mode used for the database. WAL allows the
+	// database to be read while activity is being written to it, which greatly
+	// reduces lock contention with the activity cron.
+	JournalMode string `default:"wal" yaml:"journal_mode"`
+
+	// BusyTimeout is the number of milliseconds SQLite waits for a lock on the
+	// database to be released before returning a busy error.
+	BusyTimeout int `default:"5000" yaml:"busy_timeout"`
+}
+
+// ConsoleForwarding defines the configuration for sending the console output of
+// every server on the node to an external logging service as it is produced.
+type ConsoleForwarding struct {
+	// Type is the kind of endpoint console output is sent to, either "syslog", "http"
+	// or "loki". Leaving this empty disables console forwarding.
+	Type string `default:"" yaml:"type"`
+
+	// Address is where console output is sent. For syslog this is a network address
+	// such as "udp://logs.example.com:514", or empty to use the local syslog daemon.
+	// For http and loki this is the full URL that batches are sent to, for Loki this
+	// is usually "https://loki.example.com/loki/api/v1/push".
+	Address string `default:"" yaml:"address"`
+
+	// Headers are additional headers sent with every request to an http or loki
+	// endpoint, for example to provide authentication.
+	Headers map[string]string `yaml:"headers"`
+
+	// BatchSize is the maximum number of lines sent to the endpoint at once.
+	BatchSize int `default:"500" yaml:"batch_size"`
+
+	// FlushInterval is the maximum number of milliseconds a line is held before the
+	// current batch is sent, even if the batch is not full.
+	FlushInterval int `default:"1000" yaml:"flush_interval"`
+
+	// BufferSize is the number of lines that can be waiting to be sent before new
+	// lines are dropped. This prevents a slow or unavailable endpoint from blocking
+	// the processing of console output for servers.
+	BufferSize int `default:"10000" yaml:"buffer_size"`
+}
+
 type ConsoleThrottles struct {
 	// Whether or not the throttler is enabled for this instance.
 	Enabled bool `json:"enabled" yaml:"enabled" default:"true"`
@@ -321,10 +657,21 @@ type Configuration struct {
 	PanelLocation string                   `json:"-" yaml:"remote"`
 	RemoteQuery   RemoteQueryConfiguration `json:"remote_query" yaml:"remote_query"`
 
+	// PanelFallbackLocations are additional locations of the Panel that are used when
+	// the primary location is unreachable. Only requests that are safe to repeat, such
+	// as fetching server configurations and sending activity, are sent to them.
+	PanelFallbackLocations []string `json:"-" yaml:"remote_fallbacks"`
+
 	// AllowedMounts is a list of allowed host-system mount points.
 	// This is required to have the "Server Mounts" feature work properly.
 	AllowedMounts []string `json:"-" yaml:"allowed_mounts"`
 
+	// DeniedMounts is a list of additional host paths that can never be mounted into
+	// a server container, even if they are within one of the allowed mount points.
+	// Wings always denies its own directories, the Docker socket, and sensitive
+	// system paths such as /, /etc and /proc in addition to the paths listed here.
+	DeniedMounts []string `json:"-" yaml:"denied_mounts"`
+
 	SearchRecursion SearchRecursion `yaml:"Search"`
 	// BlockBaseDirMount indicates whether mounting to /home/container is blocked.
 	// If true, mounting to /home/container is blocked.
@@ -409,6 +756,59 @@ func Get() *Configuration {
 	return &c
 }
 
+// Clone returns a deep copy of the configuration. Unlike the shallow copy that
+// is returned by Get, any maps, slices, or pointers within the copy can be
+// modified, such as by decoding a request into it, without also modifying the
+// global configuration.
+func (c *Configuration) Clone() *Configuration {
+	out := *c
+	cloneValue(reflect.ValueOf(&out).Elem())
+	return &out
+}
+
+// Replaces any maps, slices, or pointers within the value with copies of them.
+func cloneValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Field(i); f.CanSet() {
+				cloneValue(f)
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(s, v)
+		for i := 0; i < s.Len(); i++ {
+			cloneValue(s.Index(i))
+		}
+		v.Set(s)
+	case reflect.Map:
+		if v.IsNil() {
+			return
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(iter.Value())
+			cloneValue(e)
+			m.SetMapIndex(iter.Key(), e)
+		}
+		v.Set(m)
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(v.Elem())
+		cloneValue(p.Elem())
+		v.Set(p)
+	}
+}
+
 // Update performs an in-situ update of the global configuration object using
 // a thread-safe mutex lock. This is the correct way to make modifications to
 // the global configuration.
@@ -425,6 +825,53 @@ func GetJwtAlgorithm() *jwt.HMACSHA {
 	return _jwtAlgo
 }
 
+// s3CredentialsRegex matches the credentials and signature included in the query
+// string of pre-signed S3 URLs, such as those used to upload backups.
+var s3CredentialsRegex = regexp.MustCompile(`(?i)(X-Amz-(?:Credential|Signature|Security-Token)=)[^&\s"]+`)
+
+// minRedactedSecretLength is the length a container environment value or registry
+// password must be before it is redacted, so that common values such as "1" or
+// "true" do not mangle every line they appear in.
+const minRedactedSecretLength = 8
+
+// Redact replaces any values in the string that identify how to reach or
+// authenticate with this node, such as the Panel location and the node's
+// authentication token, with "{redacted}". The values of the node-wide container
+// environment variables and registry passwords that are at least 8 bytes long, and
+// the credentials in pre-signed S3 URLs, are redacted as well.
+func (c *Configuration) Redact(s string) string {
+	values := []string{
+		c.AuthenticationToken,
+		c.AuthenticationTokenId,
+		c.PanelLocation,
+		c.Api.Host,
+		c.Api.Ssl.CertificateFile,
+		c.Api.Ssl.KeyFile,
+		c.System.Sftp.Address,
+	}
+	for _, v := range c.Docker.ContainerEnv {
+		if len(v) >= minRedactedSecretLength {
+			values = append(values, v)
+		}
+	}
+	for _, r := range c.Docker.Registries {
+		if len(r.Password) >= minRedactedSecretLength {
+			values = append(values, r.Password)
+		}
+	}
+	// Replace the longest values first so that a value containing another one is
+	// never partially left behind, regardless of the order of the maps above.
+	sort.SliceStable(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+	for _, v := range values {
+		if v != "" {
+			s = strings.ReplaceAll(s, v, "{redacted}")
+		}
+	}
+	return s3CredentialsRegex.ReplaceAllString(s, "${1}{redacted}")
+}
+
 // WriteToDisk writes the configuration to the disk. This is a thread safe operation
 // and will only allow one write at a time. Additional calls while writing are
 // queued up.
@@ -540,6 +987,15 @@ func FromFile(path string) error {
 		return err
 	}
 
+	// Validate the TLS configuration now so that invalid values are reported when
+	// the configuration is loaded, rather than when the webserver is started.
+	if _, err := c.Api.TLSConfig(); err != nil {
+		return err
+	}
+	if err := c.System.Backups.Validate(); err != nil {
+		return err
+	}
+
 	// Store this configuration in the global state.
 	Set(c)
 	return nil
@@ -654,6 +1110,19 @@ func EnableLogRotation() error {
 	return errors.Wrap(t.Execute(f, _config.System), "config: failed to write logrotate to disk")
 }
 
+// GetLogPath returns the location of the log file Wings writes to.
+func (sc *SystemConfiguration) GetLogPath() string {
+	return path.Join(sc.LogDirectory, "wings.log")
+}
+
+// GetDatabasePath returns the location of the local SQLite database file.
+func (sc *SystemConfiguration) GetDatabasePath() string {
+	if sc.Database.Path != "" {
+		return sc.Database.Path
+	}
+	return path.Join(sc.RootDirectory, "wings.db")
+}
+
 // GetStatesPath returns the location of the JSON file that tracks server states.
 func (sc *SystemConfiguration) GetStatesPath() string {
 	return path.Join(sc.RootDirectory, "/states.json")
@@ -721,6 +1190,10 @@ var (
 	openat2Set atomic.Bool
 )
 
+// UseOpenat2 returns whether the openat2 syscall should be used when resolving
+// paths within a server's filesystem. When the mode is set to "auto" support for
+// openat2 is checked the first time this is called, and a warning is logged if
+// the less secure openat fallback has to be used.
 func UseOpenat2() bool {
 	if openat2Set.Load() {
 		return openat2.Load()
@@ -739,7 +1212,7 @@ func UseOpenat2() bool {
 	default:
 		fd, err := unix.Openat2(unix.AT_FDCWD, "/", &unix.OpenHow{})
 		if err != nil {
-			log.WithError(err).Warn("error occurred while checking for openat2 support, falling back to openat")
+			log.WithError(err).Warn("openat2 is not supported by the kernel, falling back to openat: paths within server filesystems will be resolved by manually validating symlinks rather than being enforced by the kernel using RESOLVE_BENEATH, upgrade to Linux 5.6 or later to use openat2")
 			openat2.Store(false)
 			return false
 		}
@@ -748,3 +1221,12 @@ func UseOpenat2() bool {
 		return true
 	}
 }
+
+// OpenatModeInUse returns the name of the syscall that is being used to resolve
+// paths within server filesystems, either "openat2" or "openat".
+func OpenatModeInUse() string {
+	if UseOpenat2() {
+		return "openat2"
+	}
+	return "openat"
+}
@@ -1,8 +1,15 @@
 package config
 
 import (
+	"context"
 	"encoding/base64"
+	"os/exec"
 	"sort"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/distribution/reference"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/registry"
@@ -52,29 +59,96 @@ type DockerConfiguration struct {
 	// Domainname is the Docker domainname for all containers.
 	Domainname string `default:"" json:"domainname" yaml:"domainname"`
 
-	// Registries .
+	// Registries defines the credentials used when pulling images from private
+	// registries, keyed by the registry host (and optionally a path prefix such
+	// as "ghcr.io/my-org"). When multiple entries match an image the most specific
+	// one is used.
 	Registries map[string]RegistryConfiguration `json:"registries" yaml:"registries"`
 
+	// RegistryMirrors maps a registry host to a mirror that images from that registry
+	// are pulled through, for example "docker.io: mirror.internal/dockerhub" causes
+	// "docker.io/library/alpine" to be pulled as "mirror.internal/dockerhub/library/alpine".
+	// If an image cannot be pulled from the mirror it is pulled from the original
+	// registry instead. Credentials for the mirror are looked up in Registries.
+	RegistryMirrors map[string]string `json:"registry_mirrors" yaml:"registry_mirrors"`
+
+	// Labels is a set of additional labels applied to every server container when
+	// it is created. Both the keys and values may contain placeholders which are
+	// replaced with details about the server:
+	//
+	//   {{server.uuid}}, {{server.name}}, {{server.allocation.ip}} and
+	//   {{server.allocation.port}}
+	//
+	// Any characters in the server name other than letters, numbers, spaces,
+	// periods, dashes and underscores are replaced with a dash.
+	//
+	// This allows external tooling, such as a reverse proxy or monitoring agent,
+	// to discover containers without needing to query the Panel.
+	Labels map[string]string `json:"labels" yaml:"labels"`
+
+	// ContainerEnv is a set of environment variables that are injected into every
+	// container created by Wings, including installation containers. Variable names
+	// are upper-cased, and any variable defined for a specific server takes precedence
+	// over a value defined here. The values may contain secrets, so they are never
+	// exposed to configuration file placeholders or diagnostics reports.
+	ContainerEnv map[string]string `json:"-" yaml:"container_env"`
+
 	// TmpfsSize specifies the size for the /tmp directory mounted into containers. Please be
 	// aware that Docker utilizes the host's system memory for this value, and that we do not
 	// keep track of the space used there, so avoid allocating too much to a server.
 	TmpfsSize uint `default:"100" json:"tmpfs_size" yaml:"tmpfs_size"`
 
+	// ImagePullRetries is the number of times a failed image pull will be retried
+	// before giving up. Set to 0 to disable retrying failed image pulls.
+	ImagePullRetries int `default:"3" json:"image_pull_retries" yaml:"image_pull_retries"`
+
+	// ImagePullRetryDelay is the number of seconds to wait before retrying a failed
+	// image pull. The delay is doubled after each failed attempt.
+	ImagePullRetryDelay int `default:"5" json:"image_pull_retry_delay" yaml:"image_pull_retry_delay"`
+
 	// ContainerPidLimit sets the total number of processes that can be active in a container
 	// at any given moment. This is a security concern in shared-hosting environments where a
 	// malicious process could create enough processes to cause the host node to run out of
 	// available pids and crash.
 	ContainerPidLimit int64 `default:"512" json:"container_pid_limit" yaml:"container_pid_limit"`
 
+	// OomScoreAdj is the default OOM score adjustment applied to server containers,
+	// between -1000 and 1000. Raising this value makes the kernel OOM killer more likely
+	// to target game servers rather than Wings or other critical processes when the
+	// node runs out of memory. Individual servers may override this value.
+	OomScoreAdj int `default:"0" json:"oom_score_adj" yaml:"oom_score_adj"`
+
 	// InstallerLimits defines the limits on the installer containers that prevents a server's
 	// installation process from unintentionally consuming more resources than expected. This
 	// is used in conjunction with the server's defined limits. Whichever value is higher will
 	// take precedence in the installer containers.
+	//
+	// MaxMemory and MaxCpu cap the resulting limits so that a server with very high (or
+	// unlimited) limits cannot monopolize the node while installing. A value of 0 means
+	// that no cap is applied.
 	InstallerLimits struct {
-		Memory int64 `default:"1024" json:"memory" yaml:"memory"`
-		Cpu    int64 `default:"100" json:"cpu" yaml:"cpu"`
+		Memory    int64 `default:"1024" json:"memory" yaml:"memory"`
+		Cpu       int64 `default:"100" json:"cpu" yaml:"cpu"`
+		MaxMemory int64 `default:"0" json:"max_memory" yaml:"max_memory"`
+		MaxCpu    int64 `default:"0" json:"max_cpu" yaml:"max_cpu"`
 	} `json:"installer_limits" yaml:"installer_limits"`
 
+	// IoThrottle controls the disk I/O limits that are applied to server containers
+	// to prevent a single server from degrading disk performance for every other
+	// server on the node.
+	IoThrottle IoThrottle `json:"io_throttle" yaml:"io_throttle"`
+
+	// Bandwidth controls the default network bandwidth limits applied to server
+	// containers, in megabits per second. Ingress limits the traffic received by the
+	// container and Egress the traffic sent by it. Limits are applied using "tc" on
+	// the host side of the container's network interface, so iproute2 and nsenter
+	// must be installed on the node. A value of 0 means no limit is applied unless
+	// one is set for the server.
+	Bandwidth struct {
+		Ingress uint64 `default:"0" json:"ingress" yaml:"ingress"`
+		Egress  uint64 `default:"0" json:"egress" yaml:"egress"`
+	} `json:"bandwidth" yaml:"bandwidth"`
+
 	// Overhead controls the memory overhead given to all containers to circumvent certain
 	// software such as the JVM not staying below the maximum memory limit.
 	Overhead Overhead `json:"overhead" yaml:"overhead"`
@@ -107,10 +181,16 @@ func (c DockerConfiguration) ContainerLogConfig() container.LogConfig {
 }
 
 // RegistryConfiguration defines the authentication credentials for a given
-// Docker registry.
+// Docker registry. Credentials can either be provided directly, or retrieved
+// from a Docker credential helper installed on the system.
 type RegistryConfiguration struct {
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+
+	// CredentialHelper is the name of a Docker credential helper, for example
+	// "ecr-login", which is used to retrieve the credentials for the registry.
+	// The "docker-credential-" prefix of the executable should be omitted.
+	CredentialHelper string `yaml:"credential_helper"`
 }
 
 // Base64 returns the authentication for a given registry as a base64 encoded
@@ -126,6 +206,119 @@ func (c RegistryConfiguration) Base64() (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
+// String returns a description of the registry configuration that is safe to
+// include in logs and diagnostic reports.
+func (c RegistryConfiguration) String() string {
+	if c.CredentialHelper != "" {
+		return "credential helper " + c.CredentialHelper
+	}
+	if c.Username != "" {
+		return "username " + c.Username + " with password {redacted}"
+	}
+	return "no credentials"
+}
+
+// Returns the credentials for the registry, executing the credential helper for
+// the registry if one is configured.
+func (c RegistryConfiguration) credentials(host string) (RegistryConfiguration, error) {
+	if c.CredentialHelper == "" {
+		return c, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "docker-credential-"+c.CredentialHelper, "get")
+	cmd.Stdin = strings.NewReader(host)
+	out, err := cmd.Output()
+	if err != nil {
+		return c, errors.Wrapf(err, "config: failed to execute credential helper for registry %s", host)
+	}
+
+	var res struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(out, &res); err != nil {
+		return c, errors.Wrapf(err, "config: failed to parse credential helper response for registry %s", host)
+	}
+	return RegistryConfiguration{Username: res.Username, Password: res.Secret}, nil
+}
+
+// RegistryAuth returns the base64 encoded authentication to use when pulling the
+// given image, or an empty string if no registry has been configured for it.
+//
+// Registries are matched against the image on path boundaries, so an entry for
+// "registry.example.com" does not match "registry.example.com.evil.io/image".
+func (c DockerConfiguration) RegistryAuth(image string) (string, error) {
+	var match string
+	for k := range c.Registries {
+		name := strings.TrimSuffix(k, "/")
+		if name == "" || len(name) <= len(strings.TrimSuffix(match, "/")) {
+			continue
+		}
+		if image == name || strings.HasPrefix(image, name+"/") || strings.HasPrefix(image, name+":") {
+			match = k
+		}
+	}
+	if match == "" {
+		return "", nil
+	}
+
+	host, _, _ := strings.Cut(strings.TrimSuffix(match, "/"), "/")
+	creds, err := c.Registries[match].credentials(host)
+	if err != nil {
+		return "", err
+	}
+	return creds.Base64()
+}
+
+// MirrorImage returns the image reference rewritten to be pulled through the mirror
+// configured for its registry, or false if no mirror is configured for it.
+func (c DockerConfiguration) MirrorImage(image string) (string, bool) {
+	if len(c.RegistryMirrors) == 0 {
+		return "", false
+	}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", false
+	}
+	mirror := strings.TrimSuffix(c.RegistryMirrors[reference.Domain(named)], "/")
+	if mirror == "" {
+		return "", false
+	}
+	out := mirror + "/" + reference.Path(named)
+	if digested, ok := named.(reference.Digested); ok {
+		return out + "@" + digested.Digest().String(), true
+	}
+	return out + ":" + reference.TagNameOnly(named).(reference.Tagged).Tag(), true
+}
+
+// IoLimits are the read and write limits for disk I/O on a container. A value
+// of 0 means no limit is applied.
+type IoLimits struct {
+	ReadBps   uint64 `default:"0" json:"read_bps" yaml:"read_bps"`
+	WriteBps  uint64 `default:"0" json:"write_bps" yaml:"write_bps"`
+	ReadIops  uint64 `default:"0" json:"read_iops" yaml:"read_iops"`
+	WriteIops uint64 `default:"0" json:"write_iops" yaml:"write_iops"`
+}
+
+// IoThrottle configures the disk I/O limits applied to server containers.
+type IoThrottle struct {
+	// Device is the block device the limits are applied to. If left empty the
+	// device containing the server data directory is used.
+	Device string `default:"" json:"device" yaml:"device"`
+
+	// Defaults are the limits applied to servers that do not have their own
+	// limits set by the Panel.
+	Defaults IoLimits `json:"defaults" yaml:"defaults"`
+
+	// Maximum are the highest limits that a server may be given. Any limits set
+	// for a server above these values are lowered to them. A value of 0 allows
+	// any limit to be set for a server.
+	Maximum IoLimits `json:"maximum" yaml:"maximum"`
+}
+
 // Overhead controls the memory overhead given to all containers to circumvent certain
 // software such as the JVM not staying below the maximum memory limit.
 type Overhead struct {
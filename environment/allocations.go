@@ -2,6 +2,9 @@ package environment
 
 import (
 	"fmt"
+	"net"
+	"slices"
+	"sort"
 	"strconv"
 
 	"github.com/docker/go-connections/nat"
@@ -30,6 +33,31 @@ type Allocations struct {
 	Mappings map[string][]int `json:"mappings"`
 }
 
+// List returns every allocation assigned to the server as "ip:port" pairs. The default
+// allocation is always first, and the remaining allocations are sorted by IP and port.
+func (a *Allocations) List() []string {
+	var out []string
+	if a.DefaultMapping.Ip != "" || a.DefaultMapping.Port != 0 {
+		out = append(out, net.JoinHostPort(a.DefaultMapping.Ip, strconv.Itoa(a.DefaultMapping.Port)))
+	}
+	ips := make([]string, 0, len(a.Mappings))
+	for ip := range a.Mappings {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	for _, ip := range ips {
+		ports := slices.Clone(a.Mappings[ip])
+		sort.Ints(ports)
+		for _, port := range ports {
+			if ip == a.DefaultMapping.Ip && port == a.DefaultMapping.Port {
+				continue
+			}
+			out = append(out, net.JoinHostPort(ip, strconv.Itoa(port)))
+		}
+	}
+	return out
+}
+
 // Converts the server allocation mappings into a format that can be understood by Docker. While
 // we do strive to support multiple environments, using Docker's standardized format for the
 // bindings certainly makes life a little easier for managing things.
@@ -0,0 +1,135 @@
+package docker
+
+import (
+	"context"
+	"net"
+	"os/exec"
+	"regexp"
+	"strconv"
+
+	"emperror.dev/errors"
+
+	"github.com/pelican-dev/wings/config"
+)
+
+var peerIndexRegex = regexp.MustCompile(`@if(\d+):`)
+
+// applyBandwidthLimits applies the network bandwidth limits for the server to the
+// host side of the container's network interface using traffic control. Any error
+// is logged rather than returned since the server should still be able to run if
+// the limits could not be applied.
+func (e *Environment) applyBandwidthLimits(ctx context.Context) {
+	ingress, egress := e.Configuration.Limits().Bandwidth()
+	if ingress == 0 && egress == 0 {
+		e.clearBandwidthLimits()
+		return
+	}
+	if config.Get().Docker.Network.Mode == "host" {
+		e.log().Warn("bandwidth limits cannot be applied to containers using host networking")
+		return
+	}
+
+	iface, err := e.hostInterface(ctx)
+	if err != nil {
+		e.log().WithField("error", err).Warn("failed to determine container network interface, not applying bandwidth limits")
+		return
+	}
+
+	e.mu.Lock()
+	e.bandwidthIface = iface
+	e.mu.Unlock()
+
+	// Remove any existing rules first so that changing or removing a limit for a running
+	// server is applied correctly.
+	_ = runTc(ctx, "qdisc", "del", "dev", iface, "root")
+	_ = runTc(ctx, "qdisc", "del", "dev", iface, "ingress")
+
+	// Traffic received by the container leaves the host through the host side of the
+	// interface, so it is shaped with a token bucket on that interface.
+	if ingress > 0 {
+		if err := runTc(ctx, "qdisc", "add", "dev", iface, "root", "tbf",
+			"rate", rate(ingress), "burst", burst(ingress), "latency", "50ms"); err != nil {
+			e.log().WithField("error", err).Warn("failed to apply ingress bandwidth limit to container")
+		}
+	}
+	// Traffic sent by the container arrives on the host side of the interface, which can
+	// only be policed rather than shaped.
+	if egress > 0 {
+		err := runTc(ctx, "qdisc", "add", "dev", iface, "handle", "ffff:", "ingress")
+		if err == nil {
+			err = runTc(ctx, "filter", "add", "dev", iface, "parent", "ffff:", "protocol", "all",
+				"u32", "match", "u32", "0", "0", "police", "rate", rate(egress), "burst", burst(egress), "drop", "flowid", ":1")
+		}
+		if err != nil {
+			e.log().WithField("error", err).Warn("failed to apply egress bandwidth limit to container")
+		}
+	}
+
+	e.log().WithField("interface", iface).WithField("ingress_mbps", ingress).WithField("egress_mbps", egress).
+		Debug("applied bandwidth limits to container")
+}
+
+// clearBandwidthLimits removes any bandwidth limits that were applied to the
+// container's network interface. The interface is normally removed by Docker once
+// the container has stopped, so failures here are ignored.
+func (e *Environment) clearBandwidthLimits() {
+	e.mu.Lock()
+	iface := e.bandwidthIface
+	e.bandwidthIface = ""
+	e.mu.Unlock()
+	if iface == "" {
+		return
+	}
+	if _, err := net.InterfaceByName(iface); err != nil {
+		return
+	}
+	_ = runTc(context.Background(), "qdisc", "del", "dev", iface, "root")
+	_ = runTc(context.Background(), "qdisc", "del", "dev", iface, "ingress")
+}
+
+// hostInterface returns the name of the host side of the veth pair for the
+// container's primary network interface.
+func (e *Environment) hostInterface(ctx context.Context) (string, error) {
+	c, err := e.ContainerInspect(ctx)
+	if err != nil {
+		return "", err
+	}
+	if c.State == nil || c.State.Pid == 0 {
+		return "", errors.New("container is not running")
+	}
+	out, err := exec.CommandContext(ctx, "nsenter", "--target", strconv.Itoa(c.State.Pid), "--net", "ip", "-o", "link", "show", "eth0").Output()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read container network interface")
+	}
+	m := peerIndexRegex.FindSubmatch(out)
+	if m == nil {
+		return "", errors.New("container network interface is not a veth pair")
+	}
+	idx, err := strconv.Atoi(string(m[1]))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	iface, err := net.InterfaceByIndex(idx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find host network interface for container")
+	}
+	return iface.Name, nil
+}
+
+func runTc(ctx context.Context, args ...string) error {
+	if out, err := exec.CommandContext(ctx, "tc", args...).CombinedOutput(); err != nil {
+		return errors.Wrap(err, string(out))
+	}
+	return nil
+}
+
+// rate returns the tc rate for the given number of megabits per second.
+func rate(mbps uint64) string {
+	return strconv.FormatUint(mbps, 10) + "mbit"
+}
+
+// burst returns the tc burst size for the given rate, allowing roughly 100ms of
+// traffic at the full rate with a minimum of 32KiB.
+func burst(mbps uint64) string {
+	return strconv.FormatUint(max(mbps*1_000_000/8/10, 32*1024), 10)
+}
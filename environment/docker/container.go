@@ -17,6 +17,7 @@ import (
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
 
 	"github.com/pelican-dev/wings/config"
 	"github.com/pelican-dev/wings/environment"
@@ -129,6 +130,10 @@ func (e *Environment) InSituUpdate() error {
 	}); err != nil {
 		return errors.Wrap(err, "environment/docker: could not update container")
 	}
+
+	if e.State() != environment.ProcessOfflineState {
+		e.applyBandwidthLimits(ctx)
+	}
 	return nil
 }
 
@@ -239,7 +244,8 @@ func (e *Environment) Create() error {
 
 		// Define resource limits for the container based on the data passed through
 		// from the Panel.
-		Resources: e.Configuration.Limits().AsContainerResources(),
+		Resources:   e.Configuration.Limits().AsContainerResources(),
+		OomScoreAdj: e.Configuration.Limits().OomScoreAdjustment(),
 
 		DNS: cfg.Docker.Network.Dns,
 
@@ -316,8 +322,9 @@ func (e *Environment) SendCommand(c string) error {
 }
 
 // Readlog reads the log file for the server. This does not care if the server
-// is running or not, it will simply try to read the last X bytes of the file
-// and return them.
+// is running or not, it will simply try to read the last X lines of the file
+// and return them. Docker reads the log file from the end, so only the lines
+// being returned are read from the disk.
 func (e *Environment) Readlog(lines int) ([]string, error) {
 	r, err := e.client.ContainerLogs(context.Background(), e.Id, container.LogsOptions{
 		ShowStdout: true,
@@ -329,7 +336,7 @@ func (e *Environment) Readlog(lines int) ([]string, error) {
 	}
 	defer r.Close()
 
-	var out []string
+	out := make([]string, 0, lines)
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		out = append(out, scanner.Text())
@@ -338,6 +345,64 @@ func (e *Environment) Readlog(lines int) ([]string, error) {
 	return out, nil
 }
 
+// SearchLog searches the logs for the container for lines matching the provided
+// options. Docker reads across any rotated log files for the container, allowing
+// the entire retained history to be searched rather than only the latest file.
+func (e *Environment) SearchLog(ctx context.Context, opts environment.LogSearchOptions) (environment.LogSearchResult, error) {
+	var res environment.LogSearchResult
+
+	lo := container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+	}
+	if !opts.Since.IsZero() {
+		lo.Since = opts.Since.Format(time.RFC3339Nano)
+	}
+	if !opts.Until.IsZero() {
+		lo.Until = opts.Until.Format(time.RFC3339Nano)
+	}
+	r, err := e.client.ContainerLogs(ctx, e.Id, lo)
+	if err != nil {
+		return res, errors.WithStack(err)
+	}
+	defer r.Close()
+
+	var scanned int64
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		b := scanner.Bytes()
+		scanned += int64(len(b)) + 1
+		if opts.MaxScanBytes > 0 && scanned > opts.MaxScanBytes {
+			res.Truncated = true
+			break
+		}
+
+		var line environment.LogLine
+		ts, text, ok := strings.Cut(string(b), " ")
+		if t, err := time.Parse(time.RFC3339Nano, ts); ok && err == nil {
+			line.Timestamp = t
+			line.Line = text
+		} else {
+			line.Line = string(b)
+		}
+		if opts.Match != nil && !opts.Match(line.Line) {
+			continue
+		}
+		res.Lines = append(res.Lines, line)
+		// Only keep the most recent matches once the maximum has been reached.
+		if opts.MaxResults > 0 && len(res.Lines) > opts.MaxResults {
+			res.Lines = res.Lines[1:]
+			res.Truncated = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return res, errors.Wrap(err, "environment/docker: failed to read container logs")
+	}
+	return res, nil
+}
+
 // Pulls the image from Docker. If there is an error while pulling the image
 // from the source but the image already exists locally, we will report that
 // error to the logger but continue with the process.
@@ -361,31 +426,11 @@ func (e *Environment) ensureImageExists(image string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*15)
 	defer cancel()
 
-	// Get a registry auth configuration from the config.
-	var registryAuth *config.RegistryConfiguration
-	for registry, c := range config.Get().Docker.Registries {
-		if !strings.HasPrefix(image, registry) {
-			continue
-		}
-
-		log.WithField("registry", registry).Debug("using authentication for registry")
-		registryAuth = &c
-		break
-	}
-
-	// Get the ImagePullOptions.
-	imagePullOptions := dockerImage.PullOptions{All: false}
-	if registryAuth != nil {
-		b64, err := registryAuth.Base64()
-		if err != nil {
-			log.WithError(err).Error("failed to get registry auth credentials")
-		}
-
-		// b64 is a string so if there is an error it will just be empty, not nil.
-		imagePullOptions.RegistryAuth = b64
+	if e.pullFromMirror(ctx, image) {
+		return nil
 	}
 
-	out, err := e.client.ImagePull(ctx, image, imagePullOptions)
+	err := e.pullImageWithRetry(ctx, image, ImagePullOptions(image))
 	if err != nil {
 		images, ierr := e.client.ImageList(ctx, dockerImage.ListOptions{})
 		if ierr != nil {
@@ -412,7 +457,87 @@ func (e *Environment) ensureImageExists(image string) error {
 			}
 		}
 
-		return errors.Wrapf(err, "environment/docker: failed to pull \"%s\" image for server", image)
+		perr := environment.NewImagePullError(image, err)
+		e.Events().Publish(environment.DockerImagePullFailed, perr.Message())
+
+		return errors.Wrap(perr, "environment/docker")
+	}
+
+	log.WithField("image", image).Debug("completed docker image pull")
+
+	return nil
+}
+
+// ImagePullOptions returns the options used to pull an image, using the credentials
+// for the registry the image is being pulled from if any have been configured.
+func ImagePullOptions(image string) dockerImage.PullOptions {
+	opts := dockerImage.PullOptions{All: false}
+	if b64, err := config.Get().Docker.RegistryAuth(image); err != nil {
+		log.WithField("image", image).WithField("error", err).Error("failed to get registry auth credentials")
+	} else {
+		opts.RegistryAuth = b64
+	}
+	return opts
+}
+
+// Attempts to pull the image through the registry mirror configured for it, tagging
+// the mirrored image with the original reference so that containers can be created
+// using it. Returns false if there is no mirror configured for the image, or the
+// image could not be pulled from the mirror.
+func (e *Environment) pullFromMirror(ctx context.Context, image string) bool {
+	mirror, ok := config.Get().Docker.MirrorImage(image)
+	if !ok {
+		return false
+	}
+	l := log.WithFields(log.Fields{"image": image, "mirror": mirror, "container_id": e.Id})
+	if err := e.pullImage(ctx, mirror, ImagePullOptions(mirror)); err != nil {
+		l.WithField("error", err).Warn("failed to pull image from registry mirror, falling back to the original registry")
+		return false
+	}
+	if err := e.client.ImageTag(ctx, mirror, image); err != nil {
+		l.WithField("error", err).Warn("failed to tag image pulled from registry mirror, falling back to the original registry")
+		return false
+	}
+	l.Debug("pulled docker image from registry mirror")
+	return true
+}
+
+// Pulls the image, retrying the pull with an increasing delay between attempts
+// if it fails due to what is likely a transient error with the registry. Errors
+// that will not be resolved by retrying, such as the image not existing or the
+// credentials being invalid, are returned immediately.
+func (e *Environment) pullImageWithRetry(ctx context.Context, image string, opts dockerImage.PullOptions) error {
+	cfg := config.Get().Docker
+	delay := time.Duration(cfg.ImagePullRetryDelay) * time.Second
+	for attempt := 0; ; attempt++ {
+		err := e.pullImage(ctx, image, opts)
+		if err == nil || attempt >= cfg.ImagePullRetries || !isRetryablePullError(err) {
+			return err
+		}
+
+		log.WithFields(log.Fields{
+			"image":        image,
+			"container_id": e.Id,
+			"attempt":      attempt + 1,
+			"error":        err,
+		}).Warn("failed to pull docker image, retrying...")
+		e.Events().Publish(environment.DockerImagePullStatus, fmt.Sprintf("Pull failed, retrying in %s (attempt %d of %d)", delay, attempt+1, cfg.ImagePullRetries))
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// Performs a single attempt at pulling the image, blocking until the pull has
+// completed and publishing the progress of the pull as events.
+func (e *Environment) pullImage(ctx context.Context, image string, opts dockerImage.PullOptions) error {
+	out, err := e.client.ImagePull(ctx, image, opts)
+	if err != nil {
+		return err
 	}
 	defer out.Close()
 
@@ -421,22 +546,36 @@ func (e *Environment) ensureImageExists(image string) error {
 	// I'm not sure what the best approach here is, but this will block execution until the image
 	// is done being pulled, which is what we need.
 	scanner := bufio.NewScanner(out)
+	progress := newPullProgress(image)
 
 	for scanner.Scan() {
 		b := scanner.Bytes()
+		// Errors that occur part way through the pull are returned in the stream
+		// rather than by the request itself.
+		if msg, _ := jsonparser.GetString(b, "error"); msg != "" {
+			return errors.New(msg)
+		}
 		status, _ := jsonparser.GetString(b, "status")
-		progress, _ := jsonparser.GetString(b, "progress")
+		p, _ := jsonparser.GetString(b, "progress")
 
-		e.Events().Publish(environment.DockerImagePullStatus, status+" "+progress)
-	}
-
-	if err := scanner.Err(); err != nil {
-		return err
+		e.Events().Publish(environment.DockerImagePullStatus, status+" "+p)
+		if progress.Update(b) {
+			e.Events().Publish(environment.DockerImagePullProgress, progress.Progress())
+		}
 	}
 
-	log.WithField("image", image).Debug("completed docker image pull")
+	return scanner.Err()
+}
 
-	return nil
+// Determines if a failed image pull should be retried. Errors returned by the
+// registry because the image does not exist, or because the request is not
+// authorized, will not be resolved by retrying the pull.
+func isRetryablePullError(err error) bool {
+	if errdefs.IsInvalidParameter(err) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	kind := environment.ClassifyImagePullError(err)
+	return kind != environment.ImagePullNotFound && kind != environment.ImagePullUnauthorized
 }
 
 func (e *Environment) convertMounts() []mount.Mount {
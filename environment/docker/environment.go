@@ -55,6 +55,10 @@ type Environment struct {
 
 	// Tracks the environment state.
 	st *system.AtomicString
+
+	// The host side network interface that bandwidth limits were applied to for the
+	// running container, if any.
+	bandwidthIface string
 }
 
 // New creates a new base Docker environment. The ID passed through will be the
@@ -209,6 +213,10 @@ func (e *Environment) SetState(state string) {
 		// If the state changed make sure we update the internal tracking to note that.
 		e.st.Store(state)
 		e.Events().Publish(environment.StateChangeEvent, state)
+
+		if state == environment.ProcessOfflineState {
+			e.clearBandwidthLimits()
+		}
 	}
 }
 
@@ -122,6 +122,8 @@ func (e *Environment) Start(ctx context.Context) error {
 		return errors.WrapIf(err, "environment/docker: failed to start container")
 	}
 
+	e.applyBandwidthLimits(actx)
+
 	// No errors, good to continue through.
 	sawError = false
 	return nil
@@ -225,6 +227,7 @@ func (e *Environment) WaitForStop(ctx context.Context, duration time.Duration, t
 
 	doTermination := func(s string) error {
 		e.log().WithField("step", s).WithField("duration", duration).Warn("container stop did not complete in time, terminating process...")
+		e.Events().Publish(environment.StopGracePeriodExpired, duration.String())
 		return e.Terminate(ctx, "SIGKILL")
 	}
 
@@ -302,8 +305,17 @@ func (e *Environment) Terminate(ctx context.Context, signal string) error {
 	}
 
 	// Wait for up to 10 seconds, polling every 500ms, to check if the container has stopped.
+	// If the egg defines a stop grace period and we're sending something other than SIGKILL,
+	// wait that long instead before escalating.
 	const checkInterval = 500 * time.Millisecond
-	const timeout = 10 * time.Second
+	timeout := 10 * time.Second
+	if signal != "SIGKILL" {
+		e.mu.RLock()
+		if g := e.meta.Stop.GracePeriod; g > 0 {
+			timeout = time.Duration(g) * time.Second
+		}
+		e.mu.RUnlock()
+	}
 
 	ticker := time.NewTicker(checkInterval)
 	defer ticker.Stop()
@@ -336,9 +348,13 @@ func (e *Environment) Terminate(ctx context.Context, signal string) error {
 				return errors.WithStack(err)
 			}
 			e.log().WithFields(log.Fields{
-				"id": e.Id,
-			}).Debug("Sent SIGKILL to container: graceful shutdown timed out")
-			
+				"id":     e.Id,
+				"signal": signal,
+			}).Warn("sent SIGKILL to container: process did not exit after initial signal")
+			if signal != "SIGKILL" {
+				e.Events().Publish(environment.StopGracePeriodExpired, timeout.String())
+			}
+
 			// Update state to offline after SIGKILL.
 			e.SetState(environment.ProcessOfflineState)
 			return nil
@@ -0,0 +1,216 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/pelican-dev/wings/config"
+)
+
+// ErrInvalidProcess is returned when attempting to signal a process that does
+// not exist within the container, or that is not allowed to be signaled.
+var ErrInvalidProcess = errors.Sentinel("environment/docker: invalid process id")
+
+// ErrInvalidSignal is returned when attempting to send an unsupported signal to
+// a process running within the container.
+var ErrInvalidSignal = errors.Sentinel("environment/docker: invalid signal")
+
+// allowedProcessSignals is the set of signals that may be sent to an individual
+// process running inside a container.
+var allowedProcessSignals = map[string]struct{}{
+	"SIGHUP":  {},
+	"SIGINT":  {},
+	"SIGQUIT": {},
+	"SIGABRT": {},
+	"SIGKILL": {},
+	"SIGUSR1": {},
+	"SIGUSR2": {},
+	"SIGTERM": {},
+	"SIGCONT": {},
+	"SIGSTOP": {},
+}
+
+// Process represents a single process running inside a server container.
+type Process struct {
+	// Pid is the ID of the process within the container's PID namespace. This
+	// is the value that must be used when sending a signal to the process.
+	Pid int `json:"pid"`
+	// HostPid is the ID of the process as seen from the host system.
+	HostPid int     `json:"host_pid"`
+	User    string  `json:"user"`
+	Cpu     float64 `json:"cpu"`
+	Memory  float64 `json:"memory"`
+	Elapsed string  `json:"elapsed"`
+	Command string  `json:"command"`
+}
+
+// Processes returns all the processes currently running inside the container
+// for this environment.
+func (e *Environment) Processes(ctx context.Context) ([]Process, error) {
+	top, err := e.client.ContainerTop(ctx, e.Id, []string{"-eo", "pid,user,pcpu,pmem,etime,args"})
+	if err != nil {
+		return nil, errors.Wrap(err, "environment/docker: failed to list container processes")
+	}
+
+	columns := make(map[string]int, len(top.Titles))
+	for i, t := range top.Titles {
+		columns[strings.ToUpper(t)] = i
+	}
+	value := func(row []string, title string) string {
+		if i, ok := columns[title]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	resolve, err := e.pidResolver(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Process, 0, len(top.Processes))
+	for _, row := range top.Processes {
+		hostPid, err := strconv.Atoi(value(row, "PID"))
+		if err != nil {
+			continue
+		}
+		p := Process{
+			Pid:     resolve(hostPid),
+			HostPid: hostPid,
+			User:    value(row, "USER"),
+			Elapsed: value(row, "ELAPSED"),
+			Command: value(row, "COMMAND"),
+		}
+		p.Cpu, _ = strconv.ParseFloat(value(row, "%CPU"), 64)
+		p.Memory, _ = strconv.ParseFloat(value(row, "%MEM"), 64)
+		out = append(out, p)
+	}
+
+	return out, nil
+}
+
+// SignalProcess sends the given signal to a single process running inside the
+// container. The pid must be the ID of the process within the container's PID
+// namespace, and the signal is delivered by executing kill within the container
+// itself, so it is not possible to signal a process outside the container.
+//
+// The container's init process cannot be signaled using this function, use the
+// normal power actions to stop the server instead.
+func (e *Environment) SignalProcess(ctx context.Context, pid int, signal string) error {
+	signal = strings.ToUpper(signal)
+	if !strings.HasPrefix(signal, "SIG") {
+		signal = "SIG" + signal
+	}
+	if _, ok := allowedProcessSignals[signal]; !ok {
+		return ErrInvalidSignal
+	}
+	if pid <= 1 {
+		return ErrInvalidProcess
+	}
+
+	processes, err := e.Processes(ctx)
+	if err != nil {
+		return err
+	}
+	var found bool
+	for _, p := range processes {
+		if p.Pid == pid {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ErrInvalidProcess
+	}
+
+	cfg := config.Get().System
+	exec, err := e.client.ContainerExecCreate(ctx, e.Id, container.ExecOptions{
+		User:         fmt.Sprintf("%d:%d", cfg.User.Uid, cfg.User.Gid),
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          []string{"kill", "-" + strings.TrimPrefix(signal, "SIG"), strconv.Itoa(pid)},
+	})
+	if err != nil {
+		return errors.Wrap(err, "environment/docker: failed to create exec instance")
+	}
+
+	res, err := e.client.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return errors.Wrap(err, "environment/docker: failed to attach to exec instance")
+	}
+	defer res.Close()
+
+	var stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(io.Discard, &stderr, res.Reader); err != nil {
+		return errors.Wrap(err, "environment/docker: failed to read exec output")
+	}
+
+	inspect, err := e.client.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return errors.Wrap(err, "environment/docker: failed to inspect exec instance")
+	}
+	if inspect.ExitCode != 0 {
+		return errors.Errorf("environment/docker: kill exited with code %d: %s", inspect.ExitCode, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// pidResolver returns a function that converts the host process IDs returned by
+// Docker into the IDs of those processes within the container. This is done by
+// reading the process information from /proc, which is only possible if Wings
+// shares its PID namespace with the Docker daemon. If it does not, such as when
+// Wings itself runs inside a container, the IDs cannot be resolved and zero is
+// returned for every process.
+func (e *Environment) pidResolver(ctx context.Context) (func(int) int, error) {
+	c, err := e.ContainerInspect(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "environment/docker: failed to inspect container")
+	}
+	// The container's init process is always PID 1 within the container, so if it
+	// does not resolve to that the /proc available to Wings is not the one the
+	// Docker daemon reported the host process IDs from.
+	if c.State == nil || c.State.Pid <= 0 || namespacedPid(c.State.Pid) != 1 {
+		return func(int) int { return 0 }, nil
+	}
+	return namespacedPid, nil
+}
+
+// namespacedPid returns the ID of the given host process as seen from within
+// its own PID namespace. If this cannot be determined, or the process is not
+// in a nested PID namespace, zero is returned.
+func namespacedPid(hostPid int) int {
+	f, err := os.Open("/proc/" + strconv.Itoa(hostPid) + "/status")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "NSpid:" {
+			continue
+		}
+		// The first value is the ID in the namespace of the process reading the
+		// file, a process in a container has at least one more value after it.
+		if len(fields) < 3 {
+			return 0
+		}
+		pid, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil {
+			return 0
+		}
+		return pid
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package docker
+
+import (
+	"strings"
+
+	"github.com/buger/jsonparser"
+
+	"github.com/pelican-dev/wings/environment"
+)
+
+type layerProgress struct {
+	total      int64
+	downloaded int64
+	extracted  int64
+	done       bool
+}
+
+// pullProgress aggregates the per-layer progress messages from a Docker image
+// pull into a single percentage for the entire image.
+type pullProgress struct {
+	image  string
+	order  []string
+	layers map[string]*layerProgress
+	last   int
+}
+
+func newPullProgress(image string) *pullProgress {
+	return &pullProgress{image: image, layers: make(map[string]*layerProgress), last: -1}
+}
+
+// Update applies a single message from the image pull stream, returning true if
+// the aggregate progress has changed since the last time it was reported.
+func (p *pullProgress) Update(b []byte) bool {
+	id, _ := jsonparser.GetString(b, "id")
+	status, _ := jsonparser.GetString(b, "status")
+	// Messages without a layer ID describe the image as a whole (e.g. "Pulling from
+	// library/alpine" or the final digest), and don't affect the progress.
+	if id == "" || strings.HasPrefix(status, "Pulling from") {
+		return false
+	}
+	l, ok := p.layers[id]
+	if !ok {
+		l = &layerProgress{}
+		p.layers[id] = l
+		p.order = append(p.order, id)
+	}
+	current, _ := jsonparser.GetInt(b, "progressDetail", "current")
+	if total, err := jsonparser.GetInt(b, "progressDetail", "total"); err == nil && total > 0 {
+		l.total = total
+	}
+	switch status {
+	case "Downloading":
+		l.downloaded = current
+	case "Download complete", "Verifying Checksum":
+		l.downloaded = l.total
+	case "Extracting":
+		l.downloaded = l.total
+		l.extracted = current
+	case "Pull complete", "Already exists":
+		l.done = true
+	}
+	pct := p.percent()
+	if pct == p.last {
+		return false
+	}
+	p.last = pct
+	return true
+}
+
+// Progress returns the current aggregate progress of the image pull.
+func (p *pullProgress) Progress() environment.PullProgress {
+	var completed int
+	for _, l := range p.layers {
+		if l.done {
+			completed++
+		}
+	}
+	return environment.PullProgress{
+		Image:     p.image,
+		Percent:   p.percent(),
+		Layers:    len(p.layers),
+		Completed: completed,
+	}
+}
+
+// percent calculates the overall progress of the pull. Each layer contributes equally
+// to the result since the size of layers that have not started downloading is not
+// known, with downloading and extracting each making up half of a layer's progress.
+func (p *pullProgress) percent() int {
+	if len(p.layers) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, id := range p.order {
+		l := p.layers[id]
+		switch {
+		case l.done:
+			sum += 1
+		case l.total > 0:
+			sum += (float64(min(l.downloaded, l.total)) + float64(min(l.extracted, l.total))) / float64(2*l.total)
+		}
+	}
+	return int(sum * 100 / float64(len(p.layers)))
+}
@@ -82,6 +82,8 @@ func (e *Environment) pollResources(ctx context.Context) error {
 				Memory:      calculateDockerMemory(v.MemoryStats),
 				MemoryLimit: v.MemoryStats.Limit,
 				CpuAbsolute: calculateDockerAbsoluteCpu(v.PreCPUStats, v.CPUStats),
+				Pids:        v.PidsStats.Current,
+				PidsLimit:   v.PidsStats.Limit,
 				Network:     environment.NetworkStats{},
 			}
 
@@ -143,3 +145,9 @@ func calculateDockerAbsoluteCpu(pStats container.CPUStats, stats container.CPUSt
 
 	return math.Round(percent*1000) / 1000
 }
+
+// CalculateUsage returns the memory usage in bytes and the absolute CPU usage of
+// a container from a single stats response from Docker.
+func CalculateUsage(v container.StatsResponse) (uint64, float64) {
+	return calculateDockerMemory(v.MemoryStats), calculateDockerAbsoluteCpu(v.PreCPUStats, v.CPUStats)
+}
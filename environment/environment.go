@@ -12,9 +12,25 @@ const (
 	ResourceEvent            = "resources"
 	DockerImagePullStarted   = "docker image pull started"
 	DockerImagePullStatus    = "docker image pull status"
+	DockerImagePullProgress  = "docker image pull progress"
 	DockerImagePullCompleted = "docker image pull completed"
+	DockerImagePullFailed    = "docker image pull failed"
+	StopGracePeriodExpired   = "stop grace period expired"
 )
 
+// PullProgress is the aggregate progress of an image pull across all of the
+// image layers, published with the DockerImagePullProgress event.
+type PullProgress struct {
+	Image string `json:"image"`
+	// Percent is the overall progress of the pull, between 0 and 100, weighting
+	// downloading and extracting each layer equally.
+	Percent int `json:"percent"`
+	// Layers is the total number of layers in the image, and Completed the number
+	// of those layers that have been fully pulled or already existed locally.
+	Layers    int `json:"layers"`
+	Completed int `json:"completed"`
+}
+
 const (
 	ProcessOfflineState  = "offline"
 	ProcessStartingState = "starting"
@@ -98,6 +114,10 @@ type ProcessEnvironment interface {
 	// number of lines is met.
 	Readlog(int) ([]string, error)
 
+	// SearchLog searches the entire log history for the process, including any
+	// log files that have been rotated, for lines matching the provided options.
+	SearchLog(ctx context.Context, opts LogSearchOptions) (LogSearchResult, error)
+
 	// Returns the current state of the environment.
 	State() string
 
@@ -0,0 +1,97 @@
+package environment
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/docker/docker/errdefs"
+)
+
+// ImagePullErrorKind describes the underlying cause of a failed image pull.
+type ImagePullErrorKind string
+
+const (
+	// ImagePullNotFound is returned when the image or tag does not exist in the
+	// registry, which is most often the result of a typo in the image reference.
+	ImagePullNotFound ImagePullErrorKind = "not_found"
+	// ImagePullUnauthorized is returned when the registry rejected the credentials
+	// used to pull the image.
+	ImagePullUnauthorized ImagePullErrorKind = "unauthorized"
+	// ImagePullUnreachable is returned when the registry could not be reached, or
+	// did not respond in time.
+	ImagePullUnreachable ImagePullErrorKind = "unreachable"
+	// ImagePullUnknown is returned for any error that could not be classified.
+	ImagePullUnknown ImagePullErrorKind = "unknown"
+)
+
+// ImagePullError is returned when an image could not be pulled for a server, and
+// classifies the failure so that a useful message can be shown to the user.
+type ImagePullError struct {
+	Image string
+	Kind  ImagePullErrorKind
+	err   error
+}
+
+// NewImagePullError classifies the error returned while pulling the given image.
+func NewImagePullError(image string, err error) *ImagePullError {
+	return &ImagePullError{Image: image, Kind: ClassifyImagePullError(err), err: err}
+}
+
+func (e *ImagePullError) Error() string {
+	return fmt.Sprintf("failed to pull image \"%s\" (%s): %s", e.Image, e.Kind, e.err)
+}
+
+func (e *ImagePullError) Unwrap() error {
+	return e.err
+}
+
+// Message returns an actionable, human-readable description of the failure that
+// is suitable to be displayed in the server console or installation output.
+func (e *ImagePullError) Message() string {
+	switch e.Kind {
+	case ImagePullNotFound:
+		return fmt.Sprintf("The image \"%s\" could not be found. Check that the image name and tag are correct, and that the configured registry credentials have access to it.", e.Image)
+	case ImagePullUnauthorized:
+		return fmt.Sprintf("The registry denied access to the image \"%s\". Check the registry credentials configured for this node.", e.Image)
+	case ImagePullUnreachable:
+		return fmt.Sprintf("The registry for the image \"%s\" could not be reached. This is usually a temporary network or registry outage, please try again later.", e.Image)
+	default:
+		return fmt.Sprintf("Failed to pull the image \"%s\": %s", e.Image, e.err)
+	}
+}
+
+// ClassifyImagePullError determines the cause of an image pull failure. Errors
+// that occur part way through a pull are only returned as text in the pull stream,
+// so the error message is inspected when the error type is not conclusive.
+func ClassifyImagePullError(err error) ImagePullErrorKind {
+	if err == nil {
+		return ImagePullUnknown
+	}
+	msg := strings.ToLower(err.Error())
+	var nerr net.Error
+	switch {
+	case errdefs.IsUnauthorized(err) || errdefs.IsForbidden(err):
+		return ImagePullUnauthorized
+	case errdefs.IsNotFound(err):
+		return ImagePullNotFound
+	case errdefs.IsUnavailable(err) || errdefs.IsDeadline(err) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &nerr):
+		return ImagePullUnreachable
+	// "pull access denied" is returned by Docker Hub for both missing images and
+	// private images, and is almost always the result of a mistyped image name.
+	case strings.Contains(msg, "manifest unknown") || strings.Contains(msg, "not found") ||
+		strings.Contains(msg, "repository does not exist") || strings.Contains(msg, "pull access denied"):
+		return ImagePullNotFound
+	case strings.Contains(msg, "unauthorized") || strings.Contains(msg, "authentication required") ||
+		strings.Contains(msg, "denied"):
+		return ImagePullUnauthorized
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "connection refused") ||
+		strings.Contains(msg, "no such host") || strings.Contains(msg, "connection reset") ||
+		strings.Contains(msg, "tls handshake") || strings.Contains(msg, "server misbehaving") ||
+		strings.Contains(msg, "service unavailable") || strings.Contains(msg, "bad gateway"):
+		return ImagePullUnreachable
+	}
+	return ImagePullUnknown
+}
@@ -0,0 +1,37 @@
+package environment
+
+import (
+	"time"
+)
+
+// LogSearchOptions controls which lines are returned when searching the log
+// history for a process.
+type LogSearchOptions struct {
+	// Match returns true if the provided line should be included in the results.
+	Match func(line string) bool
+	// Since and Until limit the search to lines logged within the time range. A
+	// zero value leaves that end of the range unbounded.
+	Since time.Time
+	Until time.Time
+	// MaxResults is the maximum number of matching lines that are returned. When
+	// more lines match, only the most recent ones are returned.
+	MaxResults int
+	// MaxScanBytes is the maximum amount of log data that is scanned before the
+	// search is stopped.
+	MaxScanBytes int64
+}
+
+// LogLine is a single line from the log history for a process.
+type LogLine struct {
+	Timestamp time.Time `json:"timestamp"`
+	Line      string    `json:"line"`
+}
+
+// LogSearchResult contains the lines that matched a log search.
+type LogSearchResult struct {
+	Lines []LogLine `json:"lines"`
+	// Truncated is true if the search was stopped before the end of the logs was
+	// reached because MaxScanBytes was exceeded, or if more lines matched than
+	// MaxResults allowed.
+	Truncated bool `json:"truncated"`
+}
@@ -3,10 +3,15 @@ package environment
 import (
 	"fmt"
 	"math"
+	"os"
+	"path/filepath"
 	"strconv"
+	"sync"
 
 	"github.com/apex/log"
+	"github.com/docker/docker/api/types/blkiodev"
 	"github.com/docker/docker/api/types/container"
+	"golang.org/x/sys/unix"
 
 	"github.com/pelican-dev/wings/config"
 )
@@ -45,6 +50,20 @@ type Limits struct {
 	// containers on the system and should be a value between 10 and 1000.
 	IoWeight uint16 `json:"io_weight"`
 
+	// The disk I/O limits for the container in bytes and operations per second. If
+	// not set the node defaults are used, and any value above the node maximum is
+	// lowered to it.
+	IoReadBps   uint64 `json:"io_read_bps"`
+	IoWriteBps  uint64 `json:"io_write_bps"`
+	IoReadIops  uint64 `json:"io_read_iops"`
+	IoWriteIops uint64 `json:"io_write_iops"`
+
+	// The network bandwidth limits for the container in megabits per second. If
+	// not set the node defaults are used, while a value of 0 exempts the server
+	// from the node defaults.
+	BandwidthIngress *uint64 `json:"bandwidth_ingress"`
+	BandwidthEgress  *uint64 `json:"bandwidth_egress"`
+
 	// The percentage of CPU that this instance is allowed to consume relative to
 	// the host. A value of 200% represents complete utilization of two cores. This
 	// should be a value between 1 and THREAD_COUNT * 100.
@@ -57,6 +76,10 @@ type Limits struct {
 	Threads string `json:"threads"`
 
 	OOMKiller bool `json:"oom_killer"`
+
+	// The OOM score adjustment for the container, between -1000 and 1000. If not set
+	// the node default is used.
+	OomScoreAdj *int `json:"oom_score_adj"`
 }
 
 // ConvertedCpuLimit converts the CPU limit for a server build into a number
@@ -99,6 +122,33 @@ func (l Limits) ProcessLimit() int64 {
 	return config.Get().Docker.ContainerPidLimit
 }
 
+// Bandwidth returns the ingress and egress bandwidth limits for the container in
+// megabits per second, falling back to the node defaults when they are not set. A
+// value of 0 means that no limit is applied.
+func (l Limits) Bandwidth() (ingress uint64, egress uint64) {
+	cfg := config.Get().Docker.Bandwidth
+	ingress, egress = cfg.Ingress, cfg.Egress
+	if l.BandwidthIngress != nil {
+		ingress = *l.BandwidthIngress
+	}
+	if l.BandwidthEgress != nil {
+		egress = *l.BandwidthEgress
+	}
+	return ingress, egress
+}
+
+// OomScoreAdjustment returns the OOM score adjustment to apply to the container. Values
+// outside the range supported by the kernel are ignored in favor of the node default.
+func (l Limits) OomScoreAdjustment() int {
+	if l.OomScoreAdj != nil {
+		if v := *l.OomScoreAdj; v >= -1000 && v <= 1000 {
+			return v
+		}
+		log.WithField("oom_score_adj", *l.OomScoreAdj).Warn("environment: server oom_score_adj is outside of the range -1000 to 1000, using the node default")
+	}
+	return min(max(config.Get().Docker.OomScoreAdj, -1000), 1000)
+}
+
 // Helper function to create a pointer to a boolean value
 func boolPtr(b bool) *bool {
 	return &b
@@ -133,9 +183,102 @@ func (l Limits) AsContainerResources() container.Resources {
 		resources.CpusetCpus = l.Threads
 	}
 
+	l.applyIoThrottle(&resources)
+
 	return resources
 }
 
+// IoThrottle returns the disk I/O limits for the container after applying the
+// node defaults and maximums to the values set for the server.
+func (l Limits) IoThrottle() config.IoLimits {
+	t := config.Get().Docker.IoThrottle
+	return config.IoLimits{
+		ReadBps:   boundedIoLimit(l.IoReadBps, t.Defaults.ReadBps, t.Maximum.ReadBps),
+		WriteBps:  boundedIoLimit(l.IoWriteBps, t.Defaults.WriteBps, t.Maximum.WriteBps),
+		ReadIops:  boundedIoLimit(l.IoReadIops, t.Defaults.ReadIops, t.Maximum.ReadIops),
+		WriteIops: boundedIoLimit(l.IoWriteIops, t.Defaults.WriteIops, t.Maximum.WriteIops),
+	}
+}
+
+// applyIoThrottle sets the blkio throttle limits on the container resources for
+// the block device storing the server data.
+func (l Limits) applyIoThrottle(resources *container.Resources) {
+	limits := l.IoThrottle()
+	if limits == (config.IoLimits{}) {
+		return
+	}
+	device, err := ResolveIoThrottleDevice()
+	if err != nil {
+		ioThrottleDevice.warn.Do(func() {
+			log.WithField("error", err).Warn("environment: failed to determine block device for I/O limits, not applying them")
+		})
+		return
+	}
+	throttle := func(rate uint64) []*blkiodev.ThrottleDevice {
+		if rate == 0 {
+			return nil
+		}
+		return []*blkiodev.ThrottleDevice{{Path: device, Rate: rate}}
+	}
+	resources.BlkioDeviceReadBps = throttle(limits.ReadBps)
+	resources.BlkioDeviceWriteBps = throttle(limits.WriteBps)
+	resources.BlkioDeviceReadIOps = throttle(limits.ReadIops)
+	resources.BlkioDeviceWriteIOps = throttle(limits.WriteIops)
+}
+
+func boundedIoLimit(v, def, maximum uint64) uint64 {
+	if v == 0 {
+		v = def
+	}
+	if maximum > 0 && (v == 0 || v > maximum) {
+		v = maximum
+	}
+	return v
+}
+
+// The block device that I/O limits are applied to, which is resolved once and
+// then reused for every container.
+var ioThrottleDevice struct {
+	once sync.Once
+	warn sync.Once
+	path string
+	err  error
+}
+
+// ResolveIoThrottleDevice returns the path of the block device that I/O limits
+// are applied to. The device is only looked up the first time this is called,
+// which should be when Wings boots, and the result is cached after that.
+func ResolveIoThrottleDevice() (string, error) {
+	ioThrottleDevice.once.Do(func() {
+		ioThrottleDevice.path, ioThrottleDevice.err = throttleDevice()
+	})
+	return ioThrottleDevice.path, ioThrottleDevice.err
+}
+
+// throttleDevice returns the path of the block device that I/O limits are
+// applied to. Unless a device is configured, this is the disk containing the
+// server data directory. Throttling is only supported on whole disks, so when
+// the data directory is on a partition the parent disk is used instead.
+func throttleDevice() (string, error) {
+	cfg := config.Get()
+	if cfg.Docker.IoThrottle.Device != "" {
+		return cfg.Docker.IoThrottle.Device, nil
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(cfg.System.Data, &st); err != nil {
+		return "", err
+	}
+	sys := fmt.Sprintf("/sys/dev/block/%d:%d", unix.Major(st.Dev), unix.Minor(st.Dev))
+	p, err := filepath.EvalSymlinks(sys)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Join(p, "partition")); err == nil {
+		p = filepath.Dir(p)
+	}
+	return "/dev/" + filepath.Base(p), nil
+}
+
 type Variables map[string]interface{}
 
 // Get is an ugly hacky function to handle environment variables that get passed
@@ -17,6 +17,11 @@ type Stats struct {
 	// does not take into account any limits on the server process itself.
 	CpuAbsolute float64 `json:"cpu_absolute"`
 
+	// The number of processes running in the container, and the maximum number of
+	// processes that may run in it. A limit of 0 means there is no limit.
+	Pids      uint64 `json:"pids"`
+	PidsLimit uint64 `json:"pids_limit"`
+
 	// Current network transmit in & out for a container.
 	Network NetworkStats `json:"network"`
 
@@ -3,9 +3,14 @@ package cron
 import (
 	"context"
 	"net"
+	"reflect"
+	"sort"
+	"time"
 
 	"emperror.dev/errors"
+	"gorm.io/gorm"
 
+	"github.com/pelican-dev/wings/config"
 	"github.com/pelican-dev/wings/internal/database"
 	"github.com/pelican-dev/wings/internal/models"
 	"github.com/pelican-dev/wings/server"
@@ -31,12 +36,14 @@ func (ac *activityCron) Run(ctx context.Context) error {
 	defer ac.mu.Store(false)
 
 	var activity []models.Activity
-	tx := database.Instance().WithContext(ctx).
-		Where("event NOT LIKE ?", "server:sftp.%").
-		Limit(ac.max).
-		Find(&activity)
-	if tx.Error != nil {
-		return errors.WithStack(tx.Error)
+	err := database.WithRetry(ctx, func() error {
+		return database.Instance().WithContext(ctx).
+			Where("event NOT LIKE ?", "server:sftp.%").
+			Limit(ac.max).
+			Find(&activity).Error
+	})
+	if err != nil {
+		return errors.WithStack(err)
 	}
 	if len(activity) == 0 {
 		return nil
@@ -57,43 +64,98 @@ func (ac *activityCron) Run(ctx context.Context) error {
 	}
 
 	// Delete any invalid activies
-	if len(ids) > 0 {
-		tx = database.Instance().WithContext(ctx).Where("id IN ?", ids).Delete(&models.Activity{})
-		if tx.Error != nil {
-			return errors.WithStack(tx.Error)
-		}
+	if err := deleteActivities(ctx, ids); err != nil {
+		return err
 	}
 
 	if len(activities) == 0 {
 		return nil
 	}
 
-	if err := ac.manager.Client().SendActivityLogs(ctx, activities); err != nil {
-		return errors.WrapIf(err, "cron: failed to send activity events to Panel")
-	}
-
 	ids = make([]int, len(activities))
 	for i, v := range activities {
 		ids[i] = v.ID
 	}
 
-	// SQLite has a limitation of how many parameters we can specify in a single
-	// query, so we need to delete the activies in chunks of 32,000 instead of
-	// all at once.
-	i := 0
-	idsLen := len(ids)
-	for i < idsLen {
-		start := i
-		end := min(i+32000, idsLen)
-		batchSize := end - start
-
-		tx = database.Instance().WithContext(ctx).Where("id IN ?", ids[start:end]).Delete(&models.Activity{})
-		if tx.Error != nil {
-			return errors.WithStack(tx.Error)
-		}
+	window := time.Duration(config.Get().System.ActivityCoalesceWindow) * time.Second
+	if err := ac.manager.Client().SendActivityLogs(ctx, coalesceActivities(activities, window)); err != nil {
+		return errors.WrapIf(err, "cron: failed to send activity events to Panel")
+	}
+
+	return deleteActivities(ctx, ids)
+}
+
+// The metadata key used to store the number of activities that were collapsed
+// into a single activity. This is prefixed so that it does not overwrite any
+// "count" already present in the metadata of an event.
+const coalescedCountKey = "coalesced_count"
+
+type coalesceKey struct {
+	User   string
+	Server string
+	Event  models.Event
+}
 
-		i += batchSize
+// coalesceActivities collapses repeated activities for the same event, server,
+// and user that have identical metadata and occur within the given window of
+// the first one into a single activity. The number of activities that were
+// collapsed is stored in the metadata of the activity using coalescedCountKey.
+func coalesceActivities(activities []models.Activity, window time.Duration) []models.Activity {
+	if window <= 0 {
+		return activities
 	}
+	sort.SliceStable(activities, func(i, j int) bool {
+		return activities[i].Timestamp.Before(activities[j].Timestamp)
+	})
 
-	return nil
+	out := make([]models.Activity, 0, len(activities))
+	counts := make([]int, 0, len(activities))
+	last := make(map[coalesceKey]int)
+	for _, a := range activities {
+		key := coalesceKey{User: a.User.String, Server: a.Server, Event: a.Event}
+		if i, ok := last[key]; ok && a.Timestamp.Sub(out[i].Timestamp) <= window && reflect.DeepEqual(a.Metadata, out[i].Metadata) {
+			counts[i]++
+			continue
+		}
+		last[key] = len(out)
+		out = append(out, a)
+		counts = append(counts, 1)
+	}
+	for i, c := range counts {
+		if c == 1 {
+			continue
+		}
+		meta := make(models.ActivityMeta, len(out[i].Metadata)+1)
+		for k, v := range out[i].Metadata {
+			meta[k] = v
+		}
+		meta[coalescedCountKey] = c
+		out[i].Metadata = meta
+	}
+	return out
+}
+
+// deleteActivities deletes the activities with the given IDs from the database.
+// The deletions are performed in a single transaction which is retried if the
+// database is busy, rather than leaving activities behind that would then be
+// sent to the Panel again.
+func deleteActivities(ctx context.Context, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	err := database.WithRetry(ctx, func() error {
+		return database.Instance().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// SQLite has a limitation of how many parameters we can specify in a single
+			// query, so we need to delete the activies in chunks of 32,000 instead of
+			// all at once.
+			for start := 0; start < len(ids); start += 32000 {
+				end := min(start+32000, len(ids))
+				if err := tx.Where("id IN ?", ids[start:end]).Delete(&models.Activity{}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	return errors.WithStack(err)
 }
@@ -0,0 +1,99 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+
+	"github.com/pelican-dev/wings/internal/models"
+)
+
+func TestCoalesceActivities(t *testing.T) {
+	g := Goblin(t)
+
+	now := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	activity := func(server string, event models.Event, offset time.Duration, meta models.ActivityMeta) models.Activity {
+		return models.Activity{Server: server, Event: event, Timestamp: now.Add(offset), Metadata: meta}
+	}
+
+	cases := []struct {
+		name     string
+		window   time.Duration
+		in       []models.Activity
+		expected []models.ActivityMeta
+	}{
+		{
+			name:   "does not coalesce when the window is disabled",
+			window: 0,
+			in: []models.Activity{
+				activity("a", "server:console.command", 0, models.ActivityMeta{"command": "say"}),
+				activity("a", "server:console.command", time.Second, models.ActivityMeta{"command": "say"}),
+			},
+			expected: []models.ActivityMeta{{"command": "say"}, {"command": "say"}},
+		},
+		{
+			name:   "coalesces identical activities within the window",
+			window: time.Second * 10,
+			in: []models.Activity{
+				activity("a", "server:console.command", 0, models.ActivityMeta{"command": "say"}),
+				activity("a", "server:console.command", time.Second, models.ActivityMeta{"command": "say"}),
+				activity("a", "server:console.command", time.Second*2, models.ActivityMeta{"command": "say"}),
+			},
+			expected: []models.ActivityMeta{{"command": "say", coalescedCountKey: 3}},
+		},
+		{
+			name:   "keeps activities outside of the window separate",
+			window: time.Second * 10,
+			in: []models.Activity{
+				activity("a", "server:console.command", 0, models.ActivityMeta{"command": "say"}),
+				activity("a", "server:console.command", time.Second*11, models.ActivityMeta{"command": "say"}),
+			},
+			expected: []models.ActivityMeta{{"command": "say"}, {"command": "say"}},
+		},
+		{
+			name:   "keeps activities with different metadata, events or servers separate",
+			window: time.Second * 10,
+			in: []models.Activity{
+				activity("a", "server:console.command", 0, models.ActivityMeta{"command": "say"}),
+				activity("a", "server:console.command", time.Second, models.ActivityMeta{"command": "stop"}),
+				activity("a", "server:power.start", time.Second*2, models.ActivityMeta{"command": "say"}),
+				activity("b", "server:console.command", time.Second*3, models.ActivityMeta{"command": "say"}),
+			},
+			expected: []models.ActivityMeta{{"command": "say"}, {"command": "stop"}, {"command": "say"}, {"command": "say"}},
+		},
+		{
+			name:   "does not overwrite an existing count in the metadata",
+			window: time.Second * 10,
+			in: []models.Activity{
+				activity("a", "server:file.delete", 0, models.ActivityMeta{"count": 5}),
+				activity("a", "server:file.delete", time.Second, models.ActivityMeta{"count": 5}),
+			},
+			expected: []models.ActivityMeta{{"count": 5, coalescedCountKey: 2}},
+		},
+	}
+
+	g.Describe("coalesceActivities", func() {
+		for _, tc := range cases {
+			g.It(tc.name, func() {
+				out := coalesceActivities(tc.in, tc.window)
+
+				g.Assert(len(out)).Equal(len(tc.expected))
+				for i, a := range out {
+					g.Assert(a.Metadata).Equal(tc.expected[i])
+				}
+			})
+		}
+
+		g.It("does not modify the metadata of the original activity", func() {
+			meta := models.ActivityMeta{"command": "say"}
+			in := []models.Activity{
+				activity("a", "server:console.command", 0, meta),
+				activity("a", "server:console.command", time.Second, meta),
+			}
+
+			coalesceActivities(in, time.Second*10)
+			g.Assert(meta).Equal(models.ActivityMeta{"command": "say"})
+		})
+	})
+}
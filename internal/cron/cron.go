@@ -49,6 +49,11 @@ func Scheduler(ctx context.Context, m *server.Manager) (gocron.Scheduler, error)
 		max:     config.Get().System.ActivitySendCount,
 	}
 
+	power := powerCron{
+		mu:      system.NewAtomicBool(false),
+		manager: m,
+	}
+
 	l := log.WithField("subsystem", "cron")
 
 	interval := time.Duration(config.Get().System.ActivitySendInterval) * time.Second
@@ -90,5 +95,36 @@ func Scheduler(ctx context.Context, m *server.Manager) (gocron.Scheduler, error)
 		return nil, errors.Wrap(err, "cron: failed to create sftp job")
 	}
 
+	// Scheduled power action job
+	_, err = s.NewJob(
+		gocron.DurationJob(5*time.Second),
+		gocron.NewTask(func() {
+			if err := power.Run(ctx); err != nil && !errors.Is(err, ErrCronRunning) {
+				l.WithField("cron", "power").WithField("error", err).Error("scheduled power action process failed to execute")
+			}
+		}),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "cron: failed to create scheduled power action job")
+	}
+
+	// Container reconciliation job
+	if v := config.Get().System.ContainerReconcileInterval; v > 0 {
+		_, err = s.NewJob(
+			gocron.DurationJob(time.Duration(v)*time.Second),
+			gocron.NewTask(func() {
+				l.WithField("cron", "reconcile").Debug("reconciling server containers")
+				if err := m.ReconcileContainers(ctx); err != nil {
+					l.WithField("cron", "reconcile").WithField("error", err).Error("container reconciliation process failed to execute")
+				}
+			}),
+			gocron.WithStartAt(gocron.WithStartImmediately()),
+			gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "cron: failed to create container reconciliation job")
+		}
+	}
+
 	return s, nil
 }
@@ -0,0 +1,89 @@
+package cron
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pelican-dev/wings/internal/database"
+	"github.com/pelican-dev/wings/internal/models"
+	"github.com/pelican-dev/wings/server"
+	"github.com/pelican-dev/wings/system"
+)
+
+// staleScheduledPowerAction is the amount of time after which a scheduled power action
+// that was missed (for example because Wings was not running) is discarded rather than
+// executed.
+const staleScheduledPowerAction = 10 * time.Minute
+
+type powerCron struct {
+	mu      *system.AtomicBool
+	manager *server.Manager
+}
+
+// Run executes any scheduled power actions that have become due. Each action is removed
+// from the database before it is executed so that it is only ever attempted once, even
+// if the action itself fails.
+func (pc *powerCron) Run(ctx context.Context) error {
+	if !pc.mu.SwapIf(true) {
+		return errors.WithStack(ErrCronRunning)
+	}
+	defer pc.mu.Store(false)
+
+	now := time.Now().UTC()
+	var actions []models.ScheduledPowerAction
+	err := database.WithRetry(ctx, func() error {
+		return database.Instance().WithContext(ctx).
+			Where("run_at <= ?", now).
+			Order("run_at ASC").
+			Find(&actions).Error
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(actions))
+	for i, a := range actions {
+		ids[i] = a.ID
+	}
+	err = database.WithRetry(ctx, func() error {
+		return database.Instance().WithContext(ctx).Delete(&models.ScheduledPowerAction{}, ids).Error
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, a := range actions {
+		l := log.WithFields(log.Fields{"cron": "power", "server": a.Server, "action": a.Action, "run_at": a.RunAt})
+		if now.Sub(a.RunAt) > staleScheduledPowerAction {
+			l.Warn("discarding scheduled power action that was missed by more than the allowed window")
+			continue
+		}
+		s, ok := pc.manager.Get(a.Server)
+		if !ok {
+			l.Debug("discarding scheduled power action for server that no longer exists")
+			continue
+		}
+		action := server.PowerAction(a.Action)
+		if !action.IsValid() {
+			l.Warn("discarding scheduled power action with an invalid action")
+			continue
+		}
+		if action.IsStart() && (s.IsSuspended() || server.IsDraining()) {
+			l.Info("skipping scheduled power action, server is suspended or the node is draining")
+			continue
+		}
+		go func(s *server.Server, a models.ScheduledPowerAction) {
+			l.Info("executing scheduled power action")
+			if err := s.HandlePowerAction(action, a.WaitSeconds); err != nil && !errors.Is(err, server.ErrIsRunning) {
+				l.WithField("error", err).Error("failed to execute scheduled power action")
+			}
+		}(s, a)
+	}
+	return nil
+}
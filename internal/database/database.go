@@ -1,7 +1,12 @@
 package database
 
 import (
+	"context"
+	"net/url"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"emperror.dev/errors"
@@ -25,8 +30,25 @@ func Initialize() error {
 	if !o.SwapIf(true) {
 		panic("database: attempt to initialize more than once during application lifecycle")
 	}
-	p := filepath.Join(config.Get().System.RootDirectory, "wings.db")
-	instance, err := gorm.Open(sqlite.Open(p), &gorm.Config{
+	cfg := config.Get().System.Database
+	switch strings.ToLower(cfg.JournalMode) {
+	case "delete", "truncate", "persist", "memory", "wal", "off":
+	default:
+		return errors.New("database: invalid journal mode: " + cfg.JournalMode)
+	}
+	p := config.Get().System.GetDatabasePath()
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return errors.Wrap(err, "database: could not create database directory")
+	}
+	// The pragmas are set in the DSN so that they are applied to every connection
+	// opened by the pool, rather than only to whichever connection happened to run
+	// them, which would then lose them when it is recycled.
+	pragmas := url.Values{"_pragma": {
+		"busy_timeout(" + strconv.Itoa(cfg.BusyTimeout) + ")",
+		"synchronous(OFF)",
+		"journal_mode(" + cfg.JournalMode + ")",
+	}}
+	instance, err := gorm.Open(sqlite.Open(p+"?"+pragmas.Encode()), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	if err != nil {
@@ -39,18 +61,36 @@ func Initialize() error {
 		sql.SetMaxOpenConns(1)
 		sql.SetConnMaxLifetime(time.Hour)
 	}
-	if tx := db.Exec("PRAGMA synchronous = OFF"); tx.Error != nil {
-		return errors.WithStack(tx.Error)
-	}
-	if tx := db.Exec("PRAGMA journal_mode = MEMORY"); tx.Error != nil {
-		return errors.WithStack(tx.Error)
-	}
-	if err := db.AutoMigrate(&models.Activity{}); err != nil {
+	if err := db.AutoMigrate(&models.Activity{}, &models.ScheduledPowerAction{}, &models.ServerHistory{}, &models.ProjectQuota{}); err != nil {
 		return errors.WithStack(err)
 	}
 	return nil
 }
 
+// OpenReadOnly opens the SQLite database at the given path in read-only mode
+// without running any migrations. This is used by commands that inspect the
+// database of a Wings instance that may currently be running, and must not
+// modify it.
+func OpenReadOnly(p string) (*gorm.DB, error) {
+	q := url.Values{
+		"mode":    {"ro"},
+		"_pragma": {"busy_timeout(" + strconv.Itoa(config.Get().System.Database.BusyTimeout) + ")"},
+	}
+	instance, err := gorm.Open(sqlite.Open("file:"+p+"?"+q.Encode()), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "database: could not open database file")
+	}
+	return instance, nil
+}
+
+// Initialized returns true if the database has been initialized for the running
+// instance of Wings.
+func Initialized() bool {
+	return db != nil
+}
+
 // Instance returns the gorm database instance that was configured when the application was
 // booted.
 func Instance() *gorm.DB {
@@ -59,3 +99,16 @@ func Instance() *gorm.DB {
 	}
 	return db
 }
+
+// Ping checks that the database has been initialized and that the underlying
+// connection is still usable.
+func Ping(ctx context.Context) error {
+	if db == nil {
+		return errors.New("database: not initialized")
+	}
+	sql, err := db.DB()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(sql.PingContext(ctx))
+}
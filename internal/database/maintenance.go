@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"emperror.dev/errors"
+
+	"github.com/pelican-dev/wings/config"
+	"github.com/pelican-dev/wings/internal/models"
+)
+
+// ActivityStats describes the backlog of activity that is stored in the local
+// database waiting to be sent to the Panel.
+type ActivityStats struct {
+	Count     int64      `json:"count"`
+	Oldest    *time.Time `json:"oldest"`
+	SizeBytes int64      `json:"size_bytes"`
+}
+
+// GetActivityStats returns the number of activities waiting to be sent to the
+// Panel, the timestamp of the oldest one, and the size of the database on disk.
+func GetActivityStats(ctx context.Context) (ActivityStats, error) {
+	var stats ActivityStats
+	if err := Instance().WithContext(ctx).Model(&models.Activity{}).Count(&stats.Count).Error; err != nil {
+		return stats, errors.WithStack(err)
+	}
+	if stats.Count > 0 {
+		var oldest models.Activity
+		if err := Instance().WithContext(ctx).Order("timestamp ASC").First(&oldest).Error; err != nil {
+			return stats, errors.WithStack(err)
+		}
+		stats.Oldest = &oldest.Timestamp
+	}
+	p := config.Get().System.GetDatabasePath()
+	// Include the write-ahead log, if there is one, since it can be considerably
+	// larger than the database itself.
+	for _, f := range []string{p, p + "-wal"} {
+		if st, err := os.Stat(f); err == nil {
+			stats.SizeBytes += st.Size()
+		}
+	}
+	return stats, nil
+}
+
+// PurgeActivity deletes all activities that occurred before the given time from
+// the database, returning the number of activities that were deleted.
+func PurgeActivity(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	err := WithRetry(ctx, func() error {
+		tx := Instance().WithContext(ctx).Where("timestamp < ?", before.UTC()).Delete(&models.Activity{})
+		deleted = tx.RowsAffected
+		return tx.Error
+	})
+	return deleted, errors.WithStack(err)
+}
+
+// Vacuum rebuilds the database file to reclaim the space left behind by
+// activities that have been deleted.
+func Vacuum(ctx context.Context) error {
+	return errors.WithStack(WithRetry(ctx, func() error {
+		return Instance().WithContext(ctx).Exec("VACUUM").Error
+	}))
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+	sqlite3 "github.com/glebarez/go-sqlite"
+)
+
+// The primary result codes returned by SQLite when the database file, or a
+// table within it, is locked by another connection.
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+// The number of times an operation is attempted when the database is busy, and
+// the delay before the first retry. The delay is doubled after each attempt.
+const (
+	busyRetryAttempts = 5
+	busyRetryDelay    = time.Millisecond * 100
+)
+
+// IsBusy returns true if the error was returned by SQLite because the database
+// was busy or locked by another connection.
+func IsBusy(err error) bool {
+	var e *sqlite3.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	// Extended result codes store the primary result code in the lower 8 bits.
+	code := e.Code() & 0xff
+	return code == sqliteBusy || code == sqliteLocked
+}
+
+// WithRetry executes the provided function, retrying it with an exponential
+// backoff if it fails because the database is busy. Any other error is returned
+// immediately.
+func WithRetry(ctx context.Context, fn func() error) error {
+	delay := busyRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !IsBusy(err) || attempt >= busyRetryAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
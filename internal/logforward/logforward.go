@@ -0,0 +1,161 @@
+// Package logforward sends the console output of servers to an external logging
+// service, such as syslog or Loki, as it is produced.
+package logforward
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pelican-dev/wings/config"
+)
+
+// The maximum amount of time spent sending a single batch of lines before it is
+// abandoned.
+const sendTimeout = time.Second * 10
+
+var forwarder atomic.Pointer[Forwarder]
+
+// Line is a single line of console output from a server.
+type Line struct {
+	Server    string    `json:"server"`
+	Timestamp time.Time `json:"timestamp"`
+	Line      string    `json:"line"`
+}
+
+// Sink is an external service that batches of console output are sent to.
+type Sink interface {
+	Send(ctx context.Context, lines []Line) error
+	Close() error
+}
+
+// Forwarder buffers console output and sends it to a sink in batches. Lines are
+// dropped rather than blocking the caller when the buffer is full, so a slow or
+// unavailable sink never holds up the processing of console output.
+type Forwarder struct {
+	sink      Sink
+	lines     chan Line
+	batchSize int
+	interval  time.Duration
+	dropped   atomic.Uint64
+}
+
+// Initialize configures console forwarding using the node configuration and
+// starts sending lines in the background until the context is canceled. This
+// is a no-op if console forwarding is not enabled.
+func Initialize(ctx context.Context) error {
+	cfg := config.Get().System.ConsoleForwarding
+	if cfg.Type == "" {
+		return nil
+	}
+	sink, err := newSink(cfg)
+	if err != nil {
+		return err
+	}
+	f := New(sink, cfg.BufferSize, cfg.BatchSize, time.Duration(cfg.FlushInterval)*time.Millisecond)
+	forwarder.Store(f)
+	go f.Run(ctx)
+	return nil
+}
+
+// Push queues a line of console output for the given server to be forwarded if
+// console forwarding is enabled.
+func Push(server string, line []byte) {
+	if f := forwarder.Load(); f != nil {
+		f.Push(server, line)
+	}
+}
+
+// New returns a forwarder that sends lines to the given sink. Lines are sent
+// once "batchSize" lines are waiting, or after the interval has elapsed.
+func New(sink Sink, bufferSize, batchSize int, interval time.Duration) *Forwarder {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &Forwarder{
+		sink:      sink,
+		lines:     make(chan Line, max(bufferSize, 1)),
+		batchSize: max(batchSize, 1),
+		interval:  interval,
+	}
+}
+
+// Push queues a line of console output for the given server, dropping it if
+// the buffer is full.
+func (f *Forwarder) Push(server string, line []byte) {
+	l := Line{
+		Server:    server,
+		Timestamp: time.Now(),
+		Line:      strings.TrimRight(string(line), "\r\n"),
+	}
+	select {
+	case f.lines <- l:
+	default:
+		f.dropped.Add(1)
+	}
+}
+
+// Run sends batches of lines to the sink until the context is canceled, at
+// which point any remaining lines are sent and the sink is closed.
+func (f *Forwarder) Run(ctx context.Context) {
+	t := time.NewTicker(f.interval)
+	defer t.Stop()
+
+	batch := make([]Line, 0, f.batchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			for len(f.lines) > 0 && len(batch) < f.batchSize {
+				batch = append(batch, <-f.lines)
+			}
+			f.flush(batch)
+			if err := f.sink.Close(); err != nil {
+				log.WithField("error", err).Warn("logforward: failed to close console forwarding sink")
+			}
+			return
+		case l := <-f.lines:
+			batch = append(batch, l)
+			if len(batch) < f.batchSize {
+				continue
+			}
+		case <-t.C:
+		}
+		f.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+// flush sends the batch of lines to the sink, logging any failure. Lines that
+// fail to send are discarded so that the buffer does not grow without bound
+// while the sink is unavailable.
+func (f *Forwarder) flush(batch []Line) {
+	if n := f.dropped.Swap(0); n > 0 {
+		log.WithField("dropped", n).Warn("logforward: dropped console output because the forwarding buffer is full")
+	}
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	if err := f.sink.Send(ctx, batch); err != nil {
+		log.WithFields(log.Fields{"lines": len(batch), "error": err}).Warn("logforward: failed to send console output")
+	}
+}
+
+// newSink returns the sink for the configured console forwarding type.
+func newSink(cfg config.ConsoleForwarding) (Sink, error) {
+	switch strings.ToLower(cfg.Type) {
+	case "syslog":
+		return newSyslogSink(cfg.Address)
+	case "http":
+		return newHTTPSink(cfg.Address, cfg.Headers, encodeJSON)
+	case "loki":
+		return newHTTPSink(cfg.Address, cfg.Headers, encodeLoki)
+	default:
+		return nil, errors.New("logforward: unknown console forwarding type: " + cfg.Type)
+	}
+}
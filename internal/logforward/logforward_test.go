@@ -0,0 +1,83 @@
+package logforward_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+
+	"github.com/pelican-dev/wings/internal/logforward"
+)
+
+type testSink struct {
+	mu      sync.Mutex
+	batches [][]logforward.Line
+	closed  bool
+}
+
+func (s *testSink) Send(_ context.Context, lines []logforward.Line) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]logforward.Line{}, lines...))
+	return nil
+}
+
+func (s *testSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *testSink) Batches() [][]logforward.Line {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.batches
+}
+
+func TestForwarder(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	g.Describe("Forwarder", func() {
+		g.It("sends full batches of lines", func() {
+			sink := &testSink{}
+			f := logforward.New(sink, 10, 2, time.Hour)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go f.Run(ctx)
+
+			f.Push("a", []byte("first\n"))
+			f.Push("b", []byte("second"))
+
+			for i := 0; i < 100 && len(sink.Batches()) == 0; i++ {
+				time.Sleep(time.Millisecond * 10)
+			}
+			batches := sink.Batches()
+			g.Assert(len(batches)).Equal(1)
+			g.Assert(len(batches[0])).Equal(2)
+			g.Assert(batches[0][0].Server).Equal("a")
+			g.Assert(batches[0][0].Line).Equal("first")
+			g.Assert(batches[0][1].Line).Equal("second")
+		})
+
+		g.It("drops lines when the buffer is full", func() {
+			sink := &testSink{}
+			f := logforward.New(sink, 2, 10, time.Hour)
+
+			f.Push("a", []byte("one"))
+			f.Push("a", []byte("two"))
+			f.Push("a", []byte("three"))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			f.Run(ctx)
+
+			batches := sink.Batches()
+			g.Assert(len(batches)).Equal(1)
+			g.Assert(len(batches[0])).Equal(2)
+			g.Assert(sink.closed).IsTrue()
+		})
+	})
+}
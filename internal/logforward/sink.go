@@ -0,0 +1,130 @@
+package logforward
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"emperror.dev/errors"
+	"github.com/goccy/go-json"
+)
+
+// syslogSink writes each line to a syslog daemon, prefixed with the UUID of the
+// server it came from.
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+// newSyslogSink connects to the syslog daemon at the given address, such as
+// "udp://logs.example.com:514". An empty address uses the local syslog daemon.
+func newSyslogSink(address string) (*syslogSink, error) {
+	var network, raddr string
+	if address != "" {
+		u, err := url.Parse(address)
+		if err != nil {
+			return nil, errors.Wrap(err, "logforward: invalid syslog address")
+		}
+		network, raddr = u.Scheme, u.Host
+	}
+	w, err := syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_DAEMON, "wings")
+	if err != nil {
+		return nil, errors.Wrap(err, "logforward: failed to connect to syslog")
+	}
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) Send(_ context.Context, lines []Line) error {
+	for _, l := range lines {
+		if err := s.w.Info(fmt.Sprintf("server=%s %s", l.Server, l.Line)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
+
+// httpSink sends each batch of lines as a single POST request to an endpoint,
+// using the encoder to build the request body.
+type httpSink struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+	encode  func(lines []Line) ([]byte, error)
+}
+
+func newHTTPSink(address string, headers map[string]string, encode func([]Line) ([]byte, error)) (*httpSink, error) {
+	if _, err := url.ParseRequestURI(address); err != nil {
+		return nil, errors.Wrap(err, "logforward: invalid http address")
+	}
+	return &httpSink{
+		client:  &http.Client{Timeout: sendTimeout},
+		url:     address,
+		headers: headers,
+		encode:  encode,
+	}, nil
+}
+
+func (s *httpSink) Send(ctx context.Context, lines []Line) error {
+	b, err := s.encode(lines)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Pelican Wings")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.New("logforward: endpoint responded with status " + res.Status)
+	}
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// encodeJSON encodes the lines as a JSON array of objects.
+func encodeJSON(lines []Line) ([]byte, error) {
+	return json.Marshal(lines)
+}
+
+// encodeLoki encodes the lines using the Loki push API format, with a stream
+// for each server that has lines in the batch.
+func encodeLoki(lines []Line) ([]byte, error) {
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	var streams []*stream
+	byServer := make(map[string]*stream)
+	for _, l := range lines {
+		s, ok := byServer[l.Server]
+		if !ok {
+			s = &stream{Stream: map[string]string{"job": "wings", "server": l.Server}}
+			byServer[l.Server] = s
+			streams = append(streams, s)
+		}
+		s.Values = append(s.Values, [2]string{strconv.FormatInt(l.Timestamp.UnixNano(), 10), l.Line})
+	}
+	return json.Marshal(map[string][]*stream{"streams": streams})
+}
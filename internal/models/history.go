@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ServerHistory is a locally retained record of a notable event for a server, such as a
+// crash or a power action. Unlike Activity entries these are not sent to the Panel and
+// deleted, instead only the most recent events for each server are kept so that they can
+// be included in diagnostics reports.
+type ServerHistory struct {
+	ID int `gorm:"primaryKey;not null" json:"-"`
+	// Server is the UUID of the server this event is associated with.
+	Server string `gorm:"type:uuid;index;not null" json:"server"`
+	// Event is the type of event that occurred, using the same naming as activity events.
+	Event Event `gorm:"not null" json:"event"`
+	// Metadata is any additional event specific data. This should never contain console
+	// output or other potentially sensitive information.
+	Metadata  ActivityMeta `gorm:"serializer:json" json:"metadata"`
+	Timestamp time.Time    `gorm:"index;not null" json:"timestamp"`
+}
+
+// BeforeCreate ensures the timestamp is set and stored as UTC.
+func (h *ServerHistory) BeforeCreate(_ *gorm.DB) error {
+	if h.Timestamp.IsZero() {
+		h.Timestamp = time.Now()
+	}
+	h.Timestamp = h.Timestamp.UTC()
+	if h.Metadata == nil {
+		h.Metadata = ActivityMeta{}
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package models
+
+// ProjectQuota is the filesystem project ID that has been assigned to the data
+// directory of a server when project quotas are enabled. IDs are stored so that
+// each server keeps the same ID between restarts, and no two servers on the node
+// are ever given the same ID.
+type ProjectQuota struct {
+	// Server is the UUID of the server the project ID is assigned to.
+	Server string `gorm:"type:uuid;primaryKey;not null" json:"server"`
+	// ProjectID is the project ID applied to the server's data directory.
+	ProjectID uint32 `gorm:"uniqueIndex;not null" json:"project_id"`
+}
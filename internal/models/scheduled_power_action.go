@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ScheduledPowerAction is a one-shot power action for a server that has been registered
+// to run at a later point in time. These are stored locally so that the action is still
+// executed even if the Panel cannot be reached when it becomes due.
+type ScheduledPowerAction struct {
+	ID int `gorm:"primaryKey;not null" json:"id"`
+	// Server is the UUID of the server the power action should be sent to.
+	Server string `gorm:"type:uuid;index;not null" json:"server"`
+	// Action is the power action to execute, one of "start", "stop", "restart" or "kill".
+	Action string `gorm:"not null" json:"action"`
+	// WaitSeconds is the amount of time to wait for a power lock to be released before
+	// giving up on the action.
+	WaitSeconds int `gorm:"not null" json:"wait_seconds"`
+	// RunAt is the time at which the action should be executed.
+	RunAt     time.Time `gorm:"index;not null" json:"run_at"`
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+}
+
+// BeforeCreate ensures that all timestamps are stored as UTC.
+func (a *ScheduledPowerAction) BeforeCreate(_ *gorm.DB) error {
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+	a.CreatedAt = a.CreatedAt.UTC()
+	a.RunAt = a.RunAt.UTC()
+	return nil
+}
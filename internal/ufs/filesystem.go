@@ -50,7 +50,8 @@ type Filesystem interface {
 	Lchown(name string, uid, gid int) error
 
 	// Chtimes changes the access and modification times of the named
-	// file, similar to the Unix utime() or utimes() functions.
+	// file, similar to the Unix utime() or utimes() functions. Symbolic
+	// links are not followed.
 	//
 	// The underlying filesystem may truncate or round the values to a
 	// less precise time unit.
@@ -124,9 +125,8 @@ type Filesystem interface {
 
 	// Rename renames (moves) oldpath to newpath.
 	//
-	// If newpath already exists and is not a directory, Rename replaces it.
+	// If newpath already exists, Rename returns ErrExist rather than replacing it.
 	// OS-specific restrictions may apply when oldpath and newpath are in different directories.
-	// Even within the same directory, on non-Unix platforms Rename is not an atomic operation.
 	//
 	// If there is an error, it will be of type *LinkError.
 	Rename(oldname, newname string) error
@@ -105,6 +105,37 @@ func (fs *Quota) CanFit(size int64) bool {
 	return false
 }
 
+// Reserve adds the given size to the tracked usage total if it can fit in the
+// filesystem without exceeding the limit, returning false if it cannot. Unlike
+// calling CanFit followed by Add, the check and the update are performed as a
+// single atomic operation, so concurrent writers cannot exceed the limit.
+//
+// If the usage has not been calculated yet nothing can be reserved, so true is
+// returned without updating the usage, in the same way that CanFit allows any
+// size while the usage is unknown.
+func (fs *Quota) Reserve(size int64) bool {
+	for {
+		limit := fs.Limit()
+		if limit == -1 {
+			return false
+		}
+		usage := fs.Usage()
+		if usage == -1 {
+			return true
+		}
+		if limit != 0 && usage+size > limit {
+			return false
+		}
+		next := usage + size
+		if next < 0 {
+			next = 0
+		}
+		if fs.usage.CompareAndSwap(usage, next) {
+			return true
+		}
+	}
+}
+
 func (fs *Quota) Remove(name string) error {
 	// For information on why this interface is used here, check its
 	// documentation.
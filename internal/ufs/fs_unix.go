@@ -84,6 +84,18 @@ func (fs *UnixFS) Chmod(name string, mode FileMode) error {
 	return convertErrorType(unix.Fchmodat(dirfd, name, uint32(mode), 0))
 }
 
+// Lchmodat is like Chmod but allows passing an existing directory file
+// descriptor rather than needing to resolve one. Unlike Chmod, this will never
+// follow a symlink, and an error will be returned if name refers to one.
+func (fs *UnixFS) Lchmodat(dirfd int, name string, mode FileMode) error {
+	fd, err := unix.Openat(dirfd, name, unix.O_RDONLY|unix.O_NOFOLLOW|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return convertErrorType(&PathError{Op: "lchmodat", Path: name, Err: err})
+	}
+	defer unix.Close(fd)
+	return convertErrorType(unix.Fchmod(fd, uint32(mode)))
+}
+
 // Chown changes the numeric uid and gid of the named file.
 //
 // If the file is a symbolic link, it changes the uid and gid of the link's target.
@@ -132,7 +144,9 @@ func (fs *UnixFS) Lchownat(dirfd int, name string, uid, gid int) error {
 }
 
 // Chtimes changes the access and modification times of the named
-// file, similar to the Unix utime() or utimes() functions.
+// file, similar to the Unix utime() or utimes() functions. If the file
+// is a symbolic link, the times of the link itself are changed rather
+// than those of its target.
 //
 // The underlying filesystem may truncate or round the values to a
 // less precise time unit.
@@ -160,8 +174,7 @@ func (fs *UnixFS) Chtimesat(dirfd int, name string, atime, mtime time.Time) erro
 	}
 	set(0, atime)
 	set(1, mtime)
-	// This does support `AT_SYMLINK_NOFOLLOW` as well if needed.
-	if err := unix.UtimesNanoAt(dirfd, name, utimes[0:], 0); err != nil {
+	if err := unix.UtimesNanoAt(dirfd, name, utimes[0:], AT_SYMLINK_NOFOLLOW); err != nil {
 		return convertErrorType(&PathError{Op: "chtimes", Path: name, Err: err})
 	}
 	return nil
@@ -398,12 +411,23 @@ func (fs *UnixFS) unlinkat(dirfd int, name string, flags int) error {
 
 // Rename renames (moves) oldpath to newpath.
 //
-// If newpath already exists and is not a directory, Rename replaces it.
-// OS-specific restrictions may apply when oldpath and newpath are in different directories.
-// Even within the same directory, on non-Unix platforms Rename is not an atomic operation.
+// If newpath already exists, Rename returns ErrExist rather than replacing it.
+// On Linux this is done atomically using renameat2 with RENAME_NOREPLACE, on
+// other platforms, or if the kernel or filesystem does not support it, the
+// existence of newpath is checked before renaming instead.
 //
 // If there is an error, it will be of type *LinkError.
 func (fs *UnixFS) Rename(oldpath, newpath string) error {
+	return fs.rename(oldpath, newpath, false)
+}
+
+// RenameReplace renames (moves) oldpath to newpath, replacing newpath if it
+// already exists and is not a directory.
+func (fs *UnixFS) RenameReplace(oldpath, newpath string) error {
+	return fs.rename(oldpath, newpath, true)
+}
+
+func (fs *UnixFS) rename(oldpath, newpath string, replace bool) error {
 	// Simple case: both paths are the same.
 	if oldpath == newpath {
 		return nil
@@ -461,17 +485,20 @@ func (fs *UnixFS) Rename(oldpath, newpath string) error {
 			Err:  ErrBadPathResolution,
 		})
 	}
-	// Stat the new target to return proper errors.
-	_, err = fs.Lstatat(newdirfd, newname)
-	switch {
-	case err == nil:
+	if !replace {
+		if err := renameNoReplace(olddirfd, oldname, newdirfd, newname); err != nil {
+			return convertErrorType(&PathError{Op: "rename", Path: newname, Err: err})
+		}
+		return nil
+	}
+	// Match the behaviour of os.Rename and refuse to replace a directory, even
+	// an empty one.
+	if st, err := fs.Lstatat(newdirfd, newname); err == nil && st.IsDir() {
 		return convertErrorType(&PathError{
 			Op:   "rename",
 			Path: newname,
 			Err:  ErrExist,
 		})
-	case !errors.Is(err, ErrNotExist):
-		return err
 	}
 	return unix.Renameat(olddirfd, oldname, newdirfd, newname)
 }
@@ -555,6 +582,33 @@ func (fs *UnixFS) Symlink(oldpath, newpath string) error {
 	return nil
 }
 
+// Truncate changes the size of the named file without replacing it, so that
+// any process that currently has the file open continues to write to the same
+// file. The file is resolved safely within the filesystem and must be a
+// regular file.
+//
+// If there is an error, it will be of type *PathError.
+func (fs *UnixFS) Truncate(name string, size int64) error {
+	// Open the file without blocking so that a named pipe cannot stall the call.
+	fd, err := fs.openFile(name, O_WRONLY|unix.O_NONBLOCK|O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return convertErrorType(&PathError{Op: "truncate", Path: name, Err: err})
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFREG {
+		return &PathError{Op: "truncate", Path: name, Err: ErrNotRegular}
+	}
+	if err := unix.Ftruncate(fd, size); err != nil {
+		return convertErrorType(&PathError{Op: "truncate", Path: name, Err: err})
+	}
+	return nil
+}
+
 // Touch will attempt to open a file for reading and/or writing. If the file
 // does not exist it will be created, and any missing parent directories will
 // also be created. The opened file may be truncated, only if `flag` has
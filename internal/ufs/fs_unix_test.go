@@ -6,13 +6,16 @@
 package ufs_test
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
 	"reflect"
 	"slices"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/pelican-dev/wings/internal/ufs"
 )
@@ -288,7 +291,71 @@ func TestUnixFS_Chtimes(t *testing.T) {
 	}
 	defer fs.Cleanup()
 
-	// TODO: implement
+	mtime := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("file", func(t *testing.T) {
+		f, err := fs.Create("chtimes_file")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_ = f.Close()
+
+		if err := fs.Chtimes("chtimes_file", mtime, mtime); err != nil {
+			t.Error(err)
+			return
+		}
+		st, err := os.Lstat(filepath.Join(fs.Root, "chtimes_file"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !st.ModTime().Equal(mtime) {
+			t.Errorf("expected modification time to be %s, but got %s", mtime, st.ModTime())
+			return
+		}
+	})
+
+	t.Run("symlink outside root", func(t *testing.T) {
+		target := filepath.Join(fs.TmpDir, "chtimes_target")
+		if err := os.WriteFile(target, []byte("test"), 0o644); err != nil {
+			t.Error(err)
+			return
+		}
+		before, err := os.Stat(target)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err := os.Symlink(target, filepath.Join(fs.Root, "chtimes_symlink")); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if err := fs.Chtimes("chtimes_symlink", mtime, mtime); err != nil {
+			t.Error(err)
+			return
+		}
+
+		after, err := os.Stat(target)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !after.ModTime().Equal(before.ModTime()) {
+			t.Errorf("expected symlink target to be left untouched, but its modification time changed to %s", after.ModTime())
+			return
+		}
+		st, err := os.Lstat(filepath.Join(fs.Root, "chtimes_symlink"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !st.ModTime().Equal(mtime) {
+			t.Errorf("expected symlink modification time to be %s, but got %s", mtime, st.ModTime())
+			return
+		}
+	})
 }
 
 func TestUnixFS_Create(t *testing.T) {
@@ -493,6 +560,31 @@ func TestUnixFS_Rename(t *testing.T) {
 			return
 		}
 	})
+
+	t.Run("rename without replacing", func(t *testing.T) {
+		for _, name := range []string{"noreplace_source", "noreplace_target"} {
+			f, err := fs.Create(name)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = f.Close()
+		}
+
+		if err := fs.Rename("noreplace_source", "noreplace_target"); !errors.Is(err, ufs.ErrExist) {
+			t.Errorf("expected an exist error, but got: %v", err)
+			return
+		}
+		if _, err := os.Lstat(filepath.Join(fs.Root, "noreplace_source")); err != nil {
+			t.Errorf("expected source to still exist: %v", err)
+			return
+		}
+
+		if err := fs.RenameReplace("noreplace_source", "noreplace_target"); err != nil {
+			t.Errorf("expected no error, but got: %v", err)
+			return
+		}
+	})
 }
 
 func TestUnixFS_Stat(t *testing.T) {
@@ -734,6 +826,93 @@ func TestUnixFS_WalkDirat(t *testing.T) {
 	})
 }
 
+func TestUnixFS_WalkDiratParallel(t *testing.T) {
+	t.Parallel()
+	fs, err := newTestUnixFS()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer fs.Cleanup()
+
+	for i := 0; i < 5; i++ {
+		dir := filepath.Join("base"+strconv.Itoa(i), "dir", "nested")
+		if err := fs.MkdirAll(dir, 0o755); err != nil {
+			t.Error(err)
+			return
+		}
+		for _, p := range []string{"base" + strconv.Itoa(i), dir} {
+			f, err := fs.Create(filepath.Join(p, "file"))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = f.Close()
+		}
+	}
+
+	expect, err := fs.testWalkDirAt("")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Run("walk matches a sequential walk", func(t *testing.T) {
+		dirfd, name, closeFd, err := fs.SafePath("")
+		defer closeFd()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var mu sync.Mutex
+		var pathsTraversed []Path
+		if err := fs.WalkDiratParallel(context.Background(), dirfd, name, 3, func(_ int, name, relative string, _ ufs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			pathsTraversed = append(pathsTraversed, Path{Name: name, Relative: relative})
+			mu.Unlock()
+			return nil
+		}); err != nil {
+			t.Error(err)
+			return
+		}
+		slices.SortStableFunc(pathsTraversed, func(a, b Path) int {
+			if a.Relative > b.Relative {
+				return 1
+			}
+			if a.Relative < b.Relative {
+				return -1
+			}
+			return 0
+		})
+		if !reflect.DeepEqual(pathsTraversed, expect) {
+			t.Log(pathsTraversed)
+			t.Log(expect)
+			t.Error("walk doesn't match")
+			return
+		}
+	})
+
+	t.Run("walk stops when the context is canceled", func(t *testing.T) {
+		dirfd, name, closeFd, err := fs.SafePath("")
+		defer closeFd()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err = fs.WalkDiratParallel(ctx, dirfd, name, 3, func(int, string, string, ufs.DirEntry, error) error {
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+}
+
 type Path struct {
 	Name     string
 	Relative string
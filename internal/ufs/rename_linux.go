@@ -0,0 +1,18 @@
+//go:build linux
+
+package ufs
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// renameNoReplace atomically renames the file without replacing an existing
+// file at the destination. If renameat2 is not supported by the kernel or the
+// filesystem the destination is checked before renaming instead.
+func renameNoReplace(olddirfd int, oldname string, newdirfd int, newname string) error {
+	err := unix.Renameat2(olddirfd, oldname, newdirfd, newname, unix.RENAME_NOREPLACE)
+	if err == unix.ENOSYS || err == unix.EINVAL {
+		return renameCheckExists(olddirfd, oldname, newdirfd, newname)
+	}
+	return err
+}
@@ -0,0 +1,9 @@
+//go:build unix && !linux
+
+package ufs
+
+// renameNoReplace renames the file without replacing an existing file at the
+// destination.
+func renameNoReplace(olddirfd int, oldname string, newdirfd int, newname string) error {
+	return renameCheckExists(olddirfd, oldname, newdirfd, newname)
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package ufs
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// renameCheckExists renames the file if nothing exists at the destination. This
+// is not atomic, so a file created at the destination between the check and the
+// rename will be replaced.
+func renameCheckExists(olddirfd int, oldname string, newdirfd int, newname string) error {
+	var st unix.Stat_t
+	err := unix.Fstatat(newdirfd, newname, &st, unix.AT_SYMLINK_NOFOLLOW)
+	switch {
+	case err == nil:
+		return unix.EEXIST
+	case err != unix.ENOENT:
+		return err
+	}
+	return unix.Renameat(olddirfd, oldname, newdirfd, newname)
+}
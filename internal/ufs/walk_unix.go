@@ -8,6 +8,7 @@ package ufs
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	iofs "io/fs"
 	"os"
@@ -15,6 +16,7 @@ import (
 	"reflect"
 	"unsafe"
 
+	"golang.org/x/sync/errgroup"
 	"golang.org/x/sys/unix"
 )
 
@@ -89,6 +91,124 @@ func (fs *UnixFS) walkDir(b []byte, parentfd int, name, relative string, d DirEn
 	return nil
 }
 
+// WalkDiratParallel is like WalkDirat except that subdirectories are walked by
+// up to "workers" goroutines at once. The walk function may be called from
+// multiple goroutines at the same time, and entries are not visited in any
+// particular order. Returning SkipDir from the walk function for a file skips
+// the remaining entries in that directory, while SkipAll stops the entire walk.
+//
+// The walk is stopped and the context error returned if the context is canceled
+// before the walk completes.
+func (fs *UnixFS) WalkDiratParallel(ctx context.Context, dirfd int, name string, workers int, fn WalkDiratFunc) error {
+	info, err := fs.Lstatat(dirfd, name)
+	if err != nil {
+		err = fn(dirfd, name, ".", nil, err)
+	} else {
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(max(workers, 1))
+		w := &parallelWalker{fs: fs, ctx: gctx, g: g, fn: fn}
+		g.Go(func() error {
+			return w.walk(dirfd, name, ".", iofs.FileInfoToDirEntry(info))
+		})
+		err = g.Wait()
+	}
+	if err == SkipDir || err == SkipAll {
+		return nil
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
+
+// parallelWalker tracks the state of a single call to WalkDiratParallel.
+type parallelWalker struct {
+	fs  *UnixFS
+	ctx context.Context
+	g   *errgroup.Group
+	fn  WalkDiratFunc
+}
+
+// walk visits the entry and, if it is a directory, opens it and walks the
+// entries within it. The directory is opened before returning so that the
+// parent directory file descriptor is not needed once a subdirectory has been
+// handed off to another goroutine.
+func (w *parallelWalker) walk(parentfd int, name, relative string, d DirEntry) error {
+	dirfd, err := w.open(parentfd, name, relative, d)
+	if err != nil || dirfd == 0 {
+		return err
+	}
+	return w.walkOpened(dirfd, name, relative, d)
+}
+
+// open calls the walk function for the entry and returns a file descriptor for
+// it if it is a directory that should be descended into. A file descriptor of
+// zero is returned for anything that should not be walked.
+func (w *parallelWalker) open(parentfd int, name, relative string, d DirEntry) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if err := w.fn(parentfd, name, relative, d, nil); err != nil || !d.IsDir() {
+		if err == SkipDir && d.IsDir() {
+			err = nil
+		}
+		return 0, err
+	}
+	dirfd, err := w.fs.openat(parentfd, name, O_DIRECTORY|O_RDONLY, 0)
+	if err != nil {
+		if dirfd != 0 {
+			unix.Close(dirfd)
+		}
+		return 0, err
+	}
+	return dirfd, nil
+}
+
+// walkOpened walks the entries of an open directory, closing the file descriptor
+// once complete. Subdirectories are handed off to another goroutine when one is
+// available, otherwise they are walked by the calling goroutine.
+func (w *parallelWalker) walkOpened(dirfd int, name, relative string, d DirEntry) error {
+	defer unix.Close(dirfd)
+
+	dirs, err := w.fs.readDir(dirfd, name, relative, nil)
+	if err != nil {
+		if err := w.fn(dirfd, name, relative, d, err); err != nil {
+			if err == SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for _, d1 := range dirs {
+		name := d1.Name()
+		var rel string
+		if relative == "." {
+			rel = name
+		} else {
+			rel = path.Join(relative, name)
+		}
+		fd, err := w.open(dirfd, name, rel, d1)
+		if err != nil {
+			if err == SkipDir {
+				break
+			}
+			return err
+		}
+		if fd == 0 {
+			continue
+		}
+		if w.g.TryGo(func() error { return w.walkOpened(fd, name, rel, d1) }) {
+			continue
+		}
+		if err := w.walkOpened(fd, name, rel, d1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // ReadDirMap .
 // TODO: document
 func ReadDirMap[T any](fs *UnixFS, path string, fn func(DirEntry) (T, error)) ([]T, error) {
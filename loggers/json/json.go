@@ -0,0 +1,44 @@
+package json
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/goccy/go-json"
+)
+
+// Handler is a log handler that writes each log entry as a single line of JSON,
+// which allows log aggregation tools to index the attached fields directly.
+type Handler struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func New(w io.Writer) *Handler {
+	return &Handler{enc: json.NewEncoder(w)}
+}
+
+// HandleLog implements log.Handler.
+func (h *Handler) HandleLog(e *log.Entry) error {
+	out := make(map[string]interface{}, len(e.Fields)+3)
+	for k, v := range e.Fields {
+		// Errors do not marshal into anything useful by default, so convert them
+		// into their string representation before encoding.
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		out[k] = v
+	}
+	// Always set these after the fields so that they cannot be overwritten by
+	// a field sharing the same name.
+	out["timestamp"] = e.Timestamp.Format(time.RFC3339Nano)
+	out["level"] = e.Level.String()
+	out["message"] = e.Message
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.enc.Encode(out)
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"emperror.dev/errors"
+)
+
+// The maximum size of a gzipped configuration file once it has been decompressed.
+// Configuration files are expected to be small, so anything larger is refused
+// rather than decompressed into memory.
+const maxGzipDecompressedSize = 32 * 1024 * 1024
+
+// ErrGzipTooLarge is returned when a gzipped configuration file decompresses to
+// more than the maximum allowed size.
+var ErrGzipTooLarge = errors.Sentinel("parser: gzipped file exceeds the maximum decompressed size")
+
+// The magic bytes found at the start of every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// configFile is the subset of file functionality required by the individual
+// parsers in order to read and then rewrite a configuration file.
+type configFile interface {
+	io.Reader
+	io.Writer
+	io.Seeker
+	Truncate(size int64) error
+}
+
+// memoryFile is an in-memory implementation of configFile which is used when
+// parsing compressed configuration files.
+type memoryFile struct {
+	buf []byte
+	off int64
+}
+
+func (m *memoryFile) Read(p []byte) (int, error) {
+	if m.off >= int64(len(m.buf)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.buf[m.off:])
+	m.off += int64(n)
+	return n, nil
+}
+
+func (m *memoryFile) Write(p []byte) (int, error) {
+	if end := m.off + int64(len(p)); end > int64(len(m.buf)) {
+		m.buf = append(m.buf, make([]byte, end-int64(len(m.buf)))...)
+	}
+	n := copy(m.buf[m.off:], p)
+	m.off += int64(n)
+	return n, nil
+}
+
+func (m *memoryFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += m.off
+	case io.SeekEnd:
+		offset += int64(len(m.buf))
+	default:
+		return 0, errors.New("parser: invalid seek whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("parser: negative seek position")
+	}
+	m.off = offset
+	return offset, nil
+}
+
+func (m *memoryFile) Truncate(size int64) error {
+	if size < 0 {
+		return errors.New("parser: negative truncate size")
+	}
+	if size > int64(len(m.buf)) {
+		m.buf = append(m.buf, make([]byte, size-int64(len(m.buf)))...)
+	} else {
+		m.buf = m.buf[:size]
+	}
+	return nil
+}
+
+// Checks if the file is a gzip stream by looking for the gzip magic bytes at
+// the start of the file. The file is always returned to the start once the
+// check has been performed.
+func isGzipped(file io.ReadSeeker) (bool, error) {
+	b := make([]byte, len(gzipMagic))
+	n, err := io.ReadFull(file, b)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	return n == len(gzipMagic) && bytes.Equal(b, gzipMagic), nil
+}
+
+// Decompresses a gzipped configuration file into memory, runs the parser against
+// the decompressed contents, and then writes the compressed result back to the
+// file on the disk.
+func (f *ConfigurationFile) parseGzippedFile(file configFile) error {
+	r, err := gzip.NewReader(file)
+	if err != nil {
+		return errors.Wrap(err, "parser: failed to open gzipped file")
+	}
+	// Read one byte more than the limit so that a file exactly at the limit can be
+	// told apart from one that exceeds it.
+	b, err := io.ReadAll(io.LimitReader(r, maxGzipDecompressedSize+1))
+	if err != nil {
+		return errors.Wrap(err, "parser: failed to decompress file")
+	}
+	if len(b) > maxGzipDecompressedSize {
+		_ = r.Close()
+		return ErrGzipTooLarge
+	}
+	header := r.Header
+	_ = r.Close()
+
+	m := &memoryFile{buf: b}
+	if err := f.parse(m); err != nil {
+		return err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+
+	w := gzip.NewWriter(file)
+	w.Header = header
+	if _, err := w.Write(m.buf); err != nil {
+		return errors.Wrap(err, "parser: failed to write gzipped file to disk")
+	}
+	return errors.Wrap(w.Close(), "parser: failed to write gzipped file to disk")
+}
@@ -22,6 +22,15 @@ import (
 // it is common to see variables such as "{{config.docker.interface}}"
 var configMatchRegex = regexp.MustCompile(`{{\s?config\.([\w.-]+)\s?}}`)
 
+// Regex to match anything that has a value matching the format of {{ env.$1 }} which
+// will cause the value to be replaced with the value of that environment variable for
+// the server, such as "{{env.SERVER_PORT}}".
+var envMatchRegex = regexp.MustCompile(`{{\s?env\.([\w.-]+)\s?}}`)
+
+// Matches both configuration and environment variable placeholders so that they
+// can be replaced in a single pass.
+var placeholderMatchRegex = regexp.MustCompile(`{{\s?(?:config|env)\.[\w.-]+\s?}}`)
+
 // Regex to support modifying XML inline variable data using the config tools. This means
 // you can pass a replacement of Root.Property='[value="testing"]' to get an XML node
 // matching:
@@ -32,6 +41,9 @@ var configMatchRegex = regexp.MustCompile(`{{\s?config\.([\w.-]+)\s?}}`)
 //
 // </Root>
 //
+// Prefer using the explicit "Root.Property.@value" syntax for setting attributes, this
+// format is only retained for backwards compatibility with existing eggs.
+//
 // noinspection RegExpRedundantEscape
 var xmlValueMatchRegex = regexp.MustCompile(`^\[([\w]+)='(.*)'\]$`)
 
@@ -85,7 +97,7 @@ func (f *ConfigurationFile) IterateOverJson(data []byte) (*gabs.Container, error
 			// If the child is a null value, nothing will happen. Seems reasonable as of the
 			// time this code is being written.
 			for _, child := range parsed.Path(strings.Trim(parts[0], ".")).Children() {
-				if err := v.SetAtPathway(child, strings.Trim(parts[1], "."), value); err != nil {
+				if err := v.applyAtPathway(child, strings.Trim(parts[1], "."), value); err != nil {
 					if errors.Is(err, gabs.ErrNotFound) {
 						continue
 					}
@@ -95,7 +107,7 @@ func (f *ConfigurationFile) IterateOverJson(data []byte) (*gabs.Container, error
 			continue
 		}
 
-		if err := v.SetAtPathway(parsed, v.Match, value); err != nil {
+		if err := v.applyAtPathway(parsed, v.Match, value); err != nil {
 			if errors.Is(err, gabs.ErrNotFound) {
 				continue
 			}
@@ -178,6 +190,68 @@ func setValueAtPath(c *gabs.Container, path string, value interface{}) error {
 	return nil
 }
 
+// Applies the replacement operation at a specific pathway. By default this will set
+// the value at the path, but replacements may also append a value to an array, or
+// delete a key (or matching array values) from the data.
+func (cfr *ConfigurationFileReplacement) applyAtPathway(c *gabs.Container, path string, value string) error {
+	switch cfr.Operation {
+	case OperationAppend:
+		return cfr.AppendAtPathway(c, path, value)
+	case OperationDelete:
+		return cfr.DeleteAtPathway(c, path, value)
+	default:
+		return cfr.SetAtPathway(c, path, value)
+	}
+}
+
+// AppendAtPathway appends the value to the array at the given path, creating the
+// array if it does not yet exist. If the value is already present in the array it
+// is not appended again, otherwise every boot of the server would add another copy
+// of the value to the file.
+func (cfr *ConfigurationFileReplacement) AppendAtPathway(c *gabs.Container, path string, value string) error {
+	v := cfr.getKeyValue(value)
+	if c.ExistsP(path) {
+		if _, ok := c.Path(path).Data().([]interface{}); !ok {
+			return errors.New("parser: cannot append value to non-array path: " + path)
+		}
+		for _, child := range c.Path(path).Children() {
+			if child.String() == gabs.Wrap(v).String() {
+				return nil
+			}
+		}
+	}
+
+	if err := c.ArrayAppendP(v, path); err != nil {
+		return errors.WithMessage(err, "failed to append value at config path: "+path)
+	}
+	return nil
+}
+
+// DeleteAtPathway deletes the key at the given path. If the path is an array and a
+// value was provided, only the matching elements are removed from the array rather
+// than the entire key.
+func (cfr *ConfigurationFileReplacement) DeleteAtPathway(c *gabs.Container, path string, value string) error {
+	if !c.ExistsP(path) {
+		return nil
+	}
+
+	if cfr.ReplaceWith.Type() != jsonparser.NotExist {
+		if children, ok := c.Path(path).Data().([]interface{}); ok {
+			match := gabs.Wrap(cfr.getKeyValue(value)).String()
+			out := make([]interface{}, 0, len(children))
+			for _, child := range children {
+				if gabs.Wrap(child).String() != match {
+					out = append(out, child)
+				}
+			}
+			_, err := c.SetP(out, path)
+			return err
+		}
+	}
+
+	return c.DeleteP(path)
+}
+
 // Sets the value at a specific pathway, but checks if we were looking for a specific
 // value or not before doing it.
 func (cfr *ConfigurationFileReplacement) SetAtPathway(c *gabs.Container, path string, value string) error {
@@ -214,20 +288,29 @@ func (cfr *ConfigurationFileReplacement) SetAtPathway(c *gabs.Container, path st
 	return setValueAtPath(c, path, cfr.getKeyValue(value))
 }
 
-// Looks up a configuration value on the Daemon given a dot-notated syntax.
+// Looks up a configuration value on the Daemon given a dot-notated syntax. Any
+// references to the server's environment variables are also resolved here.
 func (f *ConfigurationFile) LookupConfigurationValue(cfr ConfigurationFileReplacement) (string, error) {
 	// If this is not something that we can do a regex lookup on then just continue
 	// on our merry way. If the value isn't a string, we're not going to be doing anything
 	// with it anyways.
-	if cfr.ReplaceWith.Type() != jsonparser.String || !configMatchRegex.Match(cfr.ReplaceWith.Value()) {
+	if cfr.ReplaceWith.Type() != jsonparser.String {
 		return cfr.ReplaceWith.String(), nil
 	}
 
+	// Placeholders are only ever resolved from the original template. Values that
+	// are substituted in, such as server variables, are never scanned again, so a
+	// user controlled variable cannot reference the Daemon configuration.
+	value := cfr.ReplaceWith.String()
+	if !configMatchRegex.MatchString(value) {
+		return f.replaceEnvironmentValues(value, nil), nil
+	}
+
 	// If there is a match, lookup the value in the configuration for the Daemon. If no key
 	// is found, just return the string representation, otherwise use the value from the
 	// daemon configuration here.
 	huntPath := configMatchRegex.ReplaceAllString(
-		configMatchRegex.FindString(cfr.ReplaceWith.String()), "$1",
+		configMatchRegex.FindString(value), "$1",
 	)
 
 	var path []string
@@ -249,6 +332,36 @@ func (f *ConfigurationFile) LookupConfigurationValue(cfr ConfigurationFileReplac
 		// is a replace issue at play.
 		return string(match), nil
 	} else {
-		return configMatchRegex.ReplaceAllString(cfr.ReplaceWith.String(), string(match)), nil
+		return f.replaceEnvironmentValues(value, match), nil
+	}
+}
+
+// Replaces any "{{env.VARIABLE}}" placeholders in the value with the matching
+// environment variable for the server, and any "{{config.*}}" placeholders with
+// the given configuration value if it is not nil. If a variable is not defined
+// the placeholder is left intact so that it is obvious there is a replace issue.
+//
+// All placeholders are replaced in a single pass over the value, so nothing
+// that is substituted in is itself treated as a placeholder.
+func (f *ConfigurationFile) replaceEnvironmentValues(value string, configValue []byte) string {
+	if !placeholderMatchRegex.MatchString(value) {
+		return value
 	}
+
+	return placeholderMatchRegex.ReplaceAllStringFunc(value, func(m string) string {
+		if configMatchRegex.MatchString(m) {
+			if configValue == nil {
+				return m
+			}
+			return string(configValue)
+		}
+		name := envMatchRegex.FindStringSubmatch(m)[1]
+		if v, ok := f.environment[name]; ok {
+			return v
+		}
+
+		log.WithFields(log.Fields{"variable": name, "filename": f.FileName}).Debug("attempted to load an environment variable that does not exist")
+
+		return m
+	})
 }
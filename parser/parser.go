@@ -75,6 +75,19 @@ func (cv *ReplaceValue) String() string {
 	}
 }
 
+// MarshalJSON returns the replacement value in the same JSON representation that
+// it was originally received in.
+func (cv ReplaceValue) MarshalJSON() ([]byte, error) {
+	switch cv.valueType {
+	case jsonparser.String:
+		return append(append([]byte{'"'}, cv.value...), '"'), nil
+	case jsonparser.Number, jsonparser.Boolean, jsonparser.Object, jsonparser.Array:
+		return cv.value, nil
+	default:
+		return []byte("null"), nil
+	}
+}
+
 func (cv *ReplaceValue) Bytes() []byte {
 	switch cv.Type() {
 	case jsonparser.String:
@@ -112,6 +125,10 @@ type ConfigurationFile struct {
 	// Tracks Wings' configuration so that we can quickly get values
 	// out of it when variables request it.
 	configuration []byte
+
+	// Tracks the environment variables for the server so that they can be
+	// referenced by replacements using the "{{env.VARIABLE}}" syntax.
+	environment map[string]string
 }
 
 // UnmarshalJSON is a custom unmarshaler for configuration files. If there is an
@@ -132,10 +149,21 @@ func (f *ConfigurationFile) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	if err := json.Unmarshal(*m["replace"], &f.Replace); err != nil {
-		log.WithField("file", f.FileName).WithField("error", err).Warn("failed to unmarshal configuration file replacement")
-
-		f.Replace = []ConfigurationFileReplacement{}
+	// Each replacement is unmarshaled on its own so that a single invalid one, such
+	// as one using an operation this version of Wings does not support, is skipped
+	// without losing the rest of the replacements for the file.
+	var replacements []json.RawMessage
+	if err := json.Unmarshal(*m["replace"], &replacements); err != nil {
+		log.WithField("file", f.FileName).WithField("error", err).Warn("failed to unmarshal configuration file replacements")
+	}
+	f.Replace = make([]ConfigurationFileReplacement, 0, len(replacements))
+	for _, r := range replacements {
+		var cfr ConfigurationFileReplacement
+		if err := json.Unmarshal(r, &cfr); err != nil {
+			log.WithField("file", f.FileName).WithField("error", err).Warn("failed to unmarshal configuration file replacement, skipping")
+			continue
+		}
+		f.Replace = append(f.Replace, cfr)
 	}
 
 	// test if "create_file" exists, if not just assume true
@@ -152,12 +180,22 @@ func (f *ConfigurationFile) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// The operations that can be performed by a configuration file replacement. These
+// are only supported by the JSON and YAML parsers, all other parsers will always
+// set the value.
+const (
+	OperationSet    = "set"
+	OperationAppend = "append"
+	OperationDelete = "delete"
+)
+
 // ConfigurationFileReplacement defines a single find/replace instance for a
 // given server configuration file.
 type ConfigurationFileReplacement struct {
 	Match       string       `json:"match"`
 	IfValue     string       `json:"if_value"`
 	ReplaceWith ReplaceValue `json:"replace_with"`
+	Operation   string       `json:"operation"`
 }
 
 // UnmarshalJSON handles unmarshaling the JSON representation into a struct that
@@ -178,6 +216,19 @@ func (cfr *ConfigurationFileReplacement) UnmarshalJSON(data []byte) error {
 	}
 	cfr.IfValue = iv
 
+	op, err := jsonparser.GetString(data, "operation")
+	if err != nil && err != jsonparser.KeyPathNotFoundError {
+		return err
+	}
+	switch op {
+	case "":
+		op = OperationSet
+	case OperationSet, OperationAppend, OperationDelete:
+	default:
+		return errors.New("parser: invalid replacement operation: " + op)
+	}
+	cfr.Operation = op
+
 	rw, dt, _, err := jsonparser.Get(data, "replace_with")
 	if err != nil {
 		if err != jsonparser.KeyPathNotFoundError {
@@ -187,7 +238,8 @@ func (cfr *ConfigurationFileReplacement) UnmarshalJSON(data []byte) error {
 		// Okay, likely dealing with someone who forgot to upgrade their eggs, so in
 		// that case, fallback to using the old key which was "value".
 		rw, dt, _, err = jsonparser.Get(data, "value")
-		if err != nil {
+		// A value is not required when deleting a key from the file.
+		if err != nil && (err != jsonparser.KeyPathNotFoundError || op != OperationDelete) {
 			return err
 		}
 	}
@@ -201,8 +253,9 @@ func (cfr *ConfigurationFileReplacement) UnmarshalJSON(data []byte) error {
 }
 
 // Parse parses a given configuration file and updates all the values within
-// as defined in the API response from the Panel.
-func (f *ConfigurationFile) Parse(file ufs.File) error {
+// as defined in the API response from the Panel. The environment provided is
+// used to resolve any "{{env.VARIABLE}}" placeholders in the replacements.
+func (f *ConfigurationFile) Parse(file ufs.File, environment map[string]string) error {
 	//log.WithField("path", path).WithField("parser", f.Parser.String()).Debug("parsing server configuration file")
 
 	// What the fuck is going on here?
@@ -211,7 +264,23 @@ func (f *ConfigurationFile) Parse(file ufs.File) error {
 	} else {
 		f.configuration = mb
 	}
+	f.environment = environment
 
+	// If the file is gzipped, decompress it into memory and run the parser against
+	// the decompressed contents, compressing the result back into the file once
+	// the parser has finished.
+	compressed, err := isGzipped(file)
+	if err != nil {
+		return err
+	}
+	if compressed {
+		return f.parseGzippedFile(file)
+	}
+	return f.parse(file)
+}
+
+// Runs the parser for the configuration file against the given file.
+func (f *ConfigurationFile) parse(file configFile) error {
 	var err error
 
 	switch f.Parser {
@@ -232,16 +301,30 @@ func (f *ConfigurationFile) Parse(file ufs.File) error {
 }
 
 // Parses an xml file.
-func (f *ConfigurationFile) parseXmlFile(file ufs.File) error {
+//
+// The final segment of a match may be used to control which part of the matched
+// element is updated. "Root.Node.@name" will set the "name" attribute on each
+// matched "Node", while "Root.Node.text()" will set the text content of the
+// element. If neither is provided the text content is set, unless the value is
+// in the legacy "[name='value']" format, in which case the attribute is set.
+//
+// The formatting of the document is preserved when writing it back to the disk,
+// unless new elements had to be created in order to set a value, in which case
+// the document is re-indented so that those elements are laid out correctly.
+func (f *ConfigurationFile) parseXmlFile(file configFile) error {
 	doc := etree.NewDocument()
+	doc.ReadSettings.PreserveCData = true
 	if _, err := doc.ReadFrom(file); err != nil {
 		return err
 	}
 
+	var created bool
+
 	// If there is no root we should create a basic start to the file. This isn't required though,
 	// and if it doesn't work correctly I'll just remove the code.
 	if doc.Root() == nil {
 		doc.CreateProcInst("xml", `version="1.0" encoding="utf-8"`)
+		created = true
 	}
 
 	for i, replacement := range f.Replace {
@@ -250,19 +333,21 @@ func (f *ConfigurationFile) parseXmlFile(file ufs.File) error {
 			return err
 		}
 
+		match, attr := splitXmlMatch(replacement.Match)
+
 		// If this is the first item and there is no root element, create that root now and apply
 		// it for future use.
 		if i == 0 && doc.Root() == nil {
-			parts := strings.SplitN(replacement.Match, ".", 2)
+			parts := strings.SplitN(match, ".", 2)
 			doc.SetRoot(doc.CreateElement(parts[0]))
 		}
 
-		path := "./" + strings.Replace(replacement.Match, ".", "/", -1)
+		path := "./" + strings.Replace(match, ".", "/", -1)
 
 		// If we're not doing a wildcard replacement go ahead and create the
 		// missing element if we cannot find it yet.
 		if !strings.Contains(path, "*") {
-			parts := strings.Split(replacement.Match, ".")
+			parts := strings.Split(match, ".")
 
 			// Set the initial element to be the root element, and then work from there.
 			element := doc.Root()
@@ -274,6 +359,7 @@ func (f *ConfigurationFile) parseXmlFile(file ufs.File) error {
 			for _, tag := range parts[1:] {
 				if e := element.FindElement(tag); e == nil {
 					element = element.CreateElement(tag)
+					created = true
 				} else {
 					element = e
 				}
@@ -282,7 +368,9 @@ func (f *ConfigurationFile) parseXmlFile(file ufs.File) error {
 
 		// Iterate over the elements we found and update their values.
 		for _, element := range doc.FindElements(path) {
-			if xmlValueMatchRegex.MatchString(value) {
+			if attr != "" {
+				element.CreateAttr(attr, value)
+			} else if xmlValueMatchRegex.MatchString(value) && !strings.HasSuffix(replacement.Match, ".text()") {
 				k := xmlValueMatchRegex.ReplaceAllString(value, "$1")
 				v := xmlValueMatchRegex.ReplaceAllString(value, "$2")
 
@@ -300,8 +388,11 @@ func (f *ConfigurationFile) parseXmlFile(file ufs.File) error {
 		return err
 	}
 
-	// Ensure the XML is indented properly.
-	doc.Indent(2)
+	// Ensure the XML is indented properly if we had to add any elements to it,
+	// otherwise leave the existing formatting of the document alone.
+	if created {
+		doc.Indent(2)
+	}
 
 	// Write the XML to the file.
 	if _, err := doc.WriteTo(file); err != nil {
@@ -310,8 +401,20 @@ func (f *ConfigurationFile) parseXmlFile(file ufs.File) error {
 	return nil
 }
 
+// Splits the final "@attribute" or "text()" segment off of an XML match, returning
+// the path to the element and the name of the attribute to set, if any.
+func splitXmlMatch(match string) (string, string) {
+	if strings.HasSuffix(match, ".text()") {
+		return strings.TrimSuffix(match, ".text()"), ""
+	}
+	if i := strings.LastIndex(match, "."); i != -1 && strings.HasPrefix(match[i+1:], "@") {
+		return match[:i], match[i+2:]
+	}
+	return match, ""
+}
+
 // Parses an ini file.
-func (f *ConfigurationFile) parseIniFile(file ufs.File) error {
+func (f *ConfigurationFile) parseIniFile(file configFile) error {
 	// Wrap the file in a NopCloser so the ini package doesn't close the file.
 	cfg, err := ini.Load(io.NopCloser(file))
 	if err != nil {
@@ -392,7 +495,7 @@ func (f *ConfigurationFile) parseIniFile(file ufs.File) error {
 // Parses a json file updating any matching key/value pairs. If a match is not found, the
 // value is set regardless in the file. See the commentary in parseYamlFile for more details
 // about what is happening during this process.
-func (f *ConfigurationFile) parseJsonFile(file ufs.File) error {
+func (f *ConfigurationFile) parseJsonFile(file configFile) error {
 	b, err := io.ReadAll(file)
 	if err != nil {
 		return err
@@ -419,7 +522,7 @@ func (f *ConfigurationFile) parseJsonFile(file ufs.File) error {
 
 // Parses a yaml file and updates any matching key/value pairs before persisting
 // it back to the disk.
-func (f *ConfigurationFile) parseYamlFile(file ufs.File) error {
+func (f *ConfigurationFile) parseYamlFile(file configFile) error {
 	b, err := io.ReadAll(file)
 	if err != nil {
 		return err
@@ -468,7 +571,7 @@ func (f *ConfigurationFile) parseYamlFile(file ufs.File) error {
 // Parses a text file using basic find and replace. This is a highly inefficient method of
 // scanning a file and performing a replacement. You should attempt to use anything other
 // than this function where possible.
-func (f *ConfigurationFile) parseTextFile(file ufs.File) error {
+func (f *ConfigurationFile) parseTextFile(file configFile) error {
 	b := bytes.NewBuffer(nil)
 	s := bufio.NewScanner(file)
 	var replaced bool
@@ -530,7 +633,7 @@ func (f *ConfigurationFile) parseTextFile(file ufs.File) error {
 //
 // @see https://github.com/pterodactyl/panel/issues/2308 (original)
 // @see https://github.com/pterodactyl/panel/issues/3009 ("bug" introduced as result)
-func (f *ConfigurationFile) parsePropertiesFile(file ufs.File) error {
+func (f *ConfigurationFile) parsePropertiesFile(file configFile) error {
 	b, err := io.ReadAll(file)
 	if err != nil {
 		return err
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pelican-dev/wings/internal/models"
@@ -21,7 +22,7 @@ import (
 )
 
 type Client interface {
-	GetBackupRemoteUploadURLs(ctx context.Context, backup string, size int64) (BackupRemoteUploadResponse, error)
+	GetBackupRemoteUploadURLs(ctx context.Context, backup string, size int64, partSize int64) (BackupRemoteUploadResponse, error)
 	GetInstallationScript(ctx context.Context, uuid string) (InstallationScript, error)
 	GetServerConfiguration(ctx context.Context, uuid string) (ServerConfigurationResponse, error)
 	GetServers(context context.Context, perPage int) ([]RawServerData, error)
@@ -39,11 +40,25 @@ type Client interface {
 type client struct {
 	httpClient  *http.Client
 	baseUrl     string
+	fallbacks   []*fallback
 	tokenId     string
 	token       string
 	maxAttempts int
 }
 
+// The amount of time that a Panel location is skipped for after it could not be
+// reached, before requests are attempted against it again.
+const unreachableCooldown = time.Second * 30
+
+// fallback is an additional location of the Panel that is used for idempotent
+// requests when the primary location cannot be reached.
+type fallback struct {
+	baseUrl string
+
+	mu          sync.Mutex
+	unreachable time.Time
+}
+
 // New returns a new HTTP request client that is used for making authenticated
 // requests to the Panel that this instance is running under.
 func New(base string, opts ...ClientOption) Client {
@@ -69,6 +84,19 @@ func WithCredentials(id, token string) ClientOption {
 	}
 }
 
+// WithFallbacks sets additional locations of the Panel that idempotent requests
+// are sent to when the primary location cannot be reached.
+func WithFallbacks(locations ...string) ClientOption {
+	return func(c *client) {
+		for _, l := range locations {
+			if l == "" {
+				continue
+			}
+			c.fallbacks = append(c.fallbacks, &fallback{baseUrl: strings.TrimSuffix(l, "/") + "/api/remote"})
+		}
+	}
+}
+
 // WithHttpClient sets the underlying HTTP client instance to use when making
 // requests to the Panel API.
 func WithHttpClient(httpClient *http.Client) ClientOption {
@@ -97,11 +125,26 @@ func (c *client) Post(ctx context.Context, path string, data interface{}) (*Resp
 	return c.request(ctx, http.MethodPost, path, bytes.NewBuffer(b))
 }
 
+// postIdempotent executes a HTTP POST request that is safe to send to a fallback
+// location of the Panel if the primary location cannot be reached.
+func (c *client) postIdempotent(ctx context.Context, path string, data interface{}) (*Response, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return c.requestWithFailover(ctx, true, http.MethodPost, path, bytes.NewBuffer(b))
+}
+
 // requestOnce creates a http request and executes it once. Prefer request()
 // over this method when possible. It appends the path to the endpoint of the
 // client and adds the authentication token to the request.
 func (c *client) requestOnce(ctx context.Context, method, path string, body io.Reader, opts ...func(r *http.Request)) (*Response, error) {
-	req, err := http.NewRequestWithContext(ctx, method, c.baseUrl+path, body)
+	return c.requestOnceTo(ctx, c.baseUrl, method, path, body, opts...)
+}
+
+// requestOnceTo executes a http request once against the given base URL.
+func (c *client) requestOnceTo(ctx context.Context, base, method, path string, body io.Reader, opts ...func(r *http.Request)) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, base+path, body)
 	if err != nil {
 		return nil, err
 	}
@@ -132,7 +175,18 @@ func (c *client) requestOnce(ctx context.Context, method, path string, body io.R
 // and adds the required authentication headers to the request that is being
 // created. Errors returned will be of the RequestError type if there was some
 // type of response from the API that can be parsed.
+//
+// GET requests are sent to the fallback locations of the Panel, if any are
+// configured, when the primary location cannot be reached.
 func (c *client) request(ctx context.Context, method, path string, body *bytes.Buffer, opts ...func(r *http.Request)) (*Response, error) {
+	return c.requestWithFailover(ctx, method == http.MethodGet, method, path, body, opts...)
+}
+
+// requestWithFailover executes an HTTP request against the Panel API in the same
+// way as request. If failover is true and the primary location of the Panel
+// cannot be reached, the request is attempted against each fallback location in
+// turn before backing off.
+func (c *client) requestWithFailover(ctx context.Context, failover bool, method, path string, body *bytes.Buffer, opts ...func(r *http.Request)) (*Response, error) {
 	var res *Response
 	err := backoff.Retry(func() error {
 		var b bytes.Buffer
@@ -144,7 +198,13 @@ func (c *client) request(ctx context.Context, method, path string, body *bytes.B
 				return backoff.Permanent(errors.Wrap(err, "http: failed to copy body buffer"))
 			}
 		}
-		r, err := c.requestOnce(ctx, method, path, &b, opts...)
+		var r *Response
+		var err error
+		if failover && len(c.fallbacks) > 0 {
+			r, err = c.requestOnceWithFallbacks(ctx, method, path, b.Bytes(), opts...)
+		} else {
+			r, err = c.requestOnce(ctx, method, path, &b, opts...)
+		}
 		if err != nil {
 			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 				return backoff.Permanent(err)
@@ -174,6 +234,67 @@ func (c *client) request(ctx context.Context, method, path string, body *bytes.B
 	return res, nil
 }
 
+// requestOnceWithFallbacks executes a request once against the primary location
+// of the Panel, and then against each fallback location until one of them can be
+// reached. Fallback locations that could not be reached recently are tried last.
+func (c *client) requestOnceWithFallbacks(ctx context.Context, method, path string, body []byte, opts ...func(r *http.Request)) (*Response, error) {
+	r, err := c.requestOnce(ctx, method, path, bytes.NewReader(body), opts...)
+	if !isUnreachable(r, err) || ctx.Err() != nil {
+		return r, err
+	}
+
+	var healthy, unhealthy []*fallback
+	for _, f := range c.fallbacks {
+		if f.isUnreachable() {
+			unhealthy = append(unhealthy, f)
+		} else {
+			healthy = append(healthy, f)
+		}
+	}
+	for _, f := range append(healthy, unhealthy...) {
+		if r != nil && r.Response != nil {
+			_ = r.Body.Close()
+		}
+		log.WithField("location", f.baseUrl).Debug("http: primary Panel location unreachable, attempting fallback")
+		r, err = c.requestOnceTo(ctx, f.baseUrl, method, path, bytes.NewReader(body), opts...)
+		if !isUnreachable(r, err) || ctx.Err() != nil {
+			f.setUnreachable(false)
+			return r, err
+		}
+		f.setUnreachable(true)
+	}
+	return r, err
+}
+
+// isUnreachable returns true if the request failed because the Panel could not
+// be reached, rather than the Panel returning an error for the request.
+func isUnreachable(r *Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch r.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (f *fallback) isUnreachable() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return time.Since(f.unreachable) < unreachableCooldown
+}
+
+func (f *fallback) setUnreachable(v bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if v {
+		f.unreachable = time.Now()
+	} else {
+		f.unreachable = time.Time{}
+	}
+}
+
 // backoff returns an exponential backoff function for use with remote API
 // requests. This will allow an API call to be executed approximately 10 times
 // before it is finally reported back as an error.
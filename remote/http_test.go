@@ -92,3 +92,34 @@ func TestPost(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, r)
 }
+
+func TestRequestFailover(t *testing.T) {
+	primary := 0
+	c, _ := createTestClient(func(rw http.ResponseWriter, r *http.Request) {
+		primary++
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	})
+	fallbacks := 0
+	f := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/test", r.URL.Path)
+		fallbacks++
+		rw.WriteHeader(http.StatusOK)
+	}))
+	c.fallbacks = []*fallback{{baseUrl: f.URL}}
+
+	// GET requests should be sent to the fallback when the primary is unavailable.
+	r, err := c.Get(context.Background(), "/test", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	assert.Equal(t, 1, primary)
+	assert.Equal(t, 1, fallbacks)
+
+	// Non-idempotent requests should never be sent to the fallback.
+	_, err = c.Post(context.Background(), "/test", nil)
+	assert.Error(t, err)
+	assert.Equal(t, 1, fallbacks)
+
+	_, err = c.postIdempotent(context.Background(), "/test", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fallbacks)
+}
@@ -146,9 +146,13 @@ func (c *client) ValidateSftpCredentials(ctx context.Context, request SftpAuthRe
 	return auth, nil
 }
 
-func (c *client) GetBackupRemoteUploadURLs(ctx context.Context, backup string, size int64) (BackupRemoteUploadResponse, error) {
+func (c *client) GetBackupRemoteUploadURLs(ctx context.Context, backup string, size int64, partSize int64) (BackupRemoteUploadResponse, error) {
 	var data BackupRemoteUploadResponse
-	res, err := c.Get(ctx, fmt.Sprintf("/backups/%s", backup), q{"size": strconv.FormatInt(size, 10)})
+	query := q{"size": strconv.FormatInt(size, 10)}
+	if partSize > 0 {
+		query["part_size"] = strconv.FormatInt(partSize, 10)
+	}
+	res, err := c.Get(ctx, fmt.Sprintf("/backups/%s", backup), query)
 	if err != nil {
 		return data, err
 	}
@@ -182,7 +186,7 @@ func (c *client) SendRestorationStatus(ctx context.Context, backup string, succe
 
 // SendActivityLogs sends activity logs back to the Panel for processing.
 func (c *client) SendActivityLogs(ctx context.Context, activity []models.Activity) error {
-	resp, err := c.Post(ctx, "/activity", d{"data": activity})
+	resp, err := c.postIdempotent(ctx, "/activity", d{"data": activity})
 	if err != nil {
 		return errors.WithStackIf(err)
 	}
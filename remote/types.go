@@ -59,6 +59,23 @@ type InstallationScript struct {
 	ContainerImage string `json:"container_image"`
 	Entrypoint     string `json:"entrypoint"`
 	Script         string `json:"script"`
+	// PostInstall is an optional egg defined command that is executed once the
+	// installation script has completed successfully.
+	PostInstall *PostInstallScript `json:"post_install"`
+}
+
+// PostInstallScript defines a one-time setup command that is executed in a fresh
+// container after a server has been installed, such as accepting an EULA or
+// generating a key that the installation script cannot easily handle.
+type PostInstallScript struct {
+	Command string `json:"command"`
+	// Timeout is the number of seconds the command is allowed to run for before
+	// the container is forcefully stopped. A value of zero uses a five minute
+	// timeout.
+	Timeout int `json:"timeout"`
+	// RunOnReinstall controls if the command is also executed when a server is
+	// being reinstalled, rather than only on the initial installation.
+	RunOnReinstall bool `json:"run_on_reinstall"`
 }
 
 // RawServerData is a raw response from the API for a server.
@@ -96,6 +113,9 @@ type OutputLineMatcher struct {
 	// `regex:` which indicates we want to match against the regex expression.
 	raw []byte
 	reg *regexp.Regexp
+	// err is set when the matcher is marked as a regex but the expression
+	// could not be compiled.
+	err error
 }
 
 // Matches determines if the provided byte string matches the given regex or
@@ -107,11 +127,23 @@ func (olm *OutputLineMatcher) Matches(s []byte) bool {
 	return olm.reg.Match(s)
 }
 
+// Err returns the error encountered while compiling the regex for the matcher,
+// or nil if the matcher is valid.
+func (olm *OutputLineMatcher) Err() error {
+	return olm.err
+}
+
 // String returns the matcher's raw comparison string.
 func (olm *OutputLineMatcher) String() string {
 	return string(olm.raw)
 }
 
+// MarshalJSON returns the matcher as the raw string it was created from so that
+// it can be reported back exactly as it was received from the Panel.
+func (olm OutputLineMatcher) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(olm.raw))
+}
+
 // UnmarshalJSON unmarshals the startup lines into individual structs for easier
 // matching abilities.
 func (olm *OutputLineMatcher) UnmarshalJSON(data []byte) error {
@@ -125,6 +157,7 @@ func (olm *OutputLineMatcher) UnmarshalJSON(data []byte) error {
 		r, err := regexp.Compile(strings.TrimPrefix(string(olm.raw), "regex:"))
 		if err != nil {
 			log.WithField("error", err).WithField("raw", string(olm.raw)).Warn("failed to compile output line marked as being regex")
+			olm.err = err
 		}
 		olm.reg = r
 	}
@@ -136,6 +169,10 @@ func (olm *OutputLineMatcher) UnmarshalJSON(data []byte) error {
 type ProcessStopConfiguration struct {
 	Type  string `json:"type"`
 	Value string `json:"value"`
+	// GracePeriod is the number of seconds to wait for the process to stop on its
+	// own before escalating to a SIGKILL. A value of zero means the default grace
+	// period for the specific action being performed is used instead.
+	GracePeriod int `json:"grace_period"`
 }
 
 // ProcessConfiguration defines the process configuration for a given server
@@ -152,6 +189,35 @@ type ProcessConfiguration struct {
 	ConfigurationFiles []parser.ConfigurationFile `json:"configs"`
 }
 
+// InvalidOutputLineMatcher describes a startup line that was marked as being a
+// regex but could not be compiled, and is therefore ignored when checking the
+// console output of a server.
+type InvalidOutputLineMatcher struct {
+	Line  string `json:"line"`
+	Error string `json:"error"`
+}
+
+// ValidateDoneLines removes any startup done lines that failed to compile from
+// the configuration and returns them. Without this an invalid regex would fall
+// back to a plain string comparison against the raw "regex:" value and never
+// match, leaving the server stuck in the starting state.
+func (pc *ProcessConfiguration) ValidateDoneLines() []InvalidOutputLineMatcher {
+	var invalid []InvalidOutputLineMatcher
+	valid := pc.Startup.Done[:0]
+	for _, l := range pc.Startup.Done {
+		if l == nil {
+			continue
+		}
+		if err := l.Err(); err != nil {
+			invalid = append(invalid, InvalidOutputLineMatcher{Line: l.String(), Error: err.Error()})
+			continue
+		}
+		valid = append(valid, l)
+	}
+	pc.Startup.Done = valid
+	return invalid
+}
+
 type BackupRemoteUploadResponse struct {
 	Parts    []string `json:"parts"`
 	PartSize int64    `json:"part_size"`
@@ -0,0 +1,24 @@
+package remote
+
+import (
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessConfiguration_ValidateDoneLines(t *testing.T) {
+	var pc ProcessConfiguration
+	err := json.Unmarshal([]byte(`{"startup":{"done":["regex:^Done \\(","regex:[invalid","Server started"]}}`), &pc)
+	require.NoError(t, err)
+
+	invalid := pc.ValidateDoneLines()
+	require.Len(t, invalid, 1)
+	assert.Equal(t, "regex:[invalid", invalid[0].Line)
+	assert.NotEmpty(t, invalid[0].Error)
+
+	require.Len(t, pc.Startup.Done, 2)
+	assert.True(t, pc.Startup.Done[0].Matches([]byte("Done (1.23s)!")))
+	assert.True(t, pc.Startup.Done[1].Matches([]byte("[INFO] Server started")))
+}
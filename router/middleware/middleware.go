@@ -4,6 +4,8 @@ import (
 	"crypto/subtle"
 	"io"
 	"net/http"
+	"regexp"
+	"slices"
 	"strings"
 
 	"emperror.dev/errors"
@@ -16,6 +18,11 @@ import (
 	"github.com/pelican-dev/wings/server"
 )
 
+// validRequestIDRegex limits the incoming request IDs that will be accepted to a
+// reasonable length and character set, so that arbitrary data cannot be injected
+// into the logs or response headers.
+var validRequestIDRegex = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,128}$`)
+
 // AttachRequestID attaches a unique ID to the incoming HTTP request so that any
 // errors that are generated or returned to the client will include this reference
 // allowing for an easier time identifying the specific request that failed for
@@ -24,9 +31,16 @@ import (
 // If you are using a tool such as Sentry or Bugsnag for error reporting this is
 // a great location to also attach this request ID to your error handling logic
 // so that you can easily cross-reference the errors.
+//
+// If the incoming request already includes a valid X-Request-Id header (for
+// example, when it was sent by the Panel) that value is used instead, allowing
+// the same request to be located in the logs of both systems.
 func AttachRequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := uuid.New().String()
+		id := c.GetHeader("X-Request-Id")
+		if !validRequestIDRegex.MatchString(id) {
+			id = uuid.New().String()
+		}
 		c.Set("request_id", id)
 		c.Set("logger", log.WithField("request_id", id))
 		c.Header("X-Request-Id", id)
@@ -79,6 +93,10 @@ func CaptureErrors() gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "The data passed in the request was not in a parsable format. Please try again."})
 			return
 		}
+		if errors.Is(err.Err, server.ErrServerDataMissing) {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "The data directory for this server is missing from the node and must be repaired before it can be used.", "request_id": c.Writer.Header().Get("X-Request-Id")})
+			return
+		}
 		captured := NewError(err.Err)
 		if status, msg := captured.asFilesystemError(); msg != "" {
 			c.AbortWithStatusJSON(status, gin.H{"error": msg, "request_id": c.Writer.Header().Get("X-Request-Id")})
@@ -92,7 +110,7 @@ func CaptureErrors() gin.HandlerFunc {
 // the requests.
 func SetAccessControlHeaders() gin.HandlerFunc {
 	cfg := config.Get()
-	origins := cfg.AllowedOrigins
+	origins := slices.Concat(cfg.AllowedOrigins, cfg.PanelFallbackLocations)
 	location := cfg.PanelLocation
 	allowPrivateNetwork := cfg.AllowCORSPrivateNetwork
 
@@ -100,7 +118,8 @@ func SetAccessControlHeaders() gin.HandlerFunc {
 		c.Header("Access-Control-Allow-Origin", location)
 		c.Header("Access-Control-Allow-Credentials", "true")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PATCH, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Accept, Accept-Encoding, Authorization, Cache-Control, Content-Type, Content-Length, Origin, X-Real-IP, X-CSRF-Token")
+		c.Header("Access-Control-Allow-Headers", "Accept, Accept-Encoding, Authorization, Cache-Control, Content-Type, Content-Length, Origin, X-Real-IP, X-CSRF-Token, X-Request-Id")
+		c.Header("Access-Control-Expose-Headers", "X-Request-Id")
 
 		// CORS for Private Networks (RFC1918)
 		// @see https://developer.chrome.com/blog/private-network-access-update/?utm_source=devtools
@@ -159,6 +178,19 @@ func ServerExists() gin.HandlerFunc {
 	}
 }
 
+// ServerDataExists ensures that the data directory for the server in the request
+// context is present on the disk before continuing, so that the caller receives a
+// clear error rather than a confusing filesystem failure.
+func ServerDataExists() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := ExtractServer(c).CheckDataDirectory(); err != nil {
+			CaptureAndAbort(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
 // RequireAuthorization authenticates the request token against the given
 // permission string, ensuring that if it is a server permission, the token has
 // control over that server. If it is a global token, this will ensure that the
@@ -187,6 +219,20 @@ func RequireAuthorization() gin.HandlerFunc {
 	}
 }
 
+// RequireClientCertificate ensures that the request was made over TLS using a
+// client certificate that was verified against the configured certificate
+// authority. This is a no-op unless mutual TLS has been enabled for the API.
+func RequireClientCertificate() gin.HandlerFunc {
+	required := config.Get().Api.Ssl.RequireClientCertificate
+	return func(c *gin.Context) {
+		if required && (c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "A valid client certificate is required to access this endpoint."})
+			return
+		}
+		c.Next()
+	}
+}
+
 // RemoteDownloadEnabled checks if remote downloads are enabled for this instance
 // and if not aborts the request.
 func RemoteDownloadEnabled() gin.HandlerFunc {
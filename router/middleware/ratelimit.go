@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+
+	"github.com/pelican-dev/wings/config"
+	"github.com/pelican-dev/wings/router/tokens"
+)
+
+// The amount of time after which an IP address that has not made any requests
+// is removed from the rate limiter.
+const rateLimitVisitorTTL = time.Minute * 10
+
+type rateLimitVisitor struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// ipRateLimiter tracks a token bucket rate limiter for each IP address that has
+// made a request to an endpoint.
+type ipRateLimiter struct {
+	mu        sync.Mutex
+	visitors  map[string]*rateLimitVisitor
+	limit     rate.Limit
+	burst     int
+	lastClean time.Time
+}
+
+// Returns the rate limiter for the given IP address, creating one if it does not
+// already exist. Visitors that have not been seen recently are periodically
+// removed so that the map does not grow forever.
+func (l *ipRateLimiter) get(ip string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastClean) > rateLimitVisitorTTL {
+		for k, v := range l.visitors {
+			if now.Sub(v.lastSeen) > rateLimitVisitorTTL {
+				delete(l.visitors, k)
+			}
+		}
+		l.lastClean = now
+	}
+
+	v, ok := l.visitors[ip]
+	if !ok {
+		v = &rateLimitVisitor{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.visitors[ip] = v
+	}
+	v.lastSeen = now
+	return v.limiter
+}
+
+// TokenRateLimit applies a per-IP rate limit to endpoints that are authenticated
+// using signed tokens, returning a 429 response once the limit is exceeded. A
+// request made with a missing or invalid token counts more heavily against the
+// limit than any other request.
+func TokenRateLimit() gin.HandlerFunc {
+	cfg := config.Get().Api.TokenRateLimit
+	if !cfg.Enabled || cfg.RequestsPerMinute <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	burst := cfg.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	l := &ipRateLimiter{
+		visitors: make(map[string]*rateLimitVisitor),
+		limit:    rate.Limit(float64(cfg.RequestsPerMinute) / 60),
+		burst:    burst,
+	}
+
+	return func(c *gin.Context) {
+		limiter := l.get(c.ClientIP())
+		if !limiter.Allow() {
+			c.Header("Retry-After", "60")
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests have been made to this endpoint, please try again later."})
+			return
+		}
+
+		c.Next()
+
+		// Count requests with a bad token more heavily against the limit. Reserving
+		// the tokens without ever canceling the reservation puts the limiter into
+		// debt, so that subsequent requests are denied until it has recovered.
+		if penalty := cfg.FailurePenalty - 1; penalty > 0 && isTokenFailure(c) {
+			if penalty > burst {
+				penalty = burst
+			}
+			limiter.ReserveN(time.Now(), penalty)
+		}
+	}
+}
+
+// isTokenFailure reports whether the request was rejected because the token or
+// authorization header provided was missing or invalid. Other failures, such as
+// a file not existing, are not counted as a token failure.
+func isTokenFailure(c *gin.Context) bool {
+	if c.Writer.Status() == http.StatusUnauthorized || c.Writer.Status() == http.StatusForbidden {
+		return true
+	}
+	for _, err := range c.Errors {
+		if tokens.IsInvalidTokenError(err.Err) {
+			return true
+		}
+	}
+	return false
+}
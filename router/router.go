@@ -38,10 +38,15 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 		return ""
 	}))
 
+	// The health endpoint is public so that it can be used by load balancers and
+	// monitoring services without needing to be given credentials for the node.
+	router.GET("/health", getHealth)
+
 	// These routes use signed URLs to validate access to the resource being requested.
-	router.GET("/download/backup", getDownloadBackup)
-	router.GET("/download/file", getDownloadFile)
-	router.POST("/upload/file", postServerUploadFiles)
+	tokenRateLimit := middleware.TokenRateLimit()
+	router.GET("/download/backup", tokenRateLimit, getDownloadBackup)
+	router.GET("/download/file", tokenRateLimit, getDownloadFile)
+	router.POST("/upload/file", tokenRateLimit, postServerUploadFiles)
 
 	// This route is special it sits above all the other requests because we are
 	// using a JWT to authorize access to it, therefore it needs to be publicly
@@ -51,35 +56,58 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 	// This request is called by another daemon when a server is going to be transferred out.
 	// This request does not need the AuthorizationMiddleware as the panel should never call it
 	// and requests are authenticated through a JWT the panel issues to the other daemon.
-	router.POST("/api/transfers", postTransfers)
+	router.POST("/api/transfers", tokenRateLimit, postTransfers)
 
 	// All the routes beyond this mount will use an authorization middleware
 	// and will not be accessible without the correct Authorization header provided.
-	protected := router.Use(middleware.RequireAuthorization())
+	protected := router.Use(middleware.RequireClientCertificate(), middleware.RequireAuthorization())
 	protected.POST("/api/update", postUpdateConfiguration)
 	protected.GET("/api/system", getSystemInformation)
 	protected.GET("/api/system/docker/disk", getDockerDiskUsage)
 	protected.DELETE("/api/system/docker/image/prune", pruneDockerImages)
 	protected.GET("/api/system/ips", getSystemIps)
 	protected.GET("/api/system/utilization", getSystemUtilization)
+	protected.GET("/api/system/drain", getSystemDrain)
+	protected.POST("/api/system/drain", postSystemDrain)
+	protected.DELETE("/api/system/drain", deleteSystemDrain)
+	protected.GET("/api/system/activity", getSystemActivity)
+	protected.GET("/api/system/logs", getSystemLogs)
+	protected.GET("/api/node-events", getNodeEvents)
+	protected.POST("/api/system/activity/purge", postSystemActivityPurge)
 	protected.GET("/api/servers", getAllServers)
 	protected.POST("/api/servers", postCreateServer)
+	protected.POST("/api/servers/commands", postServersCommands)
 	protected.DELETE("/api/transfers/:server", deleteTransfer)
 
 	// These are server specific routes, and require that the request be authorized, and
 	// that the server exist on the Daemon.
 	server := router.Group("/api/servers/:server")
-	server.Use(middleware.RequireAuthorization(), middleware.ServerExists())
+	server.Use(middleware.RequireClientCertificate(), middleware.RequireAuthorization(), middleware.ServerExists())
 	{
 		server.GET("", getServer)
 		server.DELETE("", deleteServer)
 
+		server.GET("/limits", getServerLimits)
+		server.GET("/resources", getServerResources)
+		server.GET("/configuration", getServerConfiguration)
+		server.GET("/sessions", getServerSessions)
 		server.GET("/logs", getServerLogs)
+		server.GET("/logs/search", getServerLogsSearch)
+		server.GET("/events", getServerEvents)
+		server.GET("/install-logs", getServerInstallLogs)
 		server.POST("/power", postServerPower)
+		server.GET("/power/scheduled", getServerScheduledPower)
+		server.POST("/power/scheduled", postServerScheduledPower)
+		server.DELETE("/power/scheduled/:id", deleteServerScheduledPower)
 		server.POST("/commands", postServerCommands)
+		server.GET("/processes", getServerProcesses)
+		server.POST("/processes/:pid/signal", postServerProcessSignal)
 		server.POST("/install", postServerInstall)
+		server.DELETE("/install", deleteServerInstall)
 		server.POST("/reinstall", postServerReinstall)
 		server.POST("/sync", postServerSync)
+		server.POST("/repair", postServerRepair)
+		server.POST("/clone", postServerClone)
 		server.POST("/ws/deny", postServerDenyWSTokens)
 
 		// This archive request causes the archive to start being created
@@ -87,21 +115,36 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 		server.POST("/transfer", postServerTransfer)
 		server.DELETE("/transfer", deleteServerTransfer)
 
+		// Lists the backups for a server that are stored on this node.
+		server.GET("/backups", getServerBackups)
+
 		// Deletes all backups for a server
 		server.DELETE("deleteAllBackups", deleteAllServerBackups)
 
 		files := server.Group("/files")
+		files.Use(middleware.ServerDataExists())
 		{
 			files.GET("/contents", getServerFileContents)
 			files.GET("/list-directory", getServerListDirectory)
+			files.POST("/stat-batch", postServerStatFiles)
+			files.GET("/summary", getServerDirectorySummary)
 			files.PUT("/rename", putServerRenameFiles)
 			files.POST("/copy", postServerCopyFile)
 			files.POST("/write", postServerWriteFile)
 			files.POST("/create-directory", postServerCreateDirectory)
 			files.POST("/delete", postServerDeleteFiles)
 			files.POST("/compress", postServerCompressFiles)
+			files.POST("/download-archive", postServerDownloadArchive)
 			files.POST("/decompress", postServerDecompressFiles)
 			files.POST("/chmod", postServerChmodFile)
+			files.POST("/chtimes", postServerChtimesFile)
+			files.POST("/truncate", postServerTruncateFile)
+			files.POST("/repair-permissions", postServerRepairPermissions)
+			files.POST("/uploads", postServerCreateUpload)
+			files.GET("/uploads/:upload", getServerUpload)
+			files.PUT("/uploads/:upload", putServerUploadChunk)
+			files.POST("/uploads/:upload/finalize", postServerFinalizeUpload)
+			files.DELETE("/uploads/:upload", deleteServerUpload)
 			files.GET("/search", getFilesBySearch)
 
 			files.GET("/pull", middleware.RemoteDownloadEnabled(), getServerPullingFiles)
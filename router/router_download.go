@@ -12,9 +12,23 @@ import (
 
 	"github.com/pelican-dev/wings/router/middleware"
 	"github.com/pelican-dev/wings/router/tokens"
+	"github.com/pelican-dev/wings/server"
 	"github.com/pelican-dev/wings/server/backup"
 )
 
+// Tracks a new download for the server, aborting the request if the server
+// already has too many downloads in progress. The returned function must be
+// called once the download has finished.
+func acquireDownload(c *gin.Context, s *server.Server) (func(), bool) {
+	release, ok := s.AcquireDownload()
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"error": "This server has too many downloads in progress, please try again once one has finished.",
+		})
+	}
+	return release, ok
+}
+
 // Handle a download request for a server backup.
 func getDownloadBackup(c *gin.Context) {
 	client := middleware.ExtractApiClient(c)
@@ -28,7 +42,8 @@ func getDownloadBackup(c *gin.Context) {
 	}
 
 	// Get the server using the UUID from the token.
-	if _, ok := manager.Get(token.ServerUuid); !ok || !token.IsUniqueRequest() {
+	s, ok := manager.Get(token.ServerUuid)
+	if !ok || !token.IsUniqueRequest() {
 		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
 			"error": "The requested resource was not found on this server.",
 		})
@@ -56,6 +71,12 @@ func getDownloadBackup(c *gin.Context) {
 		return
 	}
 
+	release, ok := acquireDownload(c, s)
+	if !ok {
+		return
+	}
+	defer release()
+
 	// The use of `os` here is safe as backups are not stored within server
 	// accessible directories.
 	f, err := os.Open(b.Path())
@@ -94,6 +115,12 @@ func getDownloadFile(c *gin.Context) {
 		return
 	}
 
+	release, ok := acquireDownload(c, s)
+	if !ok {
+		return
+	}
+	defer release()
+
 	f, st, err := s.Filesystem().File(token.FilePath)
 	if err != nil {
 		middleware.CaptureAndAbort(c, err)
@@ -0,0 +1,106 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/pelican-dev/wings/config"
+	"github.com/pelican-dev/wings/environment"
+	"github.com/pelican-dev/wings/internal/database"
+)
+
+// The amount of time that the result of the health checks is reused for before
+// the checks are performed again.
+const healthCacheTTL = time.Second * 5
+
+// HealthReport is the result of checking each of the dependencies of Wings.
+type HealthReport struct {
+	Status    string            `json:"status"`
+	Checks    map[string]string `json:"checks"`
+	CheckedAt time.Time         `json:"checked_at"`
+}
+
+var healthCache struct {
+	mu     sync.Mutex
+	report *HealthReport
+}
+
+// getHealth returns the health of Wings and each of the dependencies it relies
+// on. A 503 status code is returned if any of the checks fail, allowing this to
+// be used directly by load balancers. Results are cached for a short period so
+// that frequent polling does not put any meaningful load on the node.
+func getHealth(c *gin.Context) {
+	report := checkHealth(c.Request.Context())
+	status := http.StatusOK
+	if report.Status != "healthy" {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
+
+func checkHealth(ctx context.Context) HealthReport {
+	healthCache.mu.Lock()
+	defer healthCache.mu.Unlock()
+
+	if r := healthCache.report; r != nil && time.Since(r.CheckedAt) < healthCacheTTL {
+		return *r
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*2)
+	defer cancel()
+
+	checks := map[string]func(context.Context) error{
+		"config":   checkConfigHealth,
+		"docker":   checkDockerHealth,
+		"database": database.Ping,
+		"disk":     checkDiskHealth,
+	}
+	report := HealthReport{Status: "healthy", Checks: make(map[string]string, len(checks))}
+	for name, check := range checks {
+		if err := check(ctx); err != nil {
+			log.WithField("check", name).WithField("error", err).Debug("router: health check failed")
+			report.Checks[name] = "failing"
+			report.Status = "degraded"
+			continue
+		}
+		report.Checks[name] = "ok"
+	}
+	report.CheckedAt = time.Now()
+	healthCache.report = &report
+
+	return report
+}
+
+func checkConfigHealth(_ context.Context) error {
+	if config.Get().AuthenticationToken == "" {
+		return errors.New("configuration is not loaded")
+	}
+	return nil
+}
+
+func checkDockerHealth(ctx context.Context) error {
+	cli, err := environment.Docker()
+	if err != nil {
+		return err
+	}
+	_, err = cli.Ping(ctx)
+	return err
+}
+
+// checkDiskHealth ensures that files can be written to the server data
+// directory.
+func checkDiskHealth(_ context.Context) error {
+	f, err := os.CreateTemp(config.Get().System.Data, ".wings-health-*")
+	if err != nil {
+		return err
+	}
+	_ = f.Close()
+	return os.Remove(f.Name())
+}
@@ -5,12 +5,19 @@ import (
 	"github.com/pelican-dev/wings/config"
 	"net/http"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
 	"github.com/gin-gonic/gin"
 
+	"github.com/pelican-dev/wings/environment"
+	"github.com/pelican-dev/wings/environment/docker"
+	"github.com/pelican-dev/wings/internal/database"
+	"github.com/pelican-dev/wings/internal/models"
 	"github.com/pelican-dev/wings/router/downloader"
 	"github.com/pelican-dev/wings/router/middleware"
 	"github.com/pelican-dev/wings/router/tokens"
@@ -23,15 +30,48 @@ func getServer(c *gin.Context) {
 	c.JSON(http.StatusOK, ExtractServer(c).ToAPIResponse())
 }
 
+// Returns the resource limits and allocations for a server as they are enforced
+// by this node.
+func getServerLimits(c *gin.Context) {
+	c.JSON(http.StatusOK, ExtractServer(c).EnforcedLimits())
+}
+
+// Returns a point-in-time snapshot of the resource usage for a server without
+// needing to subscribe to the event stream.
+func getServerResources(c *gin.Context) {
+	c.JSON(http.StatusOK, ExtractServer(c).Proc())
+}
+
+// Returns the number of websocket connections, downloads and SFTP sessions that
+// are currently open for a server.
+func getServerSessions(c *gin.Context) {
+	c.JSON(http.StatusOK, ExtractServer(c).ActiveSessions())
+}
+
+// Returns the process configuration for a server exactly as it was parsed by
+// Wings from the Panel response, along with the egg specific settings. This is
+// useful when trying to determine why a configuration file replacement or the
+// startup detection for a server is not behaving as expected.
+func getServerConfiguration(c *gin.Context) {
+	s := ExtractServer(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"process":            s.ProcessConfiguration(),
+		"invalid_done_lines": s.InvalidDoneLines(),
+		"egg":                s.Config().Egg,
+	})
+}
+
 // Returns the logs for a given server instance.
 func getServerLogs(c *gin.Context) {
 	s := ExtractServer(c)
 
+	limit := min(max(config.Get().System.ConsoleHistoryLines, 1), maxConsoleHistoryLines)
 	l, _ := strconv.Atoi(c.DefaultQuery("size", "100"))
 	if l <= 0 {
-		l = 100
-	} else if l > 100 {
-		l = 100
+		l = min(100, limit)
+	} else if l > limit {
+		l = limit
 	}
 
 	out, err := s.ReadLogfile(l)
@@ -43,6 +83,92 @@ func getServerLogs(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": out})
 }
 
+// The upper bound for the number of lines of console history that can be returned
+// for a server, regardless of the configured maximum.
+const maxConsoleHistoryLines = 10_000
+
+// The maximum amount of log data that is scanned when searching the logs for a
+// server, and the maximum number of matching lines that can be requested.
+const (
+	maxLogSearchScanBytes = 256 * 1024 * 1024
+	maxLogSearchResults   = 1000
+)
+
+// Searches the entire log history for a server, including rotated log files, for
+// lines containing the query. If "regex" is set the query is treated as a
+// regular expression instead. The search can be limited to a time range using
+// the "since" and "until" parameters as RFC3339 timestamps.
+func getServerLogsSearch(c *gin.Context) {
+	s := ExtractServer(c)
+
+	query := c.Query("query")
+	if query == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "A search query must be provided."})
+		return
+	}
+	opts := environment.LogSearchOptions{
+		Match:        func(line string) bool { return strings.Contains(line, query) },
+		MaxScanBytes: maxLogSearchScanBytes,
+	}
+	if c.Query("regex") == "true" {
+		re, err := regexp.Compile(query)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "The search query is not a valid regular expression."})
+			return
+		}
+		opts.Match = re.MatchString
+	}
+
+	l, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	opts.MaxResults = min(max(l, 1), maxLogSearchResults)
+
+	for k, t := range map[string]*time.Time{"since": &opts.Since, "until": &opts.Until} {
+		v := c.Query(k)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "The " + k + " parameter must be an RFC3339 timestamp."})
+			return
+		}
+		*t = parsed
+	}
+
+	res, err := s.Environment.SearchLog(c.Request.Context(), opts)
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// Returns the most recent installation logs for a given server instance.
+func getServerInstallLogs(c *gin.Context) {
+	s := ExtractServer(c)
+
+	l, _ := strconv.Atoi(c.DefaultQuery("size", "500"))
+	if l <= 0 {
+		l = 500
+	} else if l > 5000 {
+		l = 5000
+	}
+
+	out, err := s.ReadInstallLog(l)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "No installation logs were found for this server.",
+			})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
+
 // Handles a request to control the power state of a server. If the action being passed
 // through is invalid a 404 is returned. Otherwise, a HTTP/202 Accepted response is returned
 // and the actual power action is run asynchronously so that we don't have to block the
@@ -57,6 +183,9 @@ func postServerPower(c *gin.Context) {
 	var data struct {
 		Action      server.PowerAction `json:"action"`
 		WaitSeconds int                `json:"wait_seconds"`
+		// If set when starting the server, the configuration files defined by the
+		// egg are not updated for this boot.
+		SafeMode bool `json:"safe_mode"`
 	}
 
 	if err := c.BindJSON(&data); err != nil {
@@ -83,6 +212,20 @@ func postServerPower(c *gin.Context) {
 		return
 	}
 
+	if data.Action.IsStart() && server.IsDraining() {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Cannot start or restart a server while the node is draining for maintenance.",
+		})
+		return
+	}
+
+	if data.Action.IsStart() {
+		if err := s.CheckDataDirectory(); err != nil {
+			middleware.CaptureAndAbort(c, err)
+			return
+		}
+	}
+
 	// Pass the actual heavy processing off to a separate thread to handle so that
 	// we can immediately return a response from the server. Some of these actions
 	// can take quite some time, especially stopping or restarting.
@@ -90,7 +233,11 @@ func postServerPower(c *gin.Context) {
 		if data.WaitSeconds < 0 || data.WaitSeconds > 300 {
 			data.WaitSeconds = 30
 		}
-		if err := s.HandlePowerAction(data.Action, data.WaitSeconds); err != nil {
+		handle := s.HandlePowerAction
+		if data.SafeMode {
+			handle = s.HandleSafeModePowerAction
+		}
+		if err := handle(data.Action, data.WaitSeconds); err != nil {
 			if errors.Is(err, context.DeadlineExceeded) {
 				s.Log().WithField("action", data.Action).WithField("error", err).Warn("could not process server power action")
 			} else if errors.Is(err, server.ErrIsRunning) {
@@ -109,30 +256,117 @@ func postServerPower(c *gin.Context) {
 func postServerCommands(c *gin.Context) {
 	s := ExtractServer(c)
 
-	if running, err := s.Environment.IsRunning(c.Request.Context()); err != nil {
+	var data struct {
+		Commands []string `json:"commands"`
+	}
+	// BindJSON sends 400 if the request fails, all we need to do is return
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	// The rate limit is checked for the whole batch before any of the commands are
+	// sent, so that a request is never rejected part way through.
+	if err := s.SendCommands(c.Request.Context(), data.Commands); err != nil {
+		switch {
+		case errors.Is(err, server.ErrServerNotRunning):
+			c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+				"error": "Cannot send commands to a stopped server instance.",
+			})
+			return
+		case errors.Is(err, server.ErrCommandRateLimited):
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "Commands are being sent to this server too quickly.",
+			})
+			return
+		}
+		s.Log().WithFields(log.Fields{"commands": data.Commands, "error": err}).Warn("failed to send command to server instance")
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Returns the processes currently running inside the server container.
+func getServerProcesses(c *gin.Context) {
+	s := ExtractServer(c)
+
+	e, ok := s.Environment.(*docker.Environment)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The environment for this server does not support listing processes.",
+		})
+		return
+	}
+
+	if running, err := e.IsRunning(c.Request.Context()); err != nil {
 		middleware.CaptureAndAbort(c, err)
 		return
 	} else if !running {
 		c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
-			"error": "Cannot send commands to a stopped server instance.",
+			"error": "Cannot list processes for a stopped server instance.",
+		})
+		return
+	}
+
+	processes, err := e.Processes(c.Request.Context())
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": processes})
+}
+
+// Sends a signal to a single process running inside the server container. The
+// process ID must be the ID of the process within the container, as returned
+// by the processes endpoint.
+func postServerProcessSignal(c *gin.Context) {
+	s := ExtractServer(c)
+
+	e, ok := s.Environment.(*docker.Environment)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The environment for this server does not support signaling processes.",
+		})
+		return
+	}
+
+	pid, err := strconv.Atoi(c.Param("pid"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The process ID provided was not valid.",
 		})
 		return
 	}
 
 	var data struct {
-		Commands []string `json:"commands"`
+		Signal string `json:"signal"`
 	}
-	// BindJSON sends 400 if the request fails, all we need to do is return
 	if err := c.BindJSON(&data); err != nil {
 		return
 	}
+	if data.Signal == "" {
+		data.Signal = "SIGTERM"
+	}
 
-	for _, command := range data.Commands {
-		if err := s.Environment.SendCommand(command); err != nil {
-			s.Log().WithFields(log.Fields{"command": command, "error": err}).Warn("failed to send command to server instance")
+	if err := e.SignalProcess(c.Request.Context(), pid, data.Signal); err != nil {
+		if errors.Is(err, docker.ErrInvalidProcess) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "The requested process does not exist in this server instance, or cannot be signaled.",
+			})
+			return
+		}
+		if errors.Is(err, docker.ErrInvalidSignal) {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+				"error": "The signal provided is not supported.",
+			})
+			return
 		}
+		middleware.CaptureAndAbort(c, err)
+		return
 	}
 
+	s.Log().WithFields(log.Fields{"pid": pid, "signal": data.Signal}).Info("sent signal to process in server container")
+
 	c.Status(http.StatusNoContent)
 }
 
@@ -150,6 +384,82 @@ func postServerSync(c *gin.Context) {
 	}
 }
 
+// Copies all the files from another server on this node into the data directory
+// of the server. The copy is performed in a background thread, and the progress
+// of it is published to the server's console.
+func postServerClone(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		Source string `binding:"required" json:"source"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	src, ok := middleware.ExtractManager(c).Get(data.Source)
+	if !ok || src.ID() == s.ID() {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The source server could not be found on this node.",
+		})
+		return
+	}
+	if s.IsRunning() {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "Cannot clone files into a server that is currently running.",
+		})
+		return
+	}
+	if s.IsInstalling() || s.IsTransferring() || s.IsRestoring() {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "Cannot clone files into a server that is currently busy.",
+		})
+		return
+	}
+
+	size, err := src.Filesystem().DiskUsage(true)
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	if err := s.Filesystem().HasSpaceFor(size); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	go func(s *server.Server, src *server.Server) {
+		if err := s.CloneFrom(s.Context(), src); err != nil {
+			s.Log().WithField("source", src.ID()).WithField("error", err).Error("failed to clone files from source server")
+		}
+	}(s, src)
+
+	c.Status(http.StatusAccepted)
+}
+
+// Recreates the data directory for a server if it has gone missing from the disk. This
+// will not restore any of the server's files, it only allows the server to be used again
+// once the operator has resolved the underlying storage issue.
+func postServerRepair(c *gin.Context) {
+	s := ExtractServer(c)
+
+	if err := s.CheckDataDirectory(); err == nil {
+		c.Status(http.StatusNoContent)
+		return
+	} else if !errors.Is(err, server.ErrServerDataMissing) {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	s.Log().Warn("server data directory is missing, recreating it")
+	if err := s.EnsureDataDirectoryExists(); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	s.Filesystem().HasSpaceAvailable(true)
+
+	c.Status(http.StatusNoContent)
+}
+
 // Performs a server installation in a background thread.
 func postServerInstall(c *gin.Context) {
 	s := ExtractServer(c)
@@ -169,6 +479,22 @@ func postServerInstall(c *gin.Context) {
 	c.Status(http.StatusAccepted)
 }
 
+// Cancels the installation process that is currently running for a server. The
+// installation container is stopped and removed, and the Panel is notified that
+// the installation did not complete.
+func deleteServerInstall(c *gin.Context) {
+	s := ExtractServer(c)
+
+	if !s.CancelInstall() {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "There is no installation process currently running for this server.",
+		})
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
 // Reinstalls a server.
 func postServerReinstall(c *gin.Context) {
 	s := ExtractServer(c)
@@ -203,12 +529,21 @@ func deleteServer(c *gin.Context) {
 	// the server has been successfully transferred to another node, and
 	// the client needs to switch to the new node.
 	if s.IsTransferring() {
-		s.Events().Publish(server.TransferStatusEvent, transfer.StatusCompleted)
+		s.PublishTransferStatus(string(transfer.StatusCompleted))
 	}
 	s.Events().Publish(server.DeletedEvent, nil)
 
 	s.CleanupForDestroy()
 
+	// Remove any pending scheduled power actions for the server.
+	if err := database.Instance().Where("server = ?", s.ID()).Delete(&models.ScheduledPowerAction{}).Error; err != nil {
+		s.Log().WithField("error", err).Warn("failed to remove scheduled power actions for server")
+	}
+
+	// Remove the project quota from the server's data directory so that the limit
+	// is not left behind on the filesystem.
+	s.RemoveProjectQuota()
+
 	// Remove any pending remote file downloads for the server.
 	for _, dl := range downloader.ByServer(s.ID()) {
 		dl.Cancel()
@@ -238,10 +573,8 @@ func deleteServer(c *gin.Context) {
 	// so we don't want to block the HTTP call while waiting on this.
 	go func(s *server.Server) {
 		fs := s.Filesystem()
-		p := fs.Path()
-		_ = fs.UnixFS().Close()
-		if err := os.RemoveAll(p); err != nil {
-			log.WithFields(log.Fields{"path": p, "error": err}).Warn("failed to remove server files during deletion process")
+		if err := fs.Destroy(); err != nil {
+			log.WithFields(log.Fields{"path": fs.Path(), "error": err}).Warn("failed to remove server files during deletion process")
 		}
 	}(s)
 
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"fmt"
 	"net/http"
 	"os"
 	"strings"
@@ -9,11 +10,27 @@ import (
 	"github.com/apex/log"
 	"github.com/gin-gonic/gin"
 
+	"github.com/pelican-dev/wings/config"
+	"github.com/pelican-dev/wings/remote"
 	"github.com/pelican-dev/wings/router/middleware"
 	"github.com/pelican-dev/wings/server"
 	"github.com/pelican-dev/wings/server/backup"
 )
 
+// getServerBackups returns the local backups that are stored on this node for
+// the server. This allows the Panel to detect any backups that exist in its own
+// records but are missing from the disk, or the other way around.
+func getServerBackups(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	backups, err := backup.ListLocal(s.ID())
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, backups)
+}
+
 // postServerBackup performs a backup against a given server instance using the
 // provided backup adapter.
 func postServerBackup(c *gin.Context) {
@@ -32,7 +49,7 @@ func postServerBackup(c *gin.Context) {
 	var adapter backup.BackupInterface
 	switch data.Adapter {
 	case backup.LocalBackupAdapter:
-		adapter = backup.NewLocal(client, data.Uuid, s.ID(), data.Ignore)
+		adapter = localBackupAdapter(c, s, data.Uuid, data.Ignore)
 	case backup.S3BackupAdapter:
 		adapter = backup.NewS3(client, data.Uuid, s.ID(), data.Ignore)
 	default:
@@ -56,10 +73,27 @@ func postServerBackup(c *gin.Context) {
 	c.Status(http.StatusAccepted)
 }
 
+// Returns the adapter used for a local backup of the server. If ZFS snapshots are
+// enabled and the server's data directory is its own ZFS dataset a snapshot of the
+// dataset is used, otherwise the files are archived.
+func localBackupAdapter(c *gin.Context, s *server.Server, uuid string, ignore string) backup.BackupInterface {
+	client := middleware.ExtractApiClient(c)
+	if !config.Get().System.Backups.ZfsSnapshots || ignore != "" || s.Filesystem().Type() != "zfs" {
+		return backup.NewLocal(client, uuid, s.ID(), ignore)
+	}
+	ds, err := backup.ZfsDataset(c.Request.Context(), s.Filesystem().Path())
+	if err != nil {
+		middleware.ExtractLogger(c).WithField("error", err).Debug("unable to use zfs snapshot for backup, using an archive instead")
+		return backup.NewLocal(client, uuid, s.ID(), ignore)
+	}
+	return backup.NewZfs(client, uuid, s.ID(), ignore, ds)
+}
+
 // postServerRestoreBackup handles restoring a backup for a server by downloading
 // or finding the given backup on the system and then unpacking the archive into
 // the server's data directory. If the TruncateDirectory field is provided and
-// is true all of the files will be deleted for the server.
+// is true all of the files will be deleted for the server. ZFS snapshot backups
+// always replace every file for the server, whatever this field is set to.
 //
 // This endpoint will block until the backup is fully restored allowing for a
 // spinner to be displayed in the Panel UI effectively.
@@ -73,9 +107,17 @@ func postServerRestoreBackup(c *gin.Context) {
 	var data struct {
 		Adapter           backup.AdapterType `binding:"required,oneof=wings s3" json:"adapter"`
 		TruncateDirectory bool               `json:"truncate_directory"`
+		// If set, files that fail to be restored are skipped and reported once the
+		// restoration finishes, rather than aborting the entire process.
+		ContinueOnError bool `json:"continue_on_error"`
 		// A UUID is always required for this endpoint, however the download URL
 		// is only present when the given adapter type is s3.
 		DownloadUrl string `json:"download_url"`
+		// The checksum recorded when a local backup was created, and the algorithm
+		// used to create it. If a checksum is set, the backup on the disk is verified
+		// against it before anything is restored, and the algorithm is required.
+		Checksum     string `json:"checksum"`
+		ChecksumType string `json:"checksum_type"`
 	}
 	if err := c.BindJSON(&data); err != nil {
 		return
@@ -84,6 +126,12 @@ func postServerRestoreBackup(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "The download_url field is required when the backup adapter is set to S3."})
 		return
 	}
+	// The checksum can only be verified using the algorithm it was created with, which
+	// may no longer be the one configured for the node.
+	if data.Checksum != "" && data.ChecksumType == "" {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "The checksum_type field is required when a checksum is provided."})
+		return
+	}
 
 	s.SetRestoring(true)
 	hasError := true
@@ -96,16 +144,10 @@ func postServerRestoreBackup(c *gin.Context) {
 	}()
 
 	logger.Info("processing server backup restore request")
-	if data.TruncateDirectory {
-		logger.Info("received \"truncate_directory\" flag in request: deleting server files")
-		if err := s.Filesystem().TruncateRootDirectory(); err != nil {
-			middleware.CaptureAndAbort(c, err)
-			return
-		}
-	}
 
-	// Now that we've cleaned up the data directory if necessary, grab the backup file
-	// and attempt to restore it into the server directory.
+	// Local backups are verified before the data directory is truncated so that a
+	// corrupted backup never replaces the server's files. Since this can take some
+	// time for large backups it happens in the background.
 	if data.Adapter == backup.LocalBackupAdapter {
 		b, _, err := backup.LocateLocal(client, c.Param("backup"), s.ID())
 		if err != nil {
@@ -113,10 +155,35 @@ func postServerRestoreBackup(c *gin.Context) {
 			return
 		}
 		go func(s *server.Server, b backup.BackupInterface, logger *log.Entry) {
+			if data.Checksum != "" {
+				logger.Info("verifying integrity of local backup before restoring")
+				if err := b.VerifyChecksum(data.ChecksumType, data.Checksum); err != nil {
+					logger.WithField("error", err).Error("failed to verify local backup before restoring")
+					if errors.Is(err, backup.ErrIntegrityCheckFailed) {
+						s.Events().Publish(server.DaemonMessageEvent, "Backup integrity check failed: the backup stored on this node does not match its checksum and was not restored.")
+					}
+					abortRestore(s, client, b.Identifier(), logger)
+					return
+				}
+			}
+			// A ZFS snapshot replaces the entire dataset when it is received, so there is
+			// nothing to truncate. Doing so would also try to remove the dataset's mountpoint.
+			if _, ok := b.(*backup.ZfsBackup); ok && data.TruncateDirectory {
+				logger.Debug("ignoring \"truncate_directory\" flag in request: zfs snapshot backups replace all server files")
+			} else if data.TruncateDirectory {
+				logger.Info("received \"truncate_directory\" flag in request: deleting server files")
+				if err := s.Filesystem().TruncateRootDirectory(); err != nil {
+					logger.WithField("error", err).Error("failed to truncate server files before restoring backup")
+					abortRestore(s, client, b.Identifier(), logger)
+					return
+				}
+			}
 			logger.Info("starting restoration process for server backup using local driver")
-			if err := s.RestoreBackup(b, nil); err != nil {
+			failures, err := s.RestoreBackup(b, nil, data.ContinueOnError)
+			if err != nil {
 				logger.WithField("error", err).Error("failed to restore local backup to server")
 			}
+			reportRestoreFailures(s, logger, failures)
 			s.Events().Publish(server.DaemonMessageEvent, "Completed server restoration from local backup.")
 			s.Events().Publish(server.BackupRestoreCompletedEvent, "")
 			logger.Info("completed server restoration from local backup")
@@ -127,9 +194,16 @@ func postServerRestoreBackup(c *gin.Context) {
 		return
 	}
 
+	if data.TruncateDirectory {
+		logger.Info("received \"truncate_directory\" flag in request: deleting server files")
+		if err := s.Filesystem().TruncateRootDirectory(); err != nil {
+			middleware.CaptureAndAbort(c, err)
+			return
+		}
+	}
+
 	// Since this is not a local backup we need to stream the archive and then
 	// parse over the contents as we go in order to restore it to the server.
-	httpClient := http.Client{}
 	logger.Info("downloading backup from remote location...")
 	// TODO: this will hang if there is an issue. We can't use c.Request.Context() (or really any)
 	//  since it will be canceled when the request is closed which happens quickly since we push
@@ -137,30 +211,27 @@ func postServerRestoreBackup(c *gin.Context) {
 	//
 	// For now I'm just using the server context so at least the request is canceled if
 	// the server gets deleted.
-	req, err := http.NewRequestWithContext(s.Context(), http.MethodGet, data.DownloadUrl, nil)
-	if err != nil {
-		middleware.CaptureAndAbort(c, err)
-		return
-	}
-	res, err := httpClient.Do(req)
+	res, err := backup.OpenRemoteDownload(s.Context(), data.DownloadUrl, config.Get().System.Backups.RestoreDownloadRetries)
 	if err != nil {
 		middleware.CaptureAndAbort(c, err)
 		return
 	}
 	// Don't allow content types that we know are going to give us problems.
-	if res.Header.Get("Content-Type") == "" || !strings.Contains("application/x-gzip application/gzip", res.Header.Get("Content-Type")) {
-		_ = res.Body.Close()
+	if res.ContentType() == "" || !strings.Contains("application/x-gzip application/gzip", res.ContentType()) {
+		_ = res.Close()
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "The provided backup link is not a supported content type. \"" + res.Header.Get("Content-Type") + "\" is not application/x-gzip.",
+			"error": "The provided backup link is not a supported content type. \"" + res.ContentType() + "\" is not application/x-gzip.",
 		})
 		return
 	}
 
 	go func(s *server.Server, uuid string, logger *log.Entry) {
 		logger.Info("starting restoration process for server backup using S3 driver")
-		if err := s.RestoreBackup(backup.NewS3(client, uuid, s.ID(), ""), res.Body); err != nil {
+		failures, err := s.RestoreBackup(backup.NewS3(client, uuid, s.ID(), ""), res, data.ContinueOnError)
+		if err != nil {
 			logger.WithField("error", errors.WithStack(err)).Error("failed to restore remote S3 backup to server")
 		}
+		reportRestoreFailures(s, logger, failures)
 		s.Events().Publish(server.DaemonMessageEvent, "Completed server restoration from S3 backup.")
 		s.Events().Publish(server.BackupRestoreCompletedEvent, "")
 		logger.Info("completed server restoration from S3 backup")
@@ -171,6 +242,26 @@ func postServerRestoreBackup(c *gin.Context) {
 	c.Status(http.StatusAccepted)
 }
 
+// abortRestore notifies the Panel that a backup could not be restored before the
+// restoration process itself was started.
+func abortRestore(s *server.Server, client remote.Client, uuid string, logger *log.Entry) {
+	if err := client.SendRestorationStatus(s.Context(), uuid, false); err != nil {
+		logger.WithField("error", err).Error("failed to notify Panel of backup restoration status")
+	}
+	s.Events().Publish(server.BackupRestoreCompletedEvent, "")
+	s.SetRestoring(false)
+}
+
+// reportRestoreFailures logs any files that were skipped while restoring a
+// backup and notifies the connected websocket clients of how many were skipped.
+func reportRestoreFailures(s *server.Server, logger *log.Entry, failures []server.RestoreFailure) {
+	if len(failures) == 0 {
+		return
+	}
+	logger.WithField("failures", failures).Warn("skipped files that could not be restored from backup")
+	s.Events().Publish(server.DaemonMessageEvent, fmt.Sprintf("Skipped %d file(s) that could not be restored from the backup.", len(failures)))
+}
+
 // deleteServerBackup deletes a local backup of a server. If the backup is not
 // found on the machine just return a 404 error. The service calling this
 // endpoint can make its own decisions as to how it wants to handle that
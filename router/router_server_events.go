@@ -0,0 +1,178 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+
+	"github.com/pelican-dev/wings/config"
+	"github.com/pelican-dev/wings/events"
+	"github.com/pelican-dev/wings/router/middleware"
+	"github.com/pelican-dev/wings/server"
+	"github.com/pelican-dev/wings/system"
+)
+
+// Streams the events and console output for a server to the client using
+// server-sent events. A keepalive comment is periodically written to the stream
+// so that idle connections are not closed by any proxies in front of Wings.
+func getServerEvents(c *gin.Context) {
+	if abortIfDraining(c) {
+		return
+	}
+
+	s := ExtractServer(c)
+	ctx := c.Request.Context()
+
+	eventChan := make(chan []byte, 8)
+	logOutput := make(chan []byte, 8)
+	s.Events().On(eventChan)
+	s.Sink(system.LogSink).On(logOutput)
+	defer func() {
+		s.Events().Off(eventChan)
+		s.Sink(system.LogSink).Off(logOutput)
+	}()
+
+	ticker := time.NewTicker(startServerSentEvents(c))
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err = fmt.Fprint(c.Writer, ": keepalive\n\n")
+		case b, ok := <-logOutput:
+			if !ok {
+				return
+			}
+			err = writeServerSentEvent(c.Writer, server.ConsoleOutputEvent, string(b))
+		case b, ok := <-eventChan:
+			if !ok {
+				return
+			}
+			var e events.Event
+			if err := events.DecodeTo(b, &e); err != nil {
+				continue
+			}
+			err = writeServerSentEvent(c.Writer, e.Topic, e.Data)
+		}
+		if err != nil {
+			middleware.ExtractLogger(c).WithField("error", err).Debug("failed to write to server events stream")
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// Streams the lifecycle events for every server on the node to the client using
+// server-sent events, such as servers being created, deleted, installed or
+// transferred. This allows the Panel to keep its list of servers up to date
+// without polling the node.
+func getNodeEvents(c *gin.Context) {
+	if abortIfDraining(c) {
+		return
+	}
+
+	manager := middleware.ExtractManager(c)
+	ctx := c.Request.Context()
+
+	eventChan := make(chan []byte, 8)
+	manager.Events().On(eventChan)
+	defer manager.Events().Off(eventChan)
+
+	ticker := time.NewTicker(startServerSentEvents(c))
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err = fmt.Fprint(c.Writer, ": keepalive\n\n")
+		case b, ok := <-eventChan:
+			if !ok {
+				return
+			}
+			var e events.Event
+			if err := events.DecodeTo(b, &e); err != nil {
+				continue
+			}
+			err = writeServerSentEvent(c.Writer, e.Topic, e.Data)
+		}
+		if err != nil {
+			middleware.ExtractLogger(c).WithField("error", err).Debug("failed to write to node events stream")
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// Aborts the request if the node is being drained, since new streaming
+// connections should not be accepted while waiting for the node to become safe
+// to reboot. Existing connections are left open until closed by the client.
+func abortIfDraining(c *gin.Context) bool {
+	if !server.IsDraining() {
+		return false
+	}
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+		"error": "This node is currently draining for maintenance.",
+	})
+	return true
+}
+
+// Writes the headers for a server-sent events stream to the client, along with
+// the configured retry interval. The interval at which keepalive comments should
+// be written to the stream is returned.
+func startServerSentEvents(c *gin.Context) time.Duration {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	api := config.Get().Api
+	if api.SSERetrySeconds > 0 {
+		fmt.Fprintf(c.Writer, "retry: %d\n\n", api.SSERetrySeconds*1000)
+	}
+	c.Writer.Flush()
+
+	interval := time.Duration(api.SSEKeepaliveSeconds) * time.Second
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	return interval
+}
+
+// Writes a single event to the server-sent events stream. The data is encoded as
+// JSON unless it is already a string, in which case it is written as-is with
+// each line of the string being sent as a separate data field.
+func writeServerSentEvent(w gin.ResponseWriter, event string, data interface{}) error {
+	var v string
+	switch d := data.(type) {
+	case string:
+		v = d
+	case []byte:
+		v = string(d)
+	default:
+		b, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		v = string(b)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("event: " + event + "\n")
+	for _, line := range strings.Split(v, "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+	sb.WriteString("\n")
+	_, err := w.WriteString(sb.String())
+	return err
+}
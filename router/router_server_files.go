@@ -3,6 +3,7 @@ package router
 import (
 	"bufio"
 	"context"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
@@ -20,6 +22,7 @@ import (
 
 	"github.com/pelican-dev/wings/config"
 	"github.com/pelican-dev/wings/internal/models"
+	"github.com/pelican-dev/wings/internal/ufs"
 	"github.com/pelican-dev/wings/router/downloader"
 	"github.com/pelican-dev/wings/router/middleware"
 	"github.com/pelican-dev/wings/router/tokens"
@@ -58,6 +61,7 @@ func getServerFileContents(c *gin.Context) {
 	}
 
 	c.Header("X-Mime-Type", st.Mimetype)
+	c.Header("ETag", st.ETag())
 	c.Header("Content-Length", strconv.Itoa(int(st.Size())))
 	// If a download parameter is included in the URL go ahead and attach the necessary headers
 	// so that the file can be downloaded.
@@ -95,6 +99,135 @@ func getServerListDirectory(c *gin.Context) {
 	}
 }
 
+// The maximum amount of time and number of entries that are walked when
+// summarizing a directory before the response is returned as truncated.
+const (
+	directorySummaryTimeout    = time.Second * 30
+	directorySummaryMaxEntries = 5_000_000
+)
+
+// getServerDirectorySummary returns the number of files and directories within a
+// directory, along with the total size of the files, so that the Panel can warn
+// a user before they delete or archive a very large directory.
+func getServerDirectorySummary(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), directorySummaryTimeout)
+	defer cancel()
+
+	summary, err := s.Filesystem().SummarizeDirectory(ctx, c.Query("directory"), directorySummaryMaxEntries)
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// postServerStatFiles returns the stat information for multiple files at once.
+// Files that cannot be found, or which fail to stat, return an error for that
+// specific entry rather than failing the entire request.
+func postServerStatFiles(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		Files []string `json:"files"`
+	}
+	// BindJSON sends 400 if the request fails, all we need to do is return
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	if len(data.Files) == 0 {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "No files were specified to stat.",
+		})
+		return
+	} else if len(data.Files) > 1000 {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "No more than 1000 files may be requested at once.",
+		})
+		return
+	}
+
+	type statResult struct {
+		File  string           `json:"file"`
+		Stat  *filesystem.Stat `json:"stat"`
+		Error string           `json:"error,omitempty"`
+	}
+
+	out := make([]statResult, len(data.Files))
+	for i, f := range data.Files {
+		p := strings.TrimLeft(f, "/")
+		out[i].File = f
+		if err := s.Filesystem().IsIgnored(p); err != nil {
+			out[i].Error = "The requested file or directory does not exist."
+			continue
+		}
+		st, err := s.Filesystem().Stat(p)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				out[i].Error = "The requested file or directory does not exist."
+			} else {
+				s.Log().WithFields(log.Fields{"file": p, "error": err}).Warn("failed to stat file")
+				out[i].Error = "An error was encountered while attempting to stat this file."
+			}
+			continue
+		}
+		out[i].Stat = &st
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
+
+// postServerDownloadArchive streams a zip archive of the selected files directly
+// to the client without first writing the archive to the disk.
+func postServerDownloadArchive(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		Root  string   `json:"root"`
+		Files []string `json:"files"`
+	}
+	// BindJSON sends 400 if the request fails, all we need to do is return
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	if len(data.Files) == 0 {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "No files were specified to download.",
+		})
+		return
+	}
+
+	for _, f := range data.Files {
+		if err := s.Filesystem().IsIgnored(path.Join(data.Root, f)); err != nil {
+			middleware.CaptureAndAbort(c, err)
+			return
+		}
+	}
+
+	release, ok := acquireDownload(c, s)
+	if !ok {
+		return
+	}
+	defer release()
+
+	name := fmt.Sprintf("%s-%s.zip", strings.SplitN(s.ID(), "-", 2)[0], time.Now().Format("2006-01-02T150405"))
+	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(name))
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+
+	if err := s.Filesystem().StreamZip(c.Request.Context(), c.Writer, data.Root, data.Files); err != nil {
+		// The response has already been started at this point, so there is nothing
+		// that can be returned to the client other than an incomplete archive.
+		if !errors.Is(err, context.Canceled) {
+			middleware.ExtractLogger(c).WithField("error", err).Error("failed to stream archive of server files")
+		}
+		c.Abort()
+	}
+}
+
 type renameFile struct {
 	To   string `json:"to"`
 	From string `json:"from"`
@@ -107,6 +240,9 @@ func putServerRenameFiles(c *gin.Context) {
 	var data struct {
 		Root  string       `json:"root"`
 		Files []renameFile `json:"files"`
+		// Overwrite allows files at the destination to be replaced, by default the
+		// rename fails if the destination already exists.
+		Overwrite bool `json:"overwrite"`
 	}
 	// BindJSON sends 400 if the request fails, all we need to do is return
 	if err := c.BindJSON(&data); err != nil {
@@ -137,7 +273,11 @@ func putServerRenameFiles(c *gin.Context) {
 				if err := fs.IsIgnored(pf, pt); err != nil {
 					return err
 				}
-				if err := fs.Rename(pf, pt); err != nil {
+				rename := fs.Rename
+				if data.Overwrite {
+					rename = fs.RenameReplace
+				}
+				if err := rename(pf, pt); err != nil {
 					// Return nil if the error is an is not exists.
 					if errors.Is(err, os.ErrNotExist) {
 						s.Log().WithField("error", err).
@@ -260,7 +400,35 @@ func postServerWriteFile(c *gin.Context) {
 		return
 	}
 
+	if !limitRequestBody(c, writeLimit(s, f)) {
+		return
+	}
+
+	// If the client provided the ETag of the file when it was last read, ensure that
+	// the file has not been modified since then. This prevents multiple users editing
+	// the same file at once from silently overwriting each other's changes.
+	if match := c.GetHeader("If-Match"); match != "" && match != "*" {
+		st, err := s.Filesystem().Stat(f)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			middleware.CaptureAndAbort(c, err)
+			return
+		}
+		if err != nil || st.ETag() != match {
+			res := gin.H{"error": "The file has been modified since it was last read."}
+			if err == nil {
+				c.Header("ETag", st.ETag())
+				res["stat"] = &st
+			}
+			c.AbortWithStatusJSON(http.StatusConflict, res)
+			return
+		}
+	}
+
 	if err := s.Filesystem().Write(f, c.Request.Body, c.Request.ContentLength, 0o644); err != nil {
+		if isMaxBytesError(err) {
+			abortRequestTooLarge(c)
+			return
+		}
 		if filesystem.IsErrorCode(err, filesystem.ErrCodeIsDirectory) {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 				"error": "Cannot write file, name conflicts with an existing directory by the same name.",
@@ -272,6 +440,10 @@ func postServerWriteFile(c *gin.Context) {
 		return
 	}
 
+	if st, err := s.Filesystem().Stat(f); err == nil {
+		c.Header("ETag", st.ETag())
+	}
+
 	c.Status(http.StatusNoContent)
 }
 
@@ -471,12 +643,22 @@ func postServerDecompressFiles(c *gin.Context) {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "The archive provided is in a format Wings does not understand."})
 			return
 		}
+		if filesystem.IsErrorCode(err, filesystem.ErrCodeArchiveRatio) {
+			lg.WithField("error", err).Warn("failed to decompress file: archive exceeds maximum decompression ratio")
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "The archive provided expands to a size that exceeds the maximum decompression ratio allowed on this node."})
+			return
+		}
+		if filesystem.IsErrorCode(err, filesystem.ErrCodeArchiveFormat) {
+			abortArchiveFormat(c, lg, err)
+			return
+		}
 		middleware.CaptureAndAbort(c, err)
 		return
 	}
 
 	lg.Info("starting file decompression")
-	if err := s.Filesystem().DecompressFile(context.Background(), data.RootPath, data.File); err != nil {
+	skipped, err := s.Filesystem().DecompressFile(context.Background(), data.RootPath, data.File)
+	if err != nil {
 		// If the file is busy for some reason just return a nicer error to the user since there is not
 		// much we specifically can do. They'll need to stop the running server process in order to overwrite
 		// a file like this.
@@ -487,12 +669,30 @@ func postServerDecompressFiles(c *gin.Context) {
 			})
 			return
 		}
+		if filesystem.IsErrorCode(err, filesystem.ErrCodeArchiveFormat) {
+			abortArchiveFormat(c, lg, err)
+			return
+		}
 		middleware.CaptureAndAbort(c, err)
 		return
 	}
+	// Let the caller know about any files that were not extracted because they are
+	// denied by the egg, otherwise they are silently missing from the result.
+	if len(skipped) > 0 {
+		lg.WithField("skipped", skipped).Info("skipped denylisted files while decompressing archive")
+		c.JSON(http.StatusOK, gin.H{"skipped": skipped})
+		return
+	}
 	c.Status(http.StatusNoContent)
 }
 
+// abortArchiveFormat responds to a request to decompress an archive in a format
+// that the node does not allow.
+func abortArchiveFormat(c *gin.Context, lg *log.Entry, err error) {
+	lg.WithField("error", err).Warn("failed to decompress file: archive format is not allowed")
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "The archive provided is in a format that is not allowed to be extracted on this node."})
+}
+
 type chmodFile struct {
 	File string `json:"file"`
 	Mode string `json:"mode"`
@@ -564,6 +764,129 @@ func postServerChmodFile(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// postServerRepairPermissions resets the ownership of every file and folder in
+// the server's data directory to the configured container user, and applies the
+// default permissions to them. The number of entries that were updated is
+// returned to the caller.
+func postServerRepairPermissions(c *gin.Context) {
+	s := ExtractServer(c)
+
+	updated, err := s.Filesystem().RepairPermissions(c.Request.Context())
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+// postServerChtimesFile updates the access and modification times of a file. If
+// either time is omitted (or zero) it is left unchanged. The updated stat for the
+// file is returned to the caller.
+func postServerChtimesFile(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		File  string    `json:"file"`
+		Atime time.Time `json:"atime"`
+		Mtime time.Time `json:"mtime"`
+	}
+	// BindJSON sends 400 if the request fails, all we need to do is return
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	p := strings.TrimLeft(data.File, "/")
+	if p == "" {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "No file was provided.",
+		})
+		return
+	}
+	if err := s.Filesystem().IsIgnored(p); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	if err := s.Filesystem().Chtimes(p, data.Atime, data.Mtime); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "The requested resource was not found on the system.",
+			})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	st, err := s.Filesystem().Stat(p)
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, &st)
+}
+
+// postServerTruncateFile changes the size of a file in place. This is primarily
+// used to clear a log file that is being held open by the server process, which
+// deleting and re-creating the file would not achieve.
+func postServerTruncateFile(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		File string `json:"file"`
+		Size int64  `json:"size"`
+	}
+	// BindJSON sends 400 if the request fails, all we need to do is return
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	p := strings.TrimLeft(data.File, "/")
+	if p == "" {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "No file was provided.",
+		})
+		return
+	}
+	if data.Size < 0 {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "The size of the file cannot be negative.",
+		})
+		return
+	}
+	if err := s.Filesystem().IsIgnored(p); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	if err := s.Filesystem().Truncate(p, data.Size); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "The requested resource was not found on the system.",
+			})
+			return
+		}
+		if filesystem.IsErrorCode(err, filesystem.ErrCodeIsDirectory) || errors.Is(err, ufs.ErrNotRegular) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Only regular files can be truncated.",
+			})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	st, err := s.Filesystem().Stat(p)
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, &st)
+}
+
 func postServerUploadFiles(c *gin.Context) {
 	manager := middleware.ExtractManager(c)
 
@@ -581,8 +904,22 @@ func postServerUploadFiles(c *gin.Context) {
 		return
 	}
 
+	// Uploaded files are checked against the disk limit of the server as they are
+	// written, so only the upload limit for the node is applied to the request.
+	limit := uploadLimit()
+	if limit >= 0 {
+		limit += multipartOverhead
+	}
+	if !limitRequestBody(c, limit) {
+		return
+	}
+
 	form, err := c.MultipartForm()
 	if err != nil {
+		if isMaxBytesError(err) {
+			abortRequestTooLarge(c)
+			return
+		}
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 			"error": "Failed to get multipart form data from request.",
 		})
@@ -643,3 +980,62 @@ func handleFileUpload(p string, s *server.Server, header *multipart.FileHeader)
 	}
 	return nil
 }
+
+// The additional space allowed in the body of an upload request for the
+// multipart form encoding around the uploaded file.
+const multipartOverhead = 1024 * 1024
+
+// uploadLimit returns the maximum size in bytes of a file uploaded or written
+// through the Panel, or -1 if there is no limit for the node.
+func uploadLimit() int64 {
+	if v := config.Get().Api.UploadLimit; v > 0 {
+		return v * 1024 * 1024
+	}
+	return -1
+}
+
+// writeLimit returns the maximum size of the request body when writing to the
+// given file. This is the smaller of the upload limit for the node and the disk
+// space the server would have remaining once the existing file is replaced, since
+// only the difference in size is counted against the server's disk limit.
+func writeLimit(s *server.Server, p string) int64 {
+	limit := uploadLimit()
+	available := s.Filesystem().AvailableSpace()
+	if available < 0 {
+		return limit
+	}
+	if st, err := s.Filesystem().Stat(p); err == nil && st.Mode().IsRegular() {
+		available += st.Size()
+	}
+	if limit < 0 || available < limit {
+		limit = available
+	}
+	return limit
+}
+
+// limitRequestBody limits the size of the request body to the given number of
+// bytes. If the request provides a Content-Length larger than the limit it is
+// rejected before any of the body is read, otherwise the body is wrapped so that
+// reading beyond the limit fails. A limit below zero does not limit the body.
+func limitRequestBody(c *gin.Context, limit int64) bool {
+	if limit < 0 {
+		return true
+	}
+	if c.Request.ContentLength > limit {
+		abortRequestTooLarge(c)
+		return false
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	return true
+}
+
+func isMaxBytesError(err error) bool {
+	var mbe *http.MaxBytesError
+	return errors.As(err, &mbe)
+}
+
+func abortRequestTooLarge(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error": "The request body is larger than the maximum allowed size, or the space remaining for this server.",
+	})
+}
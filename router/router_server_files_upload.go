@@ -0,0 +1,116 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+
+	"emperror.dev/errors"
+	"github.com/gin-gonic/gin"
+
+	"github.com/pelican-dev/wings/router/middleware"
+	"github.com/pelican-dev/wings/server/filesystem"
+)
+
+// postServerCreateUpload creates a new chunked upload session for a file. The
+// chunks of the file are then sent to putServerUploadChunk, allowing an upload
+// that fails part way through to be resumed from the last received offset.
+func postServerCreateUpload(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		File string `binding:"required" json:"file"`
+		Size int64  `binding:"min=0" json:"size"`
+		// The SHA-256 checksum of the complete file, which is verified when the
+		// upload is finalized.
+		Checksum string `json:"checksum"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	u, err := s.Filesystem().CreateUpload(data.File, data.Size, data.Checksum)
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, u.Status())
+}
+
+// getServerUpload returns the status of a chunked upload session, including the
+// offset that the next chunk should be sent from.
+func getServerUpload(c *gin.Context) {
+	u, err := ExtractServer(c).Filesystem().Upload(c.Param("upload"))
+	if err != nil {
+		abortUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, u.Status())
+}
+
+// putServerUploadChunk writes the request body to a chunked upload session at
+// the offset provided in the query string.
+func putServerUploadChunk(c *gin.Context) {
+	s := ExtractServer(c)
+
+	offset, err := strconv.ParseInt(c.Query("offset"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "A valid offset must be provided in the query string."})
+		return
+	}
+	if c.Request.ContentLength == -1 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Missing Content-Length"})
+		return
+	}
+
+	n, err := s.Filesystem().WriteUploadChunk(c.Param("upload"), offset, c.Request.Body, c.Request.ContentLength)
+	if err != nil {
+		abortUploadError(c, err, gin.H{"offset": n})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"offset": n})
+}
+
+// postServerFinalizeUpload verifies that the entire file has been received for
+// a chunked upload session and moves it into place.
+func postServerFinalizeUpload(c *gin.Context) {
+	if err := ExtractServer(c).Filesystem().FinalizeUpload(c.Param("upload")); err != nil {
+		abortUploadError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// deleteServerUpload cancels a chunked upload session and removes any data that
+// has been uploaded for it.
+func deleteServerUpload(c *gin.Context) {
+	if err := ExtractServer(c).Filesystem().CancelUpload(c.Param("upload")); err != nil {
+		abortUploadError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func abortUploadError(c *gin.Context, err error, extra ...gin.H) {
+	res := gin.H{"error": err.Error()}
+	for _, e := range extra {
+		for k, v := range e {
+			res[k] = v
+		}
+	}
+	switch {
+	case errors.Is(err, filesystem.ErrUploadNotFound):
+		res["error"] = "The requested upload was not found."
+		c.AbortWithStatusJSON(http.StatusNotFound, res)
+	case errors.Is(err, filesystem.ErrUploadOffset):
+		res["error"] = "The offset does not match the data received for this upload."
+		c.AbortWithStatusJSON(http.StatusConflict, res)
+	case errors.Is(err, filesystem.ErrUploadSize):
+		res["error"] = "The chunk exceeds the declared size of the upload."
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, res)
+	case errors.Is(err, filesystem.ErrUploadChecksum):
+		res["error"] = "The checksum of the uploaded file does not match."
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, res)
+	default:
+		middleware.CaptureAndAbort(c, err)
+	}
+}
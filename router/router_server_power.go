@@ -0,0 +1,118 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pelican-dev/wings/internal/database"
+	"github.com/pelican-dev/wings/internal/models"
+	"github.com/pelican-dev/wings/router/middleware"
+	"github.com/pelican-dev/wings/server"
+)
+
+// maxScheduledPowerActionDelay is the furthest in the future a power action can be
+// scheduled on the node. Anything longer than this should be handled by the Panel's
+// own scheduling system.
+const maxScheduledPowerActionDelay = 7 * 24 * time.Hour
+
+// Returns all the pending scheduled power actions for a server.
+func getServerScheduledPower(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	actions := []models.ScheduledPowerAction{}
+	err := database.Instance().WithContext(c.Request.Context()).
+		Where("server = ?", s.ID()).
+		Order("run_at ASC").
+		Find(&actions).Error
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, actions)
+}
+
+// Registers a one-shot power action for a server that will be executed by Wings at the
+// given time, regardless of whether the Panel is reachable at that point.
+func postServerScheduledPower(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	var data struct {
+		Action       server.PowerAction `json:"action"`
+		RunAt        *time.Time         `json:"run_at"`
+		DelaySeconds int                `json:"delay_seconds"`
+		WaitSeconds  int                `json:"wait_seconds"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	if !data.Action.IsValid() {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "The power action provided was not valid, should be one of \"stop\", \"start\", \"restart\", \"kill\"",
+		})
+		return
+	}
+
+	runAt := time.Now().Add(time.Duration(data.DelaySeconds) * time.Second)
+	if data.RunAt != nil {
+		runAt = *data.RunAt
+	}
+	if data.DelaySeconds < 0 || runAt.Before(time.Now()) || time.Until(runAt) > maxScheduledPowerActionDelay {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "The scheduled time must be in the future and no more than 7 days from now.",
+		})
+		return
+	}
+
+	if data.WaitSeconds < 0 || data.WaitSeconds > 300 {
+		data.WaitSeconds = 30
+	}
+
+	action := models.ScheduledPowerAction{
+		Server:      s.ID(),
+		Action:      string(data.Action),
+		WaitSeconds: data.WaitSeconds,
+		RunAt:       runAt,
+	}
+	if err := database.Instance().WithContext(c.Request.Context()).Create(&action).Error; err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	s.Log().WithField("action", action.Action).WithField("run_at", action.RunAt).Info("registered scheduled power action")
+
+	c.JSON(http.StatusCreated, action)
+}
+
+// Cancels a pending scheduled power action for a server.
+func deleteServerScheduledPower(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "The requested scheduled power action could not be found.",
+		})
+		return
+	}
+
+	tx := database.Instance().WithContext(c.Request.Context()).
+		Where("id = ? AND server = ?", id, s.ID()).
+		Delete(&models.ScheduledPowerAction{})
+	if tx.Error != nil {
+		middleware.CaptureAndAbort(c, tx.Error)
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "The requested scheduled power action could not be found.",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -11,7 +11,6 @@ import (
 
 	"github.com/pelican-dev/wings/environment"
 	"github.com/pelican-dev/wings/router/middleware"
-	"github.com/pelican-dev/wings/server"
 	"github.com/pelican-dev/wings/server/installer"
 	"github.com/pelican-dev/wings/server/transfer"
 )
@@ -52,7 +51,7 @@ func postServerTransfer(c *gin.Context) {
 				Error("failed to set transfer status")
 		}
 
-		s.Events().Publish(server.TransferStatusEvent, "failure")
+		s.PublishTransferStatus("failure")
 		s.SetTransferring(false)
 	}
 
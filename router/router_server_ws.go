@@ -2,6 +2,7 @@ package router
 
 import (
 	"context"
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -10,8 +11,15 @@ import (
 
 	"github.com/pelican-dev/wings/router/middleware"
 	"github.com/pelican-dev/wings/router/websocket"
+	"github.com/pelican-dev/wings/server"
 )
 
+// The maximum number of inbound messages from a single websocket connection that
+// can be handled at the same time. Any additional messages are queued until one
+// of the in-flight handlers has finished, so that a single connection cannot
+// spawn an unbounded number of expensive handlers such as log replays.
+const maxConcurrentWebsocketHandlers = 4
+
 var expectedCloseCodes = []int{
 	ws.CloseGoingAway,
 	ws.CloseAbnormalClosure,
@@ -25,6 +33,15 @@ func getServerWebsocket(c *gin.Context) {
 	manager := middleware.ExtractManager(c)
 	s, _ := manager.Get(c.Param("server"))
 
+	// Don't accept any new connections while the node is being drained, existing
+	// connections are left open until they are closed by the client.
+	if server.IsDraining() {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"error": "This node is currently draining for maintenance.",
+		})
+		return
+	}
+
 	// Create a context that can be canceled when the user disconnects from this
 	// socket that will also cancel listeners running in separate threads. If the
 	// connection itself is terminated listeners using this context will also be
@@ -63,6 +80,7 @@ func getServerWebsocket(c *gin.Context) {
 		}
 	}()
 
+	sem := make(chan struct{}, maxConcurrentWebsocketHandlers)
 	for {
 		j := websocket.Message{}
 
@@ -81,7 +99,18 @@ func getServerWebsocket(c *gin.Context) {
 			continue
 		}
 
+		// Wait for one of the in-flight handlers to finish before reading any further
+		// messages from the connection if the limit has been reached.
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		case <-s.Context().Done():
+			return
+		}
+
 		go func(msg websocket.Message) {
+			defer func() { <-sem }()
 			if err := handler.HandleInbound(ctx, msg); err != nil {
 				_ = handler.SendErrorJson(msg, err)
 			}
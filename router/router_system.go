@@ -3,13 +3,17 @@ package router
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/gin-gonic/gin"
 
 	"github.com/pelican-dev/wings/config"
+	"github.com/pelican-dev/wings/internal/database"
 	"github.com/pelican-dev/wings/router/middleware"
 	"github.com/pelican-dev/wings/server"
 	"github.com/pelican-dev/wings/server/installer"
@@ -23,6 +27,7 @@ func getSystemInformation(c *gin.Context) {
 		middleware.CaptureAndAbort(c, err)
 		return
 	}
+	i.System.OpenatMode = config.OpenatModeInUse()
 
 	if c.Query("v") == "2" {
 		c.JSON(http.StatusOK, i)
@@ -95,6 +100,49 @@ func getAllServers(c *gin.Context) {
 	c.JSON(http.StatusOK, out)
 }
 
+// Sends a command to each of the provided servers, returning the result for each
+// server. This allows a command, such as an announcement, to be broadcast to many
+// servers at once without connecting to each of their websockets.
+func postServersCommands(c *gin.Context) {
+	manager := middleware.ExtractManager(c)
+
+	var data struct {
+		Servers []string `binding:"required,min=1" json:"servers"`
+		Command string   `binding:"required" json:"command"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	type result struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error,omitempty"`
+	}
+	results := make(map[string]result, len(data.Servers))
+	for _, id := range data.Servers {
+		s, ok := manager.Get(id)
+		if !ok {
+			results[id] = result{Error: "The requested server was not found on this node."}
+			continue
+		}
+		if err := s.SendCommand(c.Request.Context(), data.Command); err != nil {
+			switch {
+			case errors.Is(err, server.ErrServerNotRunning):
+				results[id] = result{Error: "The server is not running."}
+			case errors.Is(err, server.ErrCommandRateLimited):
+				results[id] = result{Error: "Commands are being sent to this server too quickly."}
+			default:
+				s.Log().WithFields(log.Fields{"command": data.Command, "error": err}).Warn("failed to send command to server instance")
+				results[id] = result{Error: "The command could not be sent to the server."}
+			}
+			continue
+		}
+		results[id] = result{Success: true}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
 // Creates a new server on the wings daemon and begins the installation process
 // for it.
 func postCreateServer(c *gin.Context) {
@@ -159,7 +207,9 @@ type postUpdateConfigurationResponse struct {
 
 // Updates the running configuration for this Wings instance.
 func postUpdateConfiguration(c *gin.Context) {
-	cfg := config.Get()
+	// Decode the request into a deep copy of the configuration, otherwise any maps
+	// or slices would be modified in place on the global configuration.
+	cfg := config.Get().Clone()
 
 	if cfg.IgnorePanelConfigUpdates {
 		c.JSON(http.StatusOK, postUpdateConfigurationResponse{
@@ -190,8 +240,161 @@ func postUpdateConfiguration(c *gin.Context) {
 	}
 	// Since we wrote it to the disk successfully now update the global configuration
 	// state to use this new configuration struct.
+	previous := config.Get()
 	config.Set(cfg)
+	if len(server.ChangedFields(previous, cfg)) > 0 {
+		for _, s := range middleware.ExtractManager(c).All() {
+			s.PublishNodeConfigurationApplied()
+		}
+	}
 	c.JSON(http.StatusOK, postUpdateConfigurationResponse{
 		Applied: true,
 	})
 }
+
+// Returns the current drain status for the node.
+func getSystemDrain(c *gin.Context) {
+	manager := middleware.ExtractManager(c)
+	c.JSON(http.StatusOK, manager.DrainStatus(c.Request.Context()))
+}
+
+// Places the node into drain mode, preventing servers from being started and new
+// websocket connections from being opened. If requested, all running servers are
+// gracefully stopped in the background. The drain status can then be polled until
+// the node reports that it is safe to reboot.
+func postSystemDrain(c *gin.Context) {
+	manager := middleware.ExtractManager(c)
+
+	var data struct {
+		StopServers bool `json:"stop_servers"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&data); err != nil {
+			return
+		}
+	}
+
+	if data.StopServers {
+		go manager.Drain(true)
+	} else {
+		manager.Drain(false)
+	}
+
+	c.JSON(http.StatusAccepted, manager.DrainStatus(c.Request.Context()))
+}
+
+// Removes the node from drain mode.
+func deleteSystemDrain(c *gin.Context) {
+	middleware.ExtractManager(c).Undrain()
+	c.Status(http.StatusNoContent)
+}
+
+// Returns the size of the backlog of activity stored on this node waiting to be
+// sent to the Panel.
+func getSystemActivity(c *gin.Context) {
+	stats, err := database.GetActivityStats(c.Request.Context())
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// Purges activity older than the provided number of seconds from the local
+// database and optionally vacuums it to reclaim disk space. This is used to
+// recover nodes that were unable to reach the Panel for a long period of time
+// and accumulated a backlog of activity that will never be sent.
+func postSystemActivityPurge(c *gin.Context) {
+	var data struct {
+		OlderThan int  `binding:"required,min=1" json:"older_than"`
+		Vacuum    bool `json:"vacuum"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	ctx := c.Request.Context()
+	deleted, err := database.PurgeActivity(ctx, time.Now().Add(-time.Duration(data.OlderThan)*time.Second))
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	if data.Vacuum {
+		if err := database.Vacuum(ctx); err != nil {
+			middleware.CaptureAndAbort(c, err)
+			return
+		}
+	}
+	log.WithFields(log.Fields{"deleted": deleted, "vacuum": data.Vacuum}).Info("purged activity from local database")
+
+	stats, err := database.GetActivityStats(ctx)
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted, "activity": stats})
+}
+
+// getSystemLogs streams the lines written to the Wings log file to the client
+// using server-sent events. Only lines written after the request is made are
+// sent, and any values identifying the node are redacted from each line. This
+// route requires the node's own authentication token, so it is only available
+// to the Panel and never to individual server tokens.
+func getSystemLogs(c *gin.Context) {
+	if abortIfDraining(c) {
+		return
+	}
+
+	cfg := config.Get()
+	if _, err := os.Stat(cfg.System.GetLogPath()); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "The wings log file does not exist."})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	lines := make(chan string, 64)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- system.TailFile(ctx, cfg.System.GetLogPath(), time.Millisecond*500, func(line []byte) error {
+			select {
+			case lines <- cfg.Redact(string(line)):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	ticker := time.NewTicker(startServerSentEvents(c))
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-errs:
+			if err != nil {
+				middleware.ExtractLogger(c).WithField("error", err).Warn("failed to tail wings log file")
+				_ = writeServerSentEvent(c.Writer, "error", "failed to read the wings log file")
+				c.Writer.Flush()
+			}
+			return
+		case <-ticker.C:
+			_, err = fmt.Fprint(c.Writer, ": keepalive\n\n")
+		case line := <-lines:
+			err = writeServerSentEvent(c.Writer, "log", line)
+		}
+		if err != nil {
+			middleware.ExtractLogger(c).WithField("error", err).Debug("failed to write to system logs stream")
+			return
+		}
+		c.Writer.Flush()
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"mime"
 	"mime/multipart"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/apex/log"
@@ -94,33 +93,45 @@ func postTransfers(c *gin.Context) {
 		transfer.Incoming().Remove(trnsfr)
 
 		if !successful {
-			trnsfr.Server.Events().Publish(server.TransferStatusEvent, "failure")
+			trnsfr.Server.PublishTransferStatus("failure")
 			manager.Remove(func(match *server.Server) bool {
 				return match.ID() == trnsfr.Server.ID()
 			})
+
+			// Always delete any files that were extracted before the transfer failed,
+			// otherwise they are left behind on the disk without a server tracking them.
+			go func(trnsfr *transfer.Transfer) {
+				if err := trnsfr.Server.Filesystem().Destroy(); err != nil {
+					trnsfr.Log().WithError(err).Warn("failed to delete local server files")
+				}
+			}(trnsfr)
 		}
 
 		if err := manager.Client().SetTransferStatus(context.Background(), trnsfr.Server.ID(), successful); err != nil {
-			// Only delete the files if the transfer actually failed, otherwise we could have
-			// unrecoverable data-loss.
-			if !successful && err != nil {
-				// Delete all extracted files.
-				go func(trnsfr *transfer.Transfer) {
-					_ = trnsfr.Server.Filesystem().UnixFS().Close()
-					if err := os.RemoveAll(trnsfr.Server.Filesystem().Path()); err != nil && !os.IsNotExist(err) {
-						trnsfr.Log().WithError(err).Warn("failed to delete local server files")
-					}
-				}(trnsfr)
-			}
-
 			trnsfr.Log().WithField("status", successful).WithError(err).Error("failed to set transfer status on panel")
 			return
 		}
 
-		trnsfr.Server.SetTransferring(false)
-		trnsfr.Server.Events().Publish(server.TransferStatusEvent, "success")
+		if successful {
+			trnsfr.Server.SetTransferring(false)
+			trnsfr.Server.PublishTransferStatus("success")
+		}
 	}(ctx, trnsfr)
 
+	// Aborts the request with the given error. If the transfer was canceled, or
+	// the sending node disconnected part way through the request, the error is
+	// expected and is logged rather than being reported as a failure.
+	abort := func(err error) {
+		if !isTransferCanceled(ctx, c, err) {
+			middleware.CaptureAndAbort(c, err)
+			return
+		}
+		trnsfr.Log().WithField("error", err).Info("incoming transfer was canceled before it was completed")
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "The transfer was canceled before it was completed.",
+		})
+	}
+
 	mediaType, params, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
 	if err != nil {
 		trnsfr.Log().Debug("failed to parse content type header")
@@ -157,7 +168,7 @@ out:
 				break out
 			}
 			if err != nil {
-				middleware.CaptureAndAbort(c, err)
+				abort(err)
 				return
 			}
 
@@ -172,10 +183,14 @@ out:
 				}
 
 				tee := io.TeeReader(p, h)
-				if err := trnsfr.Server.Filesystem().ExtractStreamUnsafe(ctx, "/", tee); err != nil {
-					middleware.CaptureAndAbort(c, err)
+				skipped, err := trnsfr.Server.Filesystem().ExtractStreamUnsafe(ctx, "/", tee)
+				if err != nil {
+					abort(err)
 					return
 				}
+				if len(skipped) > 0 {
+					trnsfr.Log().WithField("skipped", skipped).Warn("skipped denylisted files while extracting transfer archive")
+				}
 
 				hasArchive = true
 			case "checksum":
@@ -190,7 +205,7 @@ out:
 
 				v, err := io.ReadAll(p)
 				if err != nil {
-					middleware.CaptureAndAbort(c, err)
+					abort(err)
 					return
 				}
 
@@ -220,6 +235,11 @@ out:
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		abort(err)
+		return
+	}
+
 	if !hasArchive || !hasChecksum {
 		middleware.CaptureAndAbort(c, errors.New("missing archive or checksum"))
 		return
@@ -249,6 +269,16 @@ out:
 	trnsfr.Log().Debug("done!")
 }
 
+// Determines if an error encountered while receiving a transfer was caused by
+// the transfer being canceled, or by the sending node disconnecting part way
+// through the request, rather than a genuine failure.
+func isTransferCanceled(ctx context.Context, c *gin.Context, err error) bool {
+	if ctx.Err() != nil || c.Request.Context().Err() != nil {
+		return true
+	}
+	return errors.Is(err, context.Canceled) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
 // deleteTransfer cancels an incoming transfer for a server.
 func deleteTransfer(c *gin.Context) {
 	s := ExtractServer(c)
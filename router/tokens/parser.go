@@ -3,6 +3,7 @@ package tokens
 import (
 	"time"
 
+	"emperror.dev/errors"
 	"github.com/gbrlsnchs/jwt/v3"
 
 	"github.com/pelican-dev/wings/config"
@@ -12,6 +13,28 @@ type TokenData interface {
 	GetPayload() *jwt.Payload
 }
 
+// InvalidTokenError is returned by ParseToken when the provided token could not
+// be parsed or failed validation. The underlying error from the JWT library is
+// still available through errors.Is and errors.As.
+type InvalidTokenError struct {
+	err error
+}
+
+func (e *InvalidTokenError) Error() string {
+	return e.err.Error()
+}
+
+func (e *InvalidTokenError) Unwrap() error {
+	return e.err
+}
+
+// IsInvalidTokenError reports whether the error was caused by a token that
+// could not be parsed or validated.
+func IsInvalidTokenError(err error) bool {
+	var e *InvalidTokenError
+	return errors.As(err, &e)
+}
+
 // Validates the provided JWT against the known secret for the Daemon and returns the
 // parsed data. This function DOES NOT validate that the token is valid for the connected
 // server, nor does it ensure that the user providing the token is able to actually do things.
@@ -23,7 +46,8 @@ func ParseToken(token []byte, data TokenData) error {
 		jwt.ExpirationTimeValidator(time.Now()),
 	)
 
-	_, err := jwt.Verify(token, config.GetJwtAlgorithm(), &data, verifyOptions)
-
-	return err
+	if _, err := jwt.Verify(token, config.GetJwtAlgorithm(), &data, verifyOptions); err != nil {
+		return &InvalidTokenError{err: err}
+	}
+	return nil
 }
@@ -2,12 +2,14 @@ package websocket
 
 import (
 	"context"
+	"strconv"
 	"sync"
 	"time"
 
 	"emperror.dev/errors"
 	"github.com/goccy/go-json"
 
+	"github.com/pelican-dev/wings/config"
 	"github.com/pelican-dev/wings/events"
 	"github.com/pelican-dev/wings/system"
 
@@ -71,9 +73,14 @@ var e = []string{
 	server.InstallOutputEvent,
 	server.InstallStartedEvent,
 	server.InstallCompletedEvent,
+	server.ImagePullProgressEvent,
 	server.DaemonMessageEvent,
 	server.BackupCompletedEvent,
 	server.BackupRestoreCompletedEvent,
+	server.CloneCompletedEvent,
+	server.DiskUsageAlertEvent,
+	server.PidsLimitEvent,
+	server.ConfigurationAppliedEvent,
 	server.TransferLogsEvent,
 	server.TransferStatusEvent,
 }
@@ -107,11 +114,35 @@ func (h *Handler) listenForServerEvents(ctx context.Context) error {
 		cancel()
 	}
 
+	// Track the console lines that were dropped because this client is not reading
+	// them fast enough. A single notice is sent each time the client starts falling
+	// behind, rather than one for every line that was dropped.
+	var dropped uint64
+	var behind bool
+	maxDropped := uint64(config.Get().System.WebsocketMaxDroppedLines)
+
 	for {
 		select {
 		case <-ctx.Done():
 			break
 		case b := <-logOutput:
+			if n := h.server.Sink(system.LogSink).TakeDropped(logOutput); n > 0 {
+				dropped += n
+				if maxDropped > 0 && dropped >= maxDropped {
+					onError(ConsoleDroppedEvent, errors.Errorf("client is too slow: dropped %d console lines", dropped))
+					break
+				}
+				if !behind {
+					behind = true
+					h.Logger().WithField("dropped", dropped).Debug("websocket client is falling behind on console output")
+					if err := h.SendJson(Message{Event: ConsoleDroppedEvent, Args: []string{strconv.FormatUint(dropped, 10)}}); err != nil {
+						onError(ConsoleDroppedEvent, err)
+						break
+					}
+				}
+			} else {
+				behind = false
+			}
 			sendErr := h.SendJson(Message{Event: server.ConsoleOutputEvent, Args: []string{string(b)}})
 			if sendErr == nil {
 				continue
@@ -152,6 +183,10 @@ func (h *Handler) listenForServerEvents(ctx context.Context) error {
 		break
 	}
 
+	if dropped > 0 {
+		h.Logger().WithField("dropped", dropped).Info("websocket client dropped console output while connected")
+	}
+
 	// These functions will automatically close the channel if it hasn't been already.
 	h.server.Events().Off(eventChan)
 	h.server.Sink(system.LogSink).Off(logOutput)
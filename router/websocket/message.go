@@ -11,6 +11,7 @@ const (
 	SendStatsEvent             = "send stats"
 	ErrorEvent                 = "daemon error"
 	JwtErrorEvent              = "jwt error"
+	ConsoleDroppedEvent        = "console output dropped"
 )
 
 type Message struct {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -91,7 +92,7 @@ func GetHandler(s *server.Server, w http.ResponseWriter, r *http.Request, c *gin
 			if o == config.Get().PanelLocation {
 				return true
 			}
-			for _, origin := range config.Get().AllowedOrigins {
+			for _, origin := range slices.Concat(config.Get().AllowedOrigins, config.Get().PanelFallbackLocations) {
 				if origin == "*" || origin == o {
 					return true
 				}
@@ -432,7 +433,16 @@ func (h *Handler) HandleInbound(ctx context.Context, m Message) error {
 				}
 			}
 
-			if err := h.server.Environment.SendCommand(strings.Join(m.Args, "")); err != nil {
+			if err := h.server.SendCommand(ctx, strings.Join(m.Args, "")); err != nil {
+				if errors.Is(err, server.ErrServerNotRunning) {
+					return nil
+				}
+				if errors.Is(err, server.ErrCommandRateLimited) {
+					return h.unsafeSendJson(Message{
+						Event: ErrorEvent,
+						Args:  []string{"Commands are being sent to this server too quickly."},
+					})
+				}
 				return err
 			}
 			h.server.SaveActivity(h.ra, server.ActivityConsoleCommand, models.ActivityMeta{
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"io"
 	"io/fs"
 	"os"
@@ -106,20 +107,31 @@ func (s *Server) Backup(b backup.BackupInterface) error {
 		"uuid":          b.Identifier(),
 		"is_successful": true,
 		"checksum":      ad.Checksum,
-		"checksum_type": "sha1",
+		"checksum_type": ad.ChecksumType,
 		"file_size":     ad.Size,
 	})
 
 	return nil
 }
 
+// RestoreFailure describes a single file that could not be restored from a
+// backup archive when a restoration is run with continueOnError enabled.
+type RestoreFailure struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
 // RestoreBackup calls the Restore function on the provided backup. Once this
 // restoration is completed an event is emitted to the websocket to notify the
 // Panel that is has been completed.
 //
 // In addition to the websocket event an API call is triggered to notify the
 // Panel of the new state.
-func (s *Server) RestoreBackup(b backup.BackupInterface, reader io.ReadCloser) (err error) {
+//
+// If continueOnError is true any individual file that fails to be written to
+// the disk is skipped rather than aborting the entire restoration. All of the
+// skipped files are returned to the caller once the restoration has finished.
+func (s *Server) RestoreBackup(b backup.BackupInterface, reader io.ReadCloser, continueOnError bool) (failures []RestoreFailure, err error) {
 	s.Config().SetSuspended(true)
 	// Local backups will not pass a reader through to this function, so check first
 	// to make sure it is a valid reader before trying to close it.
@@ -143,7 +155,7 @@ func (s *Server) RestoreBackup(b backup.BackupInterface, reader io.ReadCloser) (
 	if s.Environment.State() != environment.ProcessOfflineState {
 		if err = s.Environment.WaitForStop(s.Context(), 2*time.Minute, false); err != nil {
 			if !client.IsErrNotFound(err) {
-				return errors.WrapIf(err, "server/backup: restore: failed to wait for container stop")
+				return nil, errors.WrapIf(err, "server/backup: restore: failed to wait for container stop")
 			}
 		}
 	}
@@ -157,12 +169,32 @@ func (s *Server) RestoreBackup(b backup.BackupInterface, reader io.ReadCloser) (
 		// TODO: since this will be called a lot, it may be worth adding an optimized
 		// Write with Chtimes method to the UnixFS that is able to re-use the
 		// same dirfd and file name.
-		if err := s.Filesystem().Write(file, r, info.Size(), info.Mode()); err != nil {
-			return err
+		err := backup.OpenError(r)
+		if err == nil {
+			err = s.Filesystem().Write(file, r, info.Size(), info.Mode())
+		}
+		if err == nil {
+			atime := info.ModTime()
+			err = s.Filesystem().Chtimes(file, atime, atime)
 		}
-		atime := info.ModTime()
-		return s.Filesystem().Chtimes(file, atime, atime)
+		if err != nil && continueOnError && !errors.Is(err, context.Canceled) {
+			s.Log().WithFields(log.Fields{"file": file, "error": err}).Warn("failed to restore file from backup, skipping")
+			s.Events().Publish(DaemonMessageEvent, "(restoring): skipped "+file+": "+err.Error())
+			failures = append(failures, RestoreFailure{File: file, Error: err.Error()})
+			return nil
+		}
+		return err
 	})
 
-	return errors.WithStackIf(err)
+	// Restoring a ZFS snapshot replaces the dataset without going through the filesystem,
+	// so it is opened again and the cached disk usage is updated to match the disk.
+	if _, ok := b.(*backup.ZfsBackup); ok {
+		if ferr := s.Filesystem().Reopen(); ferr != nil {
+			s.Log().WithField("error", ferr).Error("failed to reopen server filesystem after restoring zfs backup")
+		} else if _, derr := s.Filesystem().DiskUsage(false); derr != nil {
+			s.Log().WithField("error", derr).Warn("failed to update disk usage after restoring zfs backup")
+		}
+	}
+
+	return failures, errors.WithStackIf(err)
 }
@@ -3,16 +3,20 @@ package backup
 import (
 	"context"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
+	"hash"
 	"io"
 	"io/fs"
 	"os"
 	"path"
+	"strings"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
 	"github.com/mholt/archives"
 	"golang.org/x/sync/errgroup"
+	"lukechampine.com/blake3"
 
 	"github.com/pelican-dev/wings/config"
 	"github.com/pelican-dev/wings/remote"
@@ -30,12 +34,56 @@ type AdapterType string
 const (
 	LocalBackupAdapter AdapterType = "wings"
 	S3BackupAdapter    AdapterType = "s3"
+	// ZfsBackupAdapter is used in place of the local adapter by Wings for servers
+	// stored on their own ZFS dataset, it is never requested by the Panel.
+	ZfsBackupAdapter AdapterType = "zfs"
 )
 
+// The file extensions used for backups stored on the disk.
+const (
+	archiveExtension = ".tar.gz"
+	zfsExtension     = ".zfs"
+)
+
+// ErrIntegrityCheckFailed is returned when the checksum of a backup stored on
+// the disk does not match the checksum that was recorded when it was created.
+var ErrIntegrityCheckFailed = errors.Sentinel("backup: backup integrity check failed")
+
 // RestoreCallback is a generic restoration callback that exists for both local
 // and remote backups allowing the files to be restored.
 type RestoreCallback func(file string, info fs.FileInfo, r io.ReadCloser) error
 
+// openFailure is passed to a RestoreCallback in place of the contents of a file
+// in a backup that could not be opened, so that the caller can decide whether
+// to skip the file or abort the restoration.
+type openFailure struct {
+	err error
+}
+
+func (o openFailure) Read([]byte) (int, error) { return 0, o.err }
+func (o openFailure) Close() error             { return nil }
+
+// OpenError returns the error encountered opening a file from a backup if the
+// reader passed to a RestoreCallback is for a file that could not be opened.
+func OpenError(r io.ReadCloser) error {
+	if o, ok := r.(openFailure); ok {
+		return o.err
+	}
+	return nil
+}
+
+// restoreEntry opens a single file from a backup archive and passes it to the
+// callback. Files that cannot be opened are still passed to the callback, see
+// OpenError.
+func restoreEntry(f archives.FileInfo, callback RestoreCallback) error {
+	r, err := f.Open()
+	if err != nil {
+		return callback(f.NameInArchive, f.FileInfo, openFailure{err: errors.WithStack(err)})
+	}
+	defer r.Close()
+	return callback(f.NameInArchive, f.FileInfo, r)
+}
+
 // noinspection GoNameStartsWithPackageName
 type BackupInterface interface {
 	// SetClient sets the API request client on the backup interface.
@@ -53,8 +101,12 @@ type BackupInterface interface {
 	Generate(context.Context, *filesystem.Filesystem, string) (*ArchiveDetails, error)
 	// Ignored returns the ignored files for this backup instance.
 	Ignored() string
-	// Checksum returns a SHA1 checksum for the generated backup.
+	// Checksum returns a checksum for the generated backup using the configured
+	// checksum algorithm.
 	Checksum() ([]byte, error)
+	// VerifyChecksum checks that the backup stored on the disk matches the given
+	// hex encoded checksum, created using the given algorithm.
+	VerifyChecksum(algorithm string, expected string) error
 	// Size returns the size of the generated backup.
 	Size() (int64, error)
 	// Path returns the path to the backup on the machine. This is not always
@@ -86,6 +138,9 @@ type Backup struct {
 	client     remote.Client
 	adapter    AdapterType
 	logContext map[string]interface{}
+	// The file extension of the backup on the disk, if empty the backup is
+	// stored as a gzipped tar archive.
+	extension string
 }
 
 func (b *Backup) SetClient(c remote.Client) {
@@ -100,7 +155,11 @@ func (b *Backup) ServerId() string { return b.ServerUuid }
 
 // Path returns the path for this specific backup.
 func (b *Backup) Path() string {
-	return path.Join(config.Get().System.BackupDirectory, b.ServerId(), b.Identifier()+".tar.gz")
+	ext := b.extension
+	if ext == "" {
+		ext = archiveExtension
+	}
+	return path.Join(config.Get().System.BackupDirectory, b.ServerId(), b.Identifier()+ext)
 }
 
 // Size returns the size of the generated backup.
@@ -113,9 +172,31 @@ func (b *Backup) Size() (int64, error) {
 	return st.Size(), nil
 }
 
-// Checksum returns the SHA256 checksum of a backup.
+// NewChecksum returns a hash for the given checksum algorithm, which must be one
+// of the algorithms allowed by the backup configuration. This should be used to
+// verify a backup using the algorithm stored alongside its checksum.
+func NewChecksum(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "sha256":
+		return sha256.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "blake3":
+		return blake3.New(32, nil), nil
+	}
+	return nil, errors.New("backup: unsupported checksum algorithm: " + algorithm)
+}
+
+// Checksum returns the checksum of a backup using the configured algorithm.
 func (b *Backup) Checksum() ([]byte, error) {
-	h := sha1.New()
+	return b.checksum(config.Get().System.Backups.ChecksumAlgorithm)
+}
+
+func (b *Backup) checksum(algorithm string) ([]byte, error) {
+	h, err := NewChecksum(algorithm)
+	if err != nil {
+		return nil, err
+	}
 
 	f, err := os.Open(b.Path())
 	if err != nil {
@@ -131,14 +212,30 @@ func (b *Backup) Checksum() ([]byte, error) {
 	return h.Sum(nil), nil
 }
 
+// VerifyChecksum compares the checksum of the backup stored on the disk against
+// the expected hex encoded checksum, returning ErrIntegrityCheckFailed if they
+// do not match. The algorithm must be the one the checksum was created with, since
+// the configured algorithm may have changed since the backup was created.
+func (b *Backup) VerifyChecksum(algorithm string, expected string) error {
+	sum, err := b.checksum(algorithm)
+	if err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(sum); !strings.EqualFold(actual, expected) {
+		return errors.WithDetails(ErrIntegrityCheckFailed, "algorithm", algorithm, "expected", expected, "actual", actual)
+	}
+	return nil
+}
+
 // Details returns both the checksum and size of the archive currently stored on
 // the disk to the caller.
 func (b *Backup) Details(ctx context.Context, parts []remote.BackupPart) (*ArchiveDetails, error) {
-	ad := ArchiveDetails{ChecksumType: "sha1", Parts: parts}
+	algorithm := config.Get().System.Backups.ChecksumAlgorithm
+	ad := ArchiveDetails{ChecksumType: algorithm, Parts: parts}
 	g, ctx := errgroup.WithContext(ctx)
 
 	g.Go(func() error {
-		resp, err := b.Checksum()
+		resp, err := b.checksum(algorithm)
 		if err != nil {
 			return err
 		}
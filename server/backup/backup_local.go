@@ -5,6 +5,9 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"emperror.dev/errors"
 	"github.com/juju/ratelimit"
@@ -34,10 +37,15 @@ func NewLocal(client remote.Client, uuid string, suuid string, ignore string) *L
 }
 
 // LocateLocal finds the backup for a server and returns the local path. This
-// will obviously only work if the backup was created as a local backup.
-func LocateLocal(client remote.Client, uuid string, suuid string) (*LocalBackup, os.FileInfo, error) {
-	b := NewLocal(client, uuid, suuid, "")
+// will obviously only work if the backup was created as a local backup. If the
+// backup was created from a ZFS snapshot a ZfsBackup is returned.
+func LocateLocal(client remote.Client, uuid string, suuid string) (BackupInterface, os.FileInfo, error) {
+	var b BackupInterface = NewLocal(client, uuid, suuid, "")
 	st, err := os.Stat(b.Path())
+	if errors.Is(err, os.ErrNotExist) {
+		b = NewZfs(client, uuid, suuid, "", "")
+		st, err = os.Stat(b.Path())
+	}
 	if err != nil {
 		return nil, nil, err
 	}
@@ -49,6 +57,56 @@ func LocateLocal(client remote.Client, uuid string, suuid string) (*LocalBackup,
 	return b, st, nil
 }
 
+// LocalBackupFile describes a backup archive stored on the disk for a server.
+type LocalBackupFile struct {
+	Uuid      string    `json:"uuid"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListLocal returns all the backup archives stored on the disk for the given
+// server, ordered from the oldest to the newest. Temporary files left behind by
+// backups that are in progress, or that failed, are not included. If the server
+// has never had a local backup created an empty slice is returned.
+func ListLocal(suuid string) ([]LocalBackupFile, error) {
+	dir := filepath.Join(config.Get().System.BackupDirectory, suuid)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []LocalBackupFile{}, nil
+		}
+		return nil, err
+	}
+
+	backups := make([]LocalBackupFile, 0, len(entries))
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if strings.HasSuffix(e.Name(), archiveExtension) {
+			ext = archiveExtension
+		}
+		if !e.Type().IsRegular() || (ext != archiveExtension && ext != zfsExtension) {
+			continue
+		}
+		st, err := e.Info()
+		if err != nil {
+			// The backup was removed while the directory was being read.
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		backups = append(backups, LocalBackupFile{
+			Uuid:      strings.TrimSuffix(e.Name(), ext),
+			Size:      st.Size(),
+			CreatedAt: st.ModTime(),
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.Before(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
 // Remove removes a backup from the system.
 func (b *LocalBackup) Remove() error {
 	err := os.Remove(b.Path())
@@ -116,13 +174,7 @@ func (b *LocalBackup) Restore(ctx context.Context, _ io.Reader, callback Restore
 		reader = ratelimit.Reader(f, ratelimit.NewBucketWithRate(float64(writeLimit), writeLimit))
 	}
 	if err := format.Extract(ctx, reader, func(ctx context.Context, f archives.FileInfo) error {
-		r, err := f.Open()
-		if err != nil {
-			return err
-		}
-		defer r.Close()
-
-		return callback(f.NameInArchive, f.FileInfo, r)
+		return restoreEntry(f, callback)
 	}); err != nil {
 		return err
 	}
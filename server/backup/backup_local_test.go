@@ -0,0 +1,130 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+
+	"github.com/pelican-dev/wings/config"
+)
+
+func TestLocalBackup_ListLocal(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("ListLocal", func() {
+		var dir string
+
+		g.BeforeEach(func() {
+			dir = newBackupDirectory()
+		})
+
+		g.AfterEach(func() {
+			_ = os.RemoveAll(filepath.Dir(dir))
+		})
+
+		g.It("returns an empty slice if the server has no backups", func() {
+			_ = os.RemoveAll(dir)
+
+			backups, err := ListLocal("server")
+			g.Assert(err).IsNil()
+			g.Assert(len(backups)).Equal(0)
+		})
+
+		g.It("includes archives and zfs snapshots ordered by their creation time", func() {
+			createBackupFile(dir, "newer.zfs", time.Now())
+			createBackupFile(dir, "older.tar.gz", time.Now().Add(-time.Hour))
+
+			backups, err := ListLocal("server")
+			g.Assert(err).IsNil()
+			g.Assert(len(backups)).Equal(2)
+			g.Assert(backups[0].Uuid).Equal("older")
+			g.Assert(backups[1].Uuid).Equal("newer")
+		})
+
+		g.It("does not include temporary or unknown files", func() {
+			createBackupFile(dir, "partial.zfs.part", time.Now())
+			createBackupFile(dir, "partial.tar.gz.part", time.Now())
+			createBackupFile(dir, "notes.txt", time.Now())
+			g.Assert(os.Mkdir(filepath.Join(dir, "directory.zfs"), 0o700)).IsNil()
+
+			backups, err := ListLocal("server")
+			g.Assert(err).IsNil()
+			g.Assert(len(backups)).Equal(0)
+		})
+	})
+}
+
+func TestLocalBackup_LocateLocal(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("LocateLocal", func() {
+		var dir string
+
+		g.BeforeEach(func() {
+			dir = newBackupDirectory()
+		})
+
+		g.AfterEach(func() {
+			_ = os.RemoveAll(filepath.Dir(dir))
+		})
+
+		g.It("locates an archive backup", func() {
+			createBackupFile(dir, "backup.tar.gz", time.Now())
+
+			b, _, err := LocateLocal(nil, "backup", "server")
+			g.Assert(err).IsNil()
+			_, ok := b.(*LocalBackup)
+			g.Assert(ok).IsTrue("backup is not a *LocalBackup")
+		})
+
+		g.It("falls back to a zfs snapshot backup", func() {
+			createBackupFile(dir, "backup.zfs", time.Now())
+
+			b, _, err := LocateLocal(nil, "backup", "server")
+			g.Assert(err).IsNil()
+			_, ok := b.(*ZfsBackup)
+			g.Assert(ok).IsTrue("backup is not a *ZfsBackup")
+			g.Assert(b.Path()).Equal(filepath.Join(dir, "backup.zfs"))
+		})
+
+		g.It("returns an error if no backup exists", func() {
+			_, _, err := LocateLocal(nil, "backup", "server")
+			g.Assert(err).IsNotNil()
+			g.Assert(os.IsNotExist(err)).IsTrue()
+		})
+	})
+}
+
+// newBackupDirectory configures a temporary backup directory and returns the
+// directory used for the backups of a server named "server".
+func newBackupDirectory() string {
+	tmp, err := os.MkdirTemp(os.TempDir(), "pelican-backups")
+	if err != nil {
+		panic(err)
+	}
+	config.Set(&config.Configuration{
+		AuthenticationToken: "abc",
+		System: config.SystemConfiguration{
+			BackupDirectory: tmp,
+			Backups:         config.Backups{ChecksumAlgorithm: "sha256"},
+		},
+	})
+	dir := filepath.Join(tmp, "server")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		panic(err)
+	}
+	return dir
+}
+
+func createBackupFile(dir string, name string, modTime time.Time) {
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte("backup"), 0o600); err != nil {
+		panic(err)
+	}
+	if err := os.Chtimes(p, modTime, modTime); err != nil {
+		panic(err)
+	}
+}
@@ -2,18 +2,24 @@ package backup
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"emperror.dev/errors"
 	"github.com/cenkalti/backoff/v4"
 	"github.com/juju/ratelimit"
 	"github.com/mholt/archives"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/pelican-dev/wings/config"
 	"github.com/pelican-dev/wings/remote"
@@ -40,7 +46,10 @@ func NewS3(client remote.Client, uuid string, suuid string, ignore string) *S3Ba
 
 // Remove removes a backup from the system.
 func (s *S3Backup) Remove() error {
-	return os.Remove(s.Path())
+	if err := os.Remove(s.Path()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
 }
 
 // WithLogContext attaches additional context to the log output for this backup.
@@ -51,6 +60,10 @@ func (s *S3Backup) WithLogContext(c map[string]interface{}) {
 // Generate creates a new backup on the disk, moves it into the S3 bucket via
 // the provided presigned URL, and then deletes the backup from the disk.
 func (s *S3Backup) Generate(ctx context.Context, fsys *filesystem.Filesystem, ignore string) (*ArchiveDetails, error) {
+	if config.Get().System.Backups.S3Streaming {
+		return s.generateStreamed(ctx, fsys, ignore)
+	}
+
 	defer s.Remove()
 
 	a := &filesystem.Archive{
@@ -59,14 +72,13 @@ func (s *S3Backup) Generate(ctx context.Context, fsys *filesystem.Filesystem, ig
 	}
 
 	s.log().WithField("path", s.Path()).Info("creating backup for server")
-	if _, err := os.Stat(filepath.Dir(s.Path())); os.IsNotExist(err) {
-		err := os.Mkdir(filepath.Dir(s.Path()), 0o700)
-		if err != nil {
-			return nil, err
-		}
+	// Create the entire path to the temporary archive, the backup directory itself
+	// may not exist yet if only the S3 adapter has ever been used on this node.
+	if err := os.MkdirAll(filepath.Dir(s.Path()), 0o700); err != nil {
+		return nil, errors.Wrap(err, "backup: failed to create temporary backup directory")
 	}
 	if err := a.Create(ctx, s.Path()); err != nil {
-		return nil, err
+		return nil, errors.WrapIf(err, "backup: failed to create temporary archive")
 	}
 	s.log().Info("created backup successfully")
 
@@ -102,23 +114,18 @@ func (s *S3Backup) Restore(ctx context.Context, r io.Reader, callback RestoreCal
 		reader = ratelimit.Reader(r, ratelimit.NewBucketWithRate(float64(writeLimit), writeLimit))
 	}
 	if err := format.Extract(ctx, reader, func(ctx context.Context, f archives.FileInfo) error {
-		r, err := f.Open()
-		if err != nil {
-			return err
-		}
-		defer r.Close()
-
-		return callback(f.NameInArchive, f.FileInfo, r)
+		return restoreEntry(f, callback)
 	}); err != nil {
 		return err
 	}
 	return nil
 }
 
-// Generates the remote S3 request and begins the upload.
-func (s *S3Backup) generateRemoteRequest(ctx context.Context, rc io.ReadCloser) ([]remote.BackupPart, error) {
-	defer rc.Close()
-
+// Generates the remote S3 request and begins the upload. Parts are uploaded
+// concurrently up to the configured limit, and if any part fails to upload the
+// remaining uploads are canceled. The failure is then reported to the Panel,
+// which aborts the multipart upload so that no orphaned parts are left behind.
+func (s *S3Backup) generateRemoteRequest(ctx context.Context, f *os.File) ([]remote.BackupPart, error) {
 	s.log().Debug("attempting to get size of backup...")
 	size, err := s.Backup.Size()
 	if err != nil {
@@ -126,15 +133,23 @@ func (s *S3Backup) generateRemoteRequest(ctx context.Context, rc io.ReadCloser)
 	}
 	s.log().WithField("size", size).Debug("got size of backup")
 
+	cfg := config.Get().System.Backups
 	s.log().Debug("attempting to get S3 upload urls from Panel...")
-	urls, err := s.client.GetBackupRemoteUploadURLs(context.Background(), s.Backup.Uuid, size)
+	urls, err := s.client.GetBackupRemoteUploadURLs(context.Background(), s.Backup.Uuid, size, cfg.S3PartSize*1024*1024)
 	if err != nil {
 		return nil, err
 	}
+	if err := validateUploadURLs(urls.Parts); err != nil {
+		return nil, err
+	}
 	s.log().Debug("got S3 upload urls from the Panel")
 	s.log().WithField("parts", len(urls.Parts)).Info("attempting to upload backup to s3 endpoint...")
 
-	uploader := newS3FileUploader(rc)
+	uploader := newS3FileUploader()
+	parts := make([]remote.BackupPart, len(urls.Parts))
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(cfg.S3UploadConcurrency, 1))
 	for i, part := range urls.Parts {
 		// Get the size for the current part.
 		var partSize int64
@@ -145,34 +160,40 @@ func (s *S3Backup) generateRemoteRequest(ctx context.Context, rc io.ReadCloser)
 			// there is not a minimum size limit for the last part.
 			partSize = size - (int64(i) * urls.PartSize)
 		}
-
-		// Attempt to upload the part.
-		etag, err := uploader.uploadPart(ctx, part, partSize)
-		if err != nil {
-			s.log().WithField("part_id", i+1).WithError(err).Warn("failed to upload part")
-			return nil, err
-		}
-		uploader.uploadedParts = append(uploader.uploadedParts, remote.BackupPart{
-			ETag:       etag,
-			PartNumber: i + 1,
+		offset := int64(i) * urls.PartSize
+
+		g.Go(func() error {
+			// Attempt to upload the part.
+			etag, err := uploader.put(ctx, part, partSize, func() io.Reader {
+				return io.NewSectionReader(f, offset, partSize)
+			}, nil)
+			if err != nil {
+				s.log().WithField("part_id", i+1).WithError(err).Warn("failed to upload part")
+				return err
+			}
+			parts[i] = remote.BackupPart{
+				ETag:       etag,
+				PartNumber: i + 1,
+			}
+			s.log().WithField("part_id", i+1).Info("successfully uploaded backup part")
+			return nil
 		})
-		s.log().WithField("part_id", i+1).Info("successfully uploaded backup part")
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
 	}
 	s.log().WithField("parts", len(urls.Parts)).Info("backup has been successfully uploaded")
 
-	return uploader.uploadedParts, nil
+	return parts, nil
 }
 
 type s3FileUploader struct {
-	io.ReadCloser
-	client        *http.Client
-	uploadedParts []remote.BackupPart
+	client *http.Client
 }
 
 // newS3FileUploader returns a new file uploader instance.
-func newS3FileUploader(file io.ReadCloser) *s3FileUploader {
+func newS3FileUploader() *s3FileUploader {
 	return &s3FileUploader{
-		ReadCloser: file,
 		// We purposefully use a super high timeout on this request since we need to upload
 		// a 5GB file. This assumes at worst a 10Mbps connection for uploading. While technically
 		// you could go slower we're targeting mostly hosted servers that should have 100Mbps
@@ -191,26 +212,27 @@ func (fu *s3FileUploader) backoff(ctx context.Context) backoff.BackOffContext {
 	return backoff.WithContext(b, ctx)
 }
 
-// uploadPart attempts to upload a given S3 file part to the S3 system. If a
-// 5xx error is returned from the endpoint this will continue with an exponential
-// backoff to try and successfully upload the part.
+// put performs the request to upload a single part to S3, retrying the request
+// with an exponential backoff if the endpoint returns a 5xx error. The body
+// function is called for every attempt to get the reader for the request body.
 //
 // Once uploaded the ETag is returned to the caller.
-func (fu *s3FileUploader) uploadPart(ctx context.Context, part string, size int64) (string, error) {
-	r, err := http.NewRequestWithContext(ctx, http.MethodPut, part, nil)
-	if err != nil {
-		return "", errors.Wrap(err, "backup: could not create request for S3")
-	}
-
-	r.ContentLength = size
-	r.Header.Add("Content-Length", strconv.Itoa(int(size)))
-	r.Header.Add("Content-Type", "application/x-gzip")
+//
+// If a checksum is provided it is compared against the ETag returned by the
+// endpoint, and the part is uploaded again if they do not match.
+func (fu *s3FileUploader) put(ctx context.Context, part string, size int64, body func() io.Reader, checksum []byte) (string, error) {
+	var etag string
+	err := backoff.Retry(func() error {
+		r, err := http.NewRequestWithContext(ctx, http.MethodPut, part, nil)
+		if err != nil {
+			return backoff.Permanent(errors.Wrap(err, "backup: could not create request for S3"))
+		}
 
-	// Limit the reader to the size of the part.
-	r.Body = Reader{Reader: io.LimitReader(fu.ReadCloser, size)}
+		r.ContentLength = size
+		r.Header.Add("Content-Length", strconv.Itoa(int(size)))
+		r.Header.Add("Content-Type", "application/x-gzip")
+		r.Body = Reader{Reader: body()}
 
-	var etag string
-	err = backoff.Retry(func() error {
 		res, err := fu.client.Do(r)
 		if err != nil {
 			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
@@ -220,10 +242,10 @@ func (fu *s3FileUploader) uploadPart(ctx context.Context, part string, size int6
 			// the URL due to DNS issues we want to keep re-trying.
 			return errors.Wrap(err, "backup: S3 HTTP request failed")
 		}
-		_ = res.Body.Close()
+		defer res.Body.Close()
 
 		if res.StatusCode != http.StatusOK {
-			err := errors.New(fmt.Sprintf("backup: failed to put S3 object: [HTTP/%d] %s", res.StatusCode, res.Status))
+			err := s3ResponseError(r, res)
 			// Only attempt a backoff retry if this error is because of a 5xx error from
 			// the S3 endpoint. Any 4xx error should be treated as an error that a retry
 			// would not fix.
@@ -236,6 +258,9 @@ func (fu *s3FileUploader) uploadPart(ctx context.Context, part string, size int6
 		// Get the ETag from the uploaded part, this should be sent with the
 		// CompleteMultipartUpload request.
 		etag = res.Header.Get("ETag")
+		if checksum != nil && !etagMatches(etag, checksum) {
+			return errors.New(fmt.Sprintf("backup: S3 part ETag %s does not match checksum %s", etag, hex.EncodeToString(checksum)))
+		}
 
 		return nil
 	}, fu.backoff(ctx))
@@ -249,6 +274,68 @@ func (fu *s3FileUploader) uploadPart(ctx context.Context, part string, size int6
 	return etag, nil
 }
 
+// Checks that the presigned upload URLs returned by the Panel are valid, so that
+// a misconfigured S3 endpoint results in a clear error before the upload starts.
+func validateUploadURLs(parts []string) error {
+	for _, p := range parts {
+		u, err := url.Parse(p)
+		if err != nil {
+			return errors.Wrap(err, "backup: Panel returned an invalid S3 upload url")
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("backup: Panel returned an invalid S3 upload url: check the S3 endpoint configured on the Panel")
+		}
+	}
+	return nil
+}
+
+// Returns an error describing why a request to S3 failed. S3 compatible storage
+// providers return the reason for the failure as an XML document, which is
+// included in the error along with a hint for the common configuration problems
+// that cause requests to be rejected.
+func s3ResponseError(req *http.Request, res *http.Response) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+	_ = xml.Unmarshal(b, &body)
+
+	msg := fmt.Sprintf("backup: failed to put S3 object: [HTTP/%d] %s", res.StatusCode, res.Status)
+	if body.Code != "" {
+		msg += ": " + body.Code
+		if body.Message != "" {
+			msg += " (" + body.Message + ")"
+		}
+	}
+
+	switch body.Code {
+	case "NoSuchBucket", "PermanentRedirect", "AuthorizationHeaderMalformed":
+		msg += ": check the bucket, region, and path style endpoint settings on the Panel"
+	case "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch":
+		msg += ": check the S3 credentials and signature version configured on the Panel"
+	}
+	if strings.Contains(req.URL.Path, "//") {
+		msg += ": the upload url contains an empty path segment, ensure the S3 endpoint configured on the Panel does not end with a trailing slash"
+	}
+	return errors.New(msg)
+}
+
+// Checks that the ETag returned by S3 matches the MD5 checksum of the uploaded
+// part. Some providers, and objects using certain types of server-side
+// encryption, do not return the MD5 checksum as the ETag, in which case the
+// ETag cannot be verified and is assumed to be correct.
+func etagMatches(etag string, checksum []byte) bool {
+	etag = strings.Trim(etag, "\"")
+	if len(etag) != hex.EncodedLen(md5.Size) {
+		return true
+	}
+	if _, err := hex.DecodeString(etag); err != nil {
+		return true
+	}
+	return strings.EqualFold(etag, hex.EncodeToString(checksum))
+}
+
 // Reader provides a wrapper around an existing io.Reader
 // but implements io.Closer in order to satisfy an io.ReadCloser.
 type Reader struct {
@@ -0,0 +1,190 @@
+package backup
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"emperror.dev/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelican-dev/wings/config"
+	"github.com/pelican-dev/wings/remote"
+	"github.com/pelican-dev/wings/server/filesystem"
+)
+
+// The additional space requested from the Panel on top of the size of the server
+// when streaming a backup, to account for the tar headers and the gzip overhead
+// when the data being archived is not compressible.
+const streamedBackupOverhead = 10 * 1024 * 1024
+
+// generateStreamed creates a backup of the server and uploads it to S3 as the
+// archive is being generated. Rather than writing the entire archive to the disk
+// first, each part is written to a temporary file on the disk before it is
+// uploaded, so at most one part per concurrent upload is ever stored locally.
+//
+// Since the final size of the archive is not known ahead of time, the upload
+// URLs are requested from the Panel using the size of the server's files as an
+// upper bound, and any of the parts that are not needed are left unused.
+func (s *S3Backup) generateStreamed(ctx context.Context, fsys *filesystem.Filesystem, ignore string) (*ArchiveDetails, error) {
+	usage, err := fsys.DiskUsage(true)
+	if err != nil {
+		return nil, errors.WrapIf(err, "backup: failed to determine size of server")
+	}
+	estimate := usage + usage/100 + streamedBackupOverhead
+
+	cfg := config.Get().System.Backups
+	s.log().WithField("estimated_size", estimate).Debug("attempting to get S3 upload urls from Panel...")
+	urls, err := s.client.GetBackupRemoteUploadURLs(ctx, s.Backup.Uuid, estimate, cfg.S3PartSize*1024*1024)
+	if err != nil {
+		return nil, err
+	}
+	if urls.PartSize <= 0 || len(urls.Parts) == 0 {
+		return nil, errors.New("backup: Panel did not return any S3 upload urls")
+	}
+	if err := validateUploadURLs(urls.Parts); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(s.Path())
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	// Create a temporary file for each part that can be uploaded at the same time.
+	// A file is taken from the pool while its part is being uploaded, and returned
+	// once the upload has finished so that it can be used for the next part.
+	concurrency := max(cfg.S3UploadConcurrency, 1)
+	files := make(chan *os.File, concurrency)
+	for i := 0; i < concurrency; i++ {
+		tmp, err := os.CreateTemp(dir, s.Identifier()+".*.part")
+		if err != nil {
+			return nil, errors.Wrap(err, "backup: failed to create temporary file for backup part")
+		}
+		defer func() {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}()
+		files <- tmp
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		a := &filesystem.Archive{
+			Filesystem: fsys,
+			Ignore:     ignore,
+		}
+		_ = pw.CloseWithError(a.Stream(ctx, pw))
+	}()
+
+	s.log().WithField("parts", len(urls.Parts)).Info("streaming backup to s3 endpoint...")
+
+	checksum, err := NewChecksum(cfg.ChecksumAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	r := io.TeeReader(pr, checksum)
+	uploader := newS3FileUploader()
+
+	var (
+		mu    sync.Mutex
+		parts []remote.BackupPart
+		size  int64
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	err = func() error {
+		for i, part := range urls.Parts {
+			var tmp *os.File
+			select {
+			case <-gctx.Done():
+				return nil
+			case tmp = <-files:
+			}
+
+			n, err := s.spoolPart(tmp, r, urls.PartSize)
+			if err != nil || n == 0 {
+				files <- tmp
+				return err
+			}
+			size += n
+
+			sum := md5.New()
+			if _, err := io.Copy(sum, io.NewSectionReader(tmp, 0, n)); err != nil {
+				files <- tmp
+				return err
+			}
+
+			g.Go(func() error {
+				defer func() { files <- tmp }()
+				etag, err := uploader.put(gctx, part, n, func() io.Reader {
+					return io.NewSectionReader(tmp, 0, n)
+				}, sum.Sum(nil))
+				if err != nil {
+					s.log().WithField("part_id", i+1).WithError(err).Warn("failed to upload part")
+					return err
+				}
+				mu.Lock()
+				parts = append(parts, remote.BackupPart{ETag: etag, PartNumber: i + 1})
+				mu.Unlock()
+				s.log().WithField("part_id", i+1).Info("successfully uploaded backup part")
+				return nil
+			})
+
+			if n < urls.PartSize {
+				return nil
+			}
+		}
+		return nil
+	}()
+	if werr := g.Wait(); werr != nil {
+		return nil, werr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Ensure that the entire archive was uploaded, if there is anything left in the
+	// stream the archive was larger than the parts that were allocated for it.
+	if n, err := io.CopyN(io.Discard, r, 1); err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.Wrap(err, "backup: failed to generate archive")
+	} else if n > 0 {
+		return nil, errors.New("backup: archive exceeded the number of parts allocated by the Panel")
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber
+	})
+	s.log().WithField("parts", len(parts)).Info("backup has been successfully uploaded")
+
+	return &ArchiveDetails{
+		Checksum:     hex.EncodeToString(checksum.Sum(nil)),
+		ChecksumType: cfg.ChecksumAlgorithm,
+		Size:         size,
+		Parts:        parts,
+	}, nil
+}
+
+// Writes up to size bytes from the reader into the temporary file, replacing any
+// existing contents of the file. The number of bytes written is returned.
+func (s *S3Backup) spoolPart(f *os.File, r io.Reader, size int64) (int64, error) {
+	if err := f.Truncate(0); err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.CopyN(f, r, size)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, errors.Wrap(err, "backup: failed to generate archive")
+	}
+	return n, nil
+}
@@ -0,0 +1,51 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+)
+
+func TestBackup_VerifyChecksum(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("VerifyChecksum", func() {
+		var b *LocalBackup
+
+		g.BeforeEach(func() {
+			dir := newBackupDirectory()
+			createBackupFile(dir, "backup.tar.gz", time.Now())
+			b = NewLocal(nil, "backup", "server", "")
+		})
+
+		g.AfterEach(func() {
+			_ = os.RemoveAll(filepath.Dir(filepath.Dir(b.Path())))
+		})
+
+		g.It("accepts a matching checksum", func() {
+			err := b.VerifyChecksum("sha256", "54d00d867758cef816bc4685f58e327b949712b07ebd17c3485f3ffc9e9f5133")
+			g.Assert(err).IsNil()
+		})
+
+		g.It("compares checksums without regard to case", func() {
+			err := b.VerifyChecksum("sha1", "89121DC99C7DB9CE2553A093A2AB29E07F7DF34F")
+			g.Assert(err).IsNil()
+		})
+
+		g.It("returns an error if the checksum does not match", func() {
+			err := b.VerifyChecksum("sha256", "0000000000000000000000000000000000000000000000000000000000000000")
+			g.Assert(err).IsNotNil()
+			g.Assert(errors.Is(err, ErrIntegrityCheckFailed)).IsTrue("err is not ErrIntegrityCheckFailed")
+		})
+
+		g.It("requires the checksum algorithm", func() {
+			err := b.VerifyChecksum("", "54d00d867758cef816bc4685f58e327b949712b07ebd17c3485f3ffc9e9f5133")
+			g.Assert(err).IsNotNil()
+			g.Assert(errors.Is(err, ErrIntegrityCheckFailed)).IsFalse("err is ErrIntegrityCheckFailed")
+		})
+	})
+}
@@ -0,0 +1,196 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+
+	"github.com/pelican-dev/wings/config"
+	"github.com/pelican-dev/wings/remote"
+	"github.com/pelican-dev/wings/server/filesystem"
+)
+
+// ErrNotZfsDataset is returned when a server's data directory is not the root of
+// its own ZFS dataset, in which case a snapshot would include other data.
+var ErrNotZfsDataset = errors.Sentinel("backup: server data directory is not the root of a zfs dataset")
+
+// ZfsBackup is a local backup that is created by sending a snapshot of the ZFS
+// dataset the server's data is stored on, rather than archiving each file.
+type ZfsBackup struct {
+	LocalBackup
+
+	dataset string
+}
+
+var _ BackupInterface = (*ZfsBackup)(nil)
+
+// NewZfs returns a backup of the given ZFS dataset. The dataset may be empty if
+// the backup is only being located or restored, in which case it is detected
+// from the server's data directory when needed.
+func NewZfs(client remote.Client, uuid string, suuid string, ignore string, dataset string) *ZfsBackup {
+	return &ZfsBackup{
+		LocalBackup: LocalBackup{
+			Backup{
+				client:     client,
+				Uuid:       uuid,
+				ServerUuid: suuid,
+				Ignore:     ignore,
+				adapter:    ZfsBackupAdapter,
+				extension:  zfsExtension,
+			},
+		},
+		dataset: dataset,
+	}
+}
+
+// ZfsDataset returns the name of the ZFS dataset mounted at the given path. An
+// error is returned if the path is not the mountpoint of a dataset.
+func ZfsDataset(ctx context.Context, path string) (string, error) {
+	out, err := runZfs(ctx, nil, nil, "list", "-H", "-o", "name,mountpoint", path)
+	if err != nil {
+		return "", err
+	}
+	name, mountpoint, ok := strings.Cut(strings.TrimSpace(string(out)), "\t")
+	if !ok || filepath.Clean(mountpoint) != filepath.Clean(path) {
+		return "", errors.WithDetails(ErrNotZfsDataset, "path", path)
+	}
+	return name, nil
+}
+
+// Generate takes a snapshot of the server's dataset and writes the output of
+// "zfs send" for it to the disk. The snapshot is destroyed once it has been
+// sent. Snapshots cannot exclude files, so if any files are ignored a regular
+// archive is created instead.
+func (b *ZfsBackup) Generate(ctx context.Context, fsys *filesystem.Filesystem, ignore string) (*ArchiveDetails, error) {
+	if ignore != "" {
+		b.log().Info("backup has ignored files, creating an archive instead of a zfs snapshot")
+		b.adapter = LocalBackupAdapter
+		b.extension = archiveExtension
+		return b.LocalBackup.Generate(ctx, fsys, ignore)
+	}
+	if b.dataset == "" {
+		ds, err := ZfsDataset(ctx, fsys.Path())
+		if err != nil {
+			return nil, err
+		}
+		b.dataset = ds
+	}
+
+	b.log().WithField("path", b.Path()).WithField("dataset", b.dataset).Info("creating zfs snapshot backup for server")
+	if err := os.MkdirAll(filepath.Dir(b.Path()), 0o700); err != nil {
+		return nil, err
+	}
+
+	snapshot := b.snapshot()
+	if _, err := runZfs(ctx, nil, nil, "snapshot", snapshot); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if _, err := runZfs(context.Background(), nil, nil, "destroy", snapshot); err != nil {
+			b.log().WithField("snapshot", snapshot).WithField("error", err).Warn("failed to destroy zfs snapshot after backup")
+		}
+	}()
+
+	// Write the stream to a temporary file first so that a partial stream is never
+	// mistaken for a complete backup.
+	tmp := b.Path() + ".part"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	_, err = runZfs(ctx, nil, f, "send", snapshot)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, b.Path())
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return nil, errors.WrapIf(err, "backup: failed to send zfs snapshot")
+	}
+	b.log().Info("created zfs snapshot backup successfully")
+
+	ad, err := b.Details(ctx, nil)
+	if err != nil {
+		return nil, errors.WrapIf(err, "backup: failed to get archive details for zfs backup")
+	}
+	return ad, nil
+}
+
+// Restore replaces the server's dataset with the one stored in the backup using
+// "zfs receive". Every file in the dataset is replaced, so the callback is never
+// called. The server must not be running while this happens, and the dataset is
+// unmounted while it is received so that nothing is left holding on to the old
+// files. Callers must reopen the server's filesystem once this returns.
+func (b *ZfsBackup) Restore(ctx context.Context, _ io.Reader, _ RestoreCallback) error {
+	if b.dataset == "" {
+		p, err := filepath.EvalSymlinks(filepath.Join(config.Get().System.Data, b.ServerId()))
+		if err != nil {
+			return err
+		}
+		ds, err := ZfsDataset(ctx, p)
+		if err != nil {
+			return err
+		}
+		b.dataset = ds
+	}
+
+	f, err := os.Open(b.Path())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	b.log().WithField("dataset", b.dataset).Info("restoring zfs snapshot backup for server")
+	if _, err := runZfs(ctx, nil, nil, "unmount", b.dataset); err != nil {
+		return errors.WrapIf(err, "backup: failed to unmount zfs dataset before restoring")
+	}
+	// Always mount the dataset again, even if the receive failed, so that the server
+	// is not left without its files.
+	defer func() {
+		if _, err := runZfs(context.Background(), nil, nil, "mount", b.dataset); err != nil {
+			b.log().WithField("dataset", b.dataset).WithField("error", err).Error("failed to mount zfs dataset after restoring backup")
+		}
+	}()
+	if _, err := runZfs(ctx, f, nil, "receive", "-u", "-F", b.dataset); err != nil {
+		return errors.WrapIf(err, "backup: failed to receive zfs snapshot")
+	}
+	// Receiving the stream recreates the snapshot it was sent from on the dataset,
+	// which is not needed once the data has been restored.
+	if _, err := runZfs(ctx, nil, nil, "destroy", b.snapshot()); err != nil {
+		b.log().WithField("error", err).Warn("failed to destroy zfs snapshot after restoring backup")
+	}
+	return nil
+}
+
+// snapshot returns the name of the snapshot used for this backup.
+func (b *ZfsBackup) snapshot() string {
+	return b.dataset + "@wings-" + b.Identifier()
+}
+
+// runZfs runs a zfs command, returning its output. If a writer is provided the
+// output is written to it instead.
+func runZfs(ctx context.Context, stdin io.Reader, stdout io.Writer, args ...string) ([]byte, error) {
+	var out, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "zfs", args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &out
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.Errorf("backup: zfs %s: %s", args[0], msg)
+		}
+		return nil, errors.Wrap(err, "backup: zfs "+args[0])
+	}
+	return out.Bytes(), nil
+}
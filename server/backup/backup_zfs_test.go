@@ -0,0 +1,140 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+
+	"github.com/pelican-dev/wings/server/filesystem"
+)
+
+// fakeZfs is a "zfs" executable that records the commands it is run with. The
+// dataset "pool/server" is reported as mounted at $ZFS_MOUNTPOINT, and the
+// command named by $ZFS_FAIL exits with an error.
+const fakeZfs = `#!/bin/sh
+echo "$@" >> "$ZFS_LOG"
+if [ "$1" = "$ZFS_FAIL" ]; then
+	echo "cannot $1: failed" >&2
+	exit 1
+fi
+case "$1" in
+	list) printf 'pool/server\t%s\n' "$ZFS_MOUNTPOINT" ;;
+	send) printf 'stream' ;;
+	receive) cat > /dev/null ;;
+esac
+`
+
+func TestZfsBackup(t *testing.T) {
+	g := Goblin(t)
+
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "zfs"), []byte(fakeZfs), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	// Returns the commands the fake zfs executable has been run with.
+	commands := func() []string {
+		b, err := os.ReadFile(os.Getenv("ZFS_LOG"))
+		if err != nil {
+			return nil
+		}
+		return strings.Split(strings.TrimSpace(string(b)), "\n")
+	}
+
+	g.Describe("ZfsBackup", func() {
+		var dir, data string
+		var fs *filesystem.Filesystem
+
+		g.BeforeEach(func() {
+			dir = newBackupDirectory()
+			data = filepath.Join(filepath.Dir(dir), "data")
+			t.Setenv("ZFS_LOG", filepath.Join(filepath.Dir(dir), "zfs.log"))
+			t.Setenv("ZFS_MOUNTPOINT", data)
+			t.Setenv("ZFS_FAIL", "")
+
+			var err error
+			fs, err = filesystem.New(data, 0, []string{})
+			g.Assert(err).IsNil()
+			g.Assert(os.WriteFile(filepath.Join(data, "server.log"), []byte("log"), 0o644)).IsNil()
+		})
+
+		g.AfterEach(func() {
+			_ = os.RemoveAll(filepath.Dir(dir))
+		})
+
+		g.It("sends a snapshot of the dataset", func() {
+			b := NewZfs(nil, "backup", "server", "", "")
+			ad, err := b.Generate(context.Background(), fs, "")
+			g.Assert(err).IsNil()
+			g.Assert(ad.Size).Equal(int64(len("stream")))
+			g.Assert(b.Path()).Equal(filepath.Join(dir, "backup.zfs"))
+
+			g.Assert(commands()).Equal([]string{
+				"list -H -o name,mountpoint " + data,
+				"snapshot pool/server@wings-backup",
+				"send pool/server@wings-backup",
+				"destroy pool/server@wings-backup",
+			})
+		})
+
+		g.It("creates an archive instead when files are ignored", func() {
+			b := NewZfs(nil, "backup", "server", "", "")
+			_, err := b.Generate(context.Background(), fs, "*.log")
+			g.Assert(err).IsNil()
+			g.Assert(b.Path()).Equal(filepath.Join(dir, "backup.tar.gz"))
+			g.Assert(b.adapter).Equal(LocalBackupAdapter)
+
+			_, err = os.Stat(b.Path())
+			g.Assert(err).IsNil()
+			g.Assert(len(commands())).Equal(0)
+		})
+
+		g.It("does not create a backup if the snapshot cannot be sent", func() {
+			t.Setenv("ZFS_FAIL", "send")
+
+			b := NewZfs(nil, "backup", "server", "", "pool/server")
+			_, err := b.Generate(context.Background(), fs, "")
+			g.Assert(err).IsNotNil()
+
+			_, err = os.Stat(b.Path())
+			g.Assert(os.IsNotExist(err)).IsTrue()
+			_, err = os.Stat(b.Path() + ".part")
+			g.Assert(os.IsNotExist(err)).IsTrue()
+			g.Assert(commands()[len(commands())-1]).Equal("destroy pool/server@wings-backup")
+		})
+
+		g.It("receives the snapshot into the unmounted dataset", func() {
+			createBackupFile(dir, "backup.zfs", time.Now())
+
+			b := NewZfs(nil, "backup", "server", "", "pool/server")
+			g.Assert(b.Restore(context.Background(), nil, nil)).IsNil()
+
+			g.Assert(commands()).Equal([]string{
+				"unmount pool/server",
+				"receive -u -F pool/server",
+				"destroy pool/server@wings-backup",
+				"mount pool/server",
+			})
+		})
+
+		g.It("mounts the dataset again if the snapshot cannot be received", func() {
+			t.Setenv("ZFS_FAIL", "receive")
+			createBackupFile(dir, "backup.zfs", time.Now())
+
+			b := NewZfs(nil, "backup", "server", "", "pool/server")
+			g.Assert(b.Restore(context.Background(), nil, nil)).IsNotNil()
+
+			g.Assert(commands()).Equal([]string{
+				"unmount pool/server",
+				"receive -u -F pool/server",
+				"mount pool/server",
+			})
+		})
+	})
+}
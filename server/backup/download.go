@@ -0,0 +1,181 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/cenkalti/backoff/v4"
+)
+
+// RemoteDownload streams a backup from a remote location, such as a presigned
+// S3 URL. If the connection fails part way through the download, the request
+// is made again for the remainder of the file using a range request, up to the
+// configured number of retries.
+type RemoteDownload struct {
+	ctx     context.Context
+	client  *http.Client
+	url     string
+	backoff backoff.BackOff
+
+	res    *http.Response
+	offset int64
+}
+
+var _ io.ReadCloser = (*RemoteDownload)(nil)
+
+// retryBudget is a backoff that is never reset, so that the retries are shared
+// by every request made over the course of a download rather than being given
+// again each time the download is resumed.
+type retryBudget struct {
+	backoff.BackOff
+}
+
+func (retryBudget) Reset() {}
+
+// OpenRemoteDownload starts downloading the backup at the given URL. Failed
+// requests, and requests answered with a 5xx status, are retried with an
+// exponential backoff up to "retries" times over the course of the download.
+func OpenRemoteDownload(ctx context.Context, url string, retries int) (*RemoteDownload, error) {
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = 0
+	d := &RemoteDownload{
+		ctx:     ctx,
+		client:  &http.Client{},
+		url:     url,
+		backoff: backoff.WithContext(retryBudget{backoff.WithMaxRetries(b, uint64(max(retries, 0)))}, ctx),
+	}
+	res, err := d.request()
+	if err != nil {
+		return nil, err
+	}
+	d.res = res
+	return d, nil
+}
+
+// ContentType returns the content type of the backup reported by the remote
+// location.
+func (d *RemoteDownload) ContentType() string {
+	return d.res.Header.Get("Content-Type")
+}
+
+// Read reads from the download, requesting the remainder of the file again if
+// the connection fails.
+func (d *RemoteDownload) Read(p []byte) (int, error) {
+	for {
+		n, err := d.res.Body.Read(p)
+		d.offset += int64(n)
+		if err == nil || errors.Is(err, io.EOF) {
+			return n, err
+		}
+		// Return what was read before the failure, the connection will be retried
+		// on the next call since the body continues to return the same error.
+		if n > 0 {
+			return n, nil
+		}
+		if rerr := d.resume(err); rerr != nil {
+			return 0, rerr
+		}
+	}
+}
+
+// Close closes the current connection for the download.
+func (d *RemoteDownload) Close() error {
+	return d.res.Body.Close()
+}
+
+// resume requests the remainder of the file after the connection for the
+// download failed with the given error. The original error is returned if the
+// download cannot be resumed.
+func (d *RemoteDownload) resume(cause error) error {
+	_ = d.res.Body.Close()
+	// The remainder of the file is only requested if it is unchanged since the
+	// download started, otherwise the entire file would be returned instead.
+	v := d.res.Header.Get("ETag")
+	if v == "" {
+		v = d.res.Header.Get("Last-Modified")
+	}
+	if v == "" || d.res.Header.Get("Accept-Ranges") != "bytes" {
+		return cause
+	}
+	log.WithField("offset", d.offset).WithField("error", cause).Warn("backup: download failed, attempting to resume")
+
+	wait := d.backoff.NextBackOff()
+	if wait == backoff.Stop {
+		return errors.WrapIf(cause, "backup: download failed after exhausting all retries")
+	}
+	select {
+	case <-d.ctx.Done():
+		return d.ctx.Err()
+	case <-time.After(wait):
+	}
+
+	// Requests that fail to resume the download are retried using the same budget,
+	// unless the remote location refuses to return the remainder of the file.
+	var res *http.Response
+	var refused bool
+	err := backoff.Retry(func() error {
+		r, err := d.get(func(req *http.Request) {
+			req.Header.Set("Range", "bytes="+strconv.FormatInt(d.offset, 10)+"-")
+			req.Header.Set("If-Range", v)
+		})
+		if err != nil {
+			return err
+		}
+		if r.StatusCode != http.StatusPartialContent {
+			_ = r.Body.Close()
+			refused = true
+			return backoff.Permanent(cause)
+		}
+		res = r
+		return nil
+	}, d.backoff)
+	if refused {
+		return errors.WrapIf(cause, "backup: remote location did not allow the download to be resumed")
+	}
+	if err != nil {
+		return errors.WrapIf(err, "backup: failed to resume download")
+	}
+	// Keep the headers from the first response so that later attempts to resume
+	// the download use the same validator.
+	res.Header = d.res.Header
+	d.res = res
+	return nil
+}
+
+// request performs the initial request for the download, retrying it until it
+// succeeds or there are no retries remaining.
+func (d *RemoteDownload) request() (*http.Response, error) {
+	var res *http.Response
+	err := backoff.Retry(func() error {
+		var err error
+		res, err = d.get(nil)
+		return err
+	}, d.backoff)
+	return res, err
+}
+
+// get performs a single request for the download. Errors that are not worth
+// retrying are marked as permanent.
+func (d *RemoteDownload) get(fn func(req *http.Request)) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		return nil, backoff.Permanent(err)
+	}
+	if fn != nil {
+		fn(req)
+	}
+	res, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= http.StatusInternalServerError {
+		_ = res.Body.Close()
+		return nil, errors.New("backup: remote location responded with status " + res.Status)
+	}
+	return res, nil
+}
@@ -0,0 +1,95 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+// newInterruptedServer returns a server that closes the connection part way
+// through the first response, and responds to each later request using the
+// handler given, which receives the number of the request.
+func newInterruptedServer(content string, fn func(w http.ResponseWriter, r *http.Request, n int32)) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n := requests.Add(1); n > 1 {
+			fn(w, r, n)
+			return
+		}
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			panic(err)
+		}
+		defer conn.Close()
+		_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 10\r\nETag: \"backup\"\r\nAccept-Ranges: bytes\r\n\r\n" + content[:5])
+		_ = buf.Flush()
+	}))
+	return srv, &requests
+}
+
+func TestRemoteDownload(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("RemoteDownload", func() {
+		g.It("resumes the download after a failed request", func() {
+			srv, requests := newInterruptedServer("0123456789", func(w http.ResponseWriter, r *http.Request, n int32) {
+				// Fail the first attempt to resume the download.
+				if n == 2 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				g.Assert(r.Header.Get("Range")).Equal("bytes=5-")
+				g.Assert(r.Header.Get("If-Range")).Equal("\"backup\"")
+				w.Header().Set("Content-Range", "bytes 5-9/10")
+				w.WriteHeader(http.StatusPartialContent)
+				_, _ = w.Write([]byte("56789"))
+			})
+			defer srv.Close()
+
+			d, err := OpenRemoteDownload(context.Background(), srv.URL, 3)
+			g.Assert(err).IsNil()
+			defer d.Close()
+
+			b, err := io.ReadAll(d)
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal("0123456789")
+			g.Assert(requests.Load()).Equal(int32(3))
+		})
+
+		g.It("returns an error once there are no retries remaining", func() {
+			srv, requests := newInterruptedServer("0123456789", func(w http.ResponseWriter, r *http.Request, n int32) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			})
+			defer srv.Close()
+
+			d, err := OpenRemoteDownload(context.Background(), srv.URL, 2)
+			g.Assert(err).IsNil()
+			defer d.Close()
+
+			// The initial request, followed by a request for each of the two retries.
+			_, err = io.ReadAll(d)
+			g.Assert(err).IsNotNil()
+			g.Assert(requests.Load()).Equal(int32(3))
+		})
+
+		g.It("returns an error if the remote location does not resume the download", func() {
+			srv, requests := newInterruptedServer("0123456789", func(w http.ResponseWriter, r *http.Request, n int32) {
+				_, _ = w.Write([]byte("0123456789"))
+			})
+			defer srv.Close()
+
+			d, err := OpenRemoteDownload(context.Background(), srv.URL, 3)
+			g.Assert(err).IsNil()
+			defer d.Close()
+
+			_, err = io.ReadAll(d)
+			g.Assert(err).IsNotNil()
+			g.Assert(requests.Load()).Equal(int32(2))
+		})
+	})
+}
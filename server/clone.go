@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+
+	"github.com/pelican-dev/wings/internal/progress"
+)
+
+// CloneFrom copies all the files belonging to the source server into the data
+// directory of this server. The server must not be running while the files are
+// being copied, and it is marked as restoring for the duration of the copy so
+// that it cannot be started until the process has finished.
+//
+// The progress of the copy is published to the console of this server.
+func (s *Server) CloneFrom(ctx context.Context, src *Server) error {
+	if src.ID() == s.ID() {
+		return errors.New("server: cannot clone a server into itself")
+	}
+	if s.IsRunning() {
+		return ErrIsRunning
+	}
+
+	s.SetRestoring(true)
+	defer s.SetRestoring(false)
+
+	s.Log().WithField("source", src.ID()).Info("cloning files from source server")
+	s.Events().Publish(DaemonMessageEvent, "Copying files from source server...")
+
+	p := progress.NewProgress(0)
+	ctx2, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		t := time.NewTicker(5 * time.Second)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx2.Done():
+				return
+			case <-t.C:
+				s.Events().Publish(DaemonMessageEvent, "Copying files "+p.Progress(25))
+			}
+		}
+	}()
+
+	if err := s.Filesystem().CopyFrom(ctx2, src.Filesystem(), p); err != nil {
+		s.Events().Publish(DaemonMessageEvent, "Failed to copy files from source server.")
+		return errors.WrapIf(err, "server: failed to clone files from source server")
+	}
+
+	s.Events().Publish(DaemonMessageEvent, "Completed copying files from source server.")
+	s.Events().Publish(CloneCompletedEvent, "")
+	s.Log().WithField("source", src.ID()).Info("completed cloning files from source server")
+	return nil
+}
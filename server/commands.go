@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+	"golang.org/x/time/rate"
+
+	"github.com/pelican-dev/wings/config"
+)
+
+var (
+	ErrServerNotRunning   = errors.Sentinel("server: cannot send commands to a stopped server")
+	ErrCommandRateLimited = errors.Sentinel("server: command rate limit exceeded")
+)
+
+// SendCommand sends a command to the server process. An error is returned if
+// the server is not running, or if commands are being sent to the server faster
+// than the configured command rate limit allows.
+func (s *Server) SendCommand(ctx context.Context, command string) error {
+	return s.SendCommands(ctx, []string{command})
+}
+
+// SendCommands sends the commands to the server process in order. If the server
+// is not running, or the command rate limit does not allow every command to be
+// sent, an error is returned without sending any of them. If a command fails to
+// be sent the remaining commands are still sent, and the first error is returned.
+func (s *Server) SendCommands(ctx context.Context, commands []string) error {
+	if running, err := s.Environment.IsRunning(ctx); err != nil {
+		return err
+	} else if !running {
+		return errors.WithStack(ErrServerNotRunning)
+	}
+	if !s.allowCommands(len(commands)) {
+		return errors.WithStack(ErrCommandRateLimited)
+	}
+	var first error
+	for _, command := range commands {
+		if err := s.Environment.SendCommand(command); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// allowCommands returns true if the given number of commands can be sent to the
+// server without exceeding the command rate limit.
+func (s *Server) allowCommands(n int) bool {
+	s.commandLimiterOnce.Do(func() {
+		limit := config.Get().System.CommandRateLimit
+		if limit <= 0 {
+			s.commandLimiter = rate.NewLimiter(rate.Inf, 0)
+			return
+		}
+		s.commandLimiter = rate.NewLimiter(rate.Limit(limit), limit*2)
+	})
+	return s.commandLimiter.AllowN(time.Now(), n)
+}
@@ -3,14 +3,47 @@ package server
 import (
 	"os"
 	"runtime"
+	"sync"
 
 	"fmt"
 	"strings"
 
 	"github.com/gammazero/workerpool"
+
+	"github.com/pelican-dev/wings/config"
 	"github.com/pelican-dev/wings/internal/ufs"
 )
 
+var (
+	configParserOnce    sync.Once
+	configParserWorkers int
+	// configParserSlots limits the number of configuration files being processed
+	// at the same time across every server on the node, so that a large number of
+	// servers booting at once do not oversubscribe the CPU.
+	configParserSlots chan struct{}
+)
+
+// configParserLimits returns the node-wide semaphore used to limit concurrent
+// configuration file processing, along with the number of workers a single
+// server is allowed to use at once. A single server is only ever allowed to use
+// half of the available slots so that one server with a large number of
+// configuration files cannot starve every other server that is booting.
+func configParserLimits() (chan struct{}, int) {
+	configParserOnce.Do(func() {
+		configParserWorkers = config.Get().System.ConfigParserWorkers
+		if configParserWorkers <= 0 {
+			configParserWorkers = runtime.NumCPU()
+		}
+		configParserSlots = make(chan struct{}, configParserWorkers)
+	})
+
+	perServer := configParserWorkers / 2
+	if perServer < 1 {
+		perServer = 1
+	}
+	return configParserSlots, perServer
+}
+
 // Helper function to replace variables in the file path of the configuration parser
 func replaceParserConfigPathVariables(filename string, envvars map[string]interface{}) string {
 	// Check if filename contains at least one '{' and one '}'
@@ -33,8 +66,21 @@ func replaceParserConfigPathVariables(filename string, envvars map[string]interf
 
 // UpdateConfigurationFiles updates all the defined configuration files for
 // a server automatically to ensure that they always use the specified values.
+//
+// Processing is limited by a node-wide pool of workers, configured using the
+// "config_parser_workers" option, which is shared by every server on the node.
 func (s *Server) UpdateConfigurationFiles() {
-	pool := workerpool.New(runtime.NumCPU())
+	slots, workers := configParserLimits()
+	pool := workerpool.New(workers)
+
+	// Node-wide container variables are left out so that any secrets they contain
+	// cannot be written into files the server's users are able to read.
+	environment := make(map[string]string)
+	for _, v := range s.serverEnvironmentVariables() {
+		if k, v, ok := strings.Cut(v, "="); ok {
+			environment[k] = v
+		}
+	}
 
 	s.Log().Debug("acquiring process configuration files...")
 	files := s.ProcessConfiguration().ConfigurationFiles
@@ -43,6 +89,9 @@ func (s *Server) UpdateConfigurationFiles() {
 		f := cf
 
 		pool.Submit(func() {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
 			filename := replaceParserConfigPathVariables(f.FileName, s.Config().EnvVars)
 			file, err := func() (ufs.File, error) {
 				if f.AllowCreateFile {
@@ -61,7 +110,7 @@ func (s *Server) UpdateConfigurationFiles() {
 			}
 			defer file.Close()
 
-			if err := f.Parse(file); err != nil {
+			if err := f.Parse(file, environment); err != nil {
 				s.Log().WithField("error", err).Error("failed to parse and update server configuration file")
 			}
 
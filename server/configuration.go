@@ -14,6 +14,29 @@ type EggConfiguration struct {
 	// or basically any type of access on the server by any user. This is NOT the same
 	// as a per-user denylist, this is defined at the Egg level.
 	FileDenylist []string `json:"file_denylist"`
+
+	// MountTimezoneData determines if the timezone data from the host system should
+	// be mounted into the server container, in addition to the TZ environment
+	// variable, for software that requires /etc/localtime to be present.
+	MountTimezoneData bool `json:"mount_timezone_data"`
+
+	// CrashHook is an optional command that is executed after the server process
+	// is detected as having crashed, before it is automatically restarted.
+	CrashHook *CrashHook `json:"crash_hook"`
+}
+
+// CrashHook defines a command that is executed in a fresh container using the
+// server's image and mounts after a crash, such as removing a stale lock file
+// that would otherwise prevent the server from booting again.
+type CrashHook struct {
+	Command string `json:"command"`
+	// Timeout is the number of seconds the command is allowed to run for before
+	// the container is forcefully stopped. A value of zero uses a one minute
+	// timeout.
+	Timeout int `json:"timeout"`
+	// AbortRestartOnFailure prevents the server from being restarted if the
+	// command fails or does not complete within the timeout.
+	AbortRestartOnFailure bool `json:"abort_restart_on_failure"`
 }
 
 type ConfigurationMeta struct {
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/goccy/go-json"
+)
+
+// ConfigurationApplied is the data sent with a ConfigurationAppliedEvent. Only
+// the names of the fields that changed are included, never their values, so
+// that secrets contained in the configuration are not exposed.
+type ConfigurationApplied struct {
+	// Source is either "node" when the configuration of Wings itself was updated,
+	// or "server" when the configuration of the server was synced from the Panel.
+	Source string `json:"source"`
+	// Changed is the list of fields that changed, this is only included when the
+	// configuration of the server was changed.
+	Changed []string `json:"changed,omitempty"`
+}
+
+// PublishConfigurationApplied notifies any listeners that a new configuration
+// has been applied to the server, if any fields of it were changed.
+func (s *Server) PublishConfigurationApplied(source string, changed []string) {
+	if len(changed) == 0 {
+		return
+	}
+	s.Events().Publish(ConfigurationAppliedEvent, ConfigurationApplied{Source: source, Changed: changed})
+}
+
+// PublishNodeConfigurationApplied notifies any listeners that a new configuration
+// has been applied to Wings itself. The fields that changed are not included
+// since the names alone, such as the hosts of configured registries, can reveal
+// details about the node that users of the server should not be able to see.
+func (s *Server) PublishNodeConfigurationApplied() {
+	s.Events().Publish(ConfigurationAppliedEvent, ConfigurationApplied{Source: "node"})
+}
+
+// ChangedFields returns the dot separated paths of the fields that differ
+// between the JSON representations of the two values provided. A value that
+// has already been encoded can be provided as a json.RawMessage.
+func ChangedFields(before, after interface{}) []string {
+	var a, b interface{}
+	if err := remarshal(before, &a); err != nil {
+		return nil
+	}
+	if err := remarshal(after, &b); err != nil {
+		return nil
+	}
+	var out []string
+	diffFields("", a, b, &out)
+	sort.Strings(out)
+	return out
+}
+
+func remarshal(v interface{}, out *interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+func diffFields(prefix string, a, b interface{}, out *[]string) {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if !aok || !bok {
+		if !reflect.DeepEqual(a, b) && prefix != "" {
+			*out = append(*out, prefix)
+		}
+		return
+	}
+	keys := make(map[string]struct{}, len(am)+len(bm))
+	for k := range am {
+		keys[k] = struct{}{}
+	}
+	for k := range bm {
+		keys[k] = struct{}{}
+	}
+	for k := range keys {
+		p := k
+		if prefix != "" {
+			p = prefix + "." + k
+		}
+		diffFields(p, am[k], bm[k], out)
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+	"github.com/goccy/go-json"
+)
+
+func TestChangedFields(t *testing.T) {
+	g := Goblin(t)
+
+	type nested struct {
+		Memory int64 `json:"memory"`
+		Swap   int64 `json:"swap"`
+	}
+	type cfg struct {
+		Name   string `json:"name"`
+		Secret string `json:"-"`
+		Build  nested `json:"build"`
+	}
+
+	g.Describe("ChangedFields", func() {
+		g.It("returns the paths of nested fields that changed", func() {
+			a := cfg{Name: "a", Build: nested{Memory: 1024, Swap: 0}}
+			b := cfg{Name: "b", Build: nested{Memory: 2048, Swap: 0}}
+
+			g.Assert(ChangedFields(a, b)).Equal([]string{"build.memory", "name"})
+		})
+
+		g.It("does not include fields that are not encoded", func() {
+			a := cfg{Name: "a", Secret: "one"}
+			b := cfg{Name: "a", Secret: "two"}
+
+			g.Assert(len(ChangedFields(a, b))).Equal(0)
+		})
+
+		g.It("accepts values that have already been encoded", func() {
+			a := cfg{Name: "a"}
+			before, err := json.Marshal(a)
+			g.Assert(err).IsNil()
+
+			a.Build.Swap = 512
+			g.Assert(ChangedFields(json.RawMessage(before), a)).Equal([]string{"build.swap"})
+		})
+	})
+}
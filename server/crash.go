@@ -100,8 +100,23 @@ func (s *Server) handleServerCrash() error {
 		"oomkilled": oomKilled,
 		"logs":      logs,
 	})
+	s.recordHistory(ActivityServerCrashed, models.ActivityMeta{
+		"exit_code": exitCode,
+		"oomkilled": oomKilled,
+	})
 	
 	s.crasher.SetLastCrash(time.Now())
 
+	if hook := s.Config().Egg.CrashHook; hook != nil && hook.Command != "" {
+		if err := s.runCrashHook(hook); err != nil {
+			s.Log().WithField("error", err).Warn("failed to run crash hook command for server")
+			s.PublishConsoleOutputFromDaemon("Crash hook command failed: " + err.Error())
+			if hook.AbortRestartOnFailure {
+				s.PublishConsoleOutputFromDaemon("Aborting automatic restart, crash hook command did not complete successfully.")
+				return nil
+			}
+		}
+	}
+
 	return errors.Wrap(s.HandlePowerAction(PowerActionStart), "failed to start server after crash detection")
 }
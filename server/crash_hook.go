@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+
+	"github.com/pelican-dev/wings/config"
+	"github.com/pelican-dev/wings/environment"
+	"github.com/pelican-dev/wings/system"
+)
+
+// runCrashHook executes the crash hook command defined by the server's egg, if
+// there is one. The command is run in a new container using the server's image
+// and mounts, since the server's own container is no longer running at this
+// point. The output of the command is logged and sent to the server console.
+func (s *Server) runCrashHook(hook *CrashHook) error {
+	timeout := time.Minute
+	if hook.Timeout > 0 {
+		timeout = time.Duration(hook.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(s.Context(), timeout)
+	defer cancel()
+
+	cli, err := environment.Docker()
+	if err != nil {
+		return err
+	}
+
+	name := s.ID() + "_crash_hook"
+	defer func() {
+		// Use the server context here since the timed context may have already expired.
+		err := cli.ContainerRemove(s.Context(), name, container.RemoveOptions{RemoveVolumes: true, Force: true})
+		if err != nil && !client.IsErrNotFound(err) {
+			s.Log().WithField("error", err).Warn("failed to remove crash hook container")
+		}
+	}()
+
+	cfg := config.Get()
+	conf := &container.Config{
+		Hostname:     s.ID(),
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          true,
+		Entrypoint:   []string{"/bin/sh", "-c"},
+		Cmd:          []string{strings.ReplaceAll(hook.Command, "\r\n", "\n")},
+		Image:        strings.TrimPrefix(s.Config().Container.Image, "~"),
+		Env:          s.GetEnvironmentVariables(),
+		WorkingDir:   "/home/container",
+		Labels: map[string]string{
+			"Service":       "Pelican",
+			"ContainerType": "server_crash_hook",
+		},
+	}
+	if cfg.System.User.Rootless.Enabled {
+		conf.User = fmt.Sprintf("%d:%d", cfg.System.User.Rootless.ContainerUID, cfg.System.User.Rootless.ContainerGID)
+	} else {
+		conf.User = strconv.Itoa(cfg.System.User.Uid) + ":" + strconv.Itoa(cfg.System.User.Gid)
+	}
+
+	var mounts []mount.Mount
+	for _, m := range s.Mounts() {
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   m.Source,
+			Target:   m.Target,
+			ReadOnly: m.ReadOnly,
+		})
+	}
+
+	build := s.Config().Build
+	hostConf := &container.HostConfig{
+		Mounts:         mounts,
+		Resources:      build.AsContainerResources(),
+		DNS:            cfg.Docker.Network.Dns,
+		LogConfig:      cfg.Docker.ContainerLogConfig(),
+		SecurityOpt:    []string{"no-new-privileges"},
+		ReadonlyRootfs: true,
+		NetworkMode:    container.NetworkMode(cfg.Docker.Network.Mode),
+		UsernsMode:     container.UsernsMode(cfg.Docker.UsernsMode),
+	}
+
+	s.Log().WithField("timeout", timeout).Info("running crash hook command for server")
+	s.PublishConsoleOutputFromDaemon("Running crash hook command...")
+
+	r, err := cli.ContainerCreate(ctx, conf, hostConf, nil, nil, name)
+	if err != nil {
+		return err
+	}
+	if err := cli.ContainerStart(ctx, r.ID, container.StartOptions{}); err != nil {
+		return err
+	}
+
+	reader, err := cli.ContainerLogs(ctx, r.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true, Follow: true})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	err = system.ScanReader(reader, func(line []byte) {
+		s.Log().WithField("output", string(line)).Info("crash hook output")
+		s.PublishConsoleOutputFromDaemon(string(line))
+	})
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		s.Log().WithField("error", err).Warn("error processing crash hook output lines")
+	}
+
+	sChan, eChan := cli.ContainerWait(ctx, r.ID, container.WaitConditionNotRunning)
+	select {
+	case err := <-eChan:
+		if errors.Is(err, context.DeadlineExceeded) {
+			return errors.Errorf("crash hook command did not complete within %s", timeout)
+		}
+		return err
+	case res := <-sChan:
+		if res.StatusCode != 0 {
+			return errors.Errorf("crash hook command exited with code %d", res.StatusCode)
+		}
+	}
+
+	s.PublishConsoleOutputFromDaemon("Crash hook command completed.")
+	return nil
+}
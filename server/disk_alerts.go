@@ -0,0 +1,70 @@
+package server
+
+import (
+	"sort"
+	"sync"
+)
+
+// DiskUsageAlert is the data sent along with a DiskUsageAlertEvent when a server
+// crosses one of the configured disk usage thresholds.
+type DiskUsageAlert struct {
+	Threshold int   `json:"threshold"`
+	Usage     int64 `json:"usage"`
+	Limit     int64 `json:"limit"`
+}
+
+// diskUsageAlerter tracks the highest disk usage threshold that a server has
+// crossed so that an alert is only sent once each time a threshold is crossed,
+// rather than every time the usage is checked.
+type diskUsageAlerter struct {
+	mu         sync.Mutex
+	thresholds []int
+	crossed    int
+}
+
+func newDiskUsageAlerter(thresholds []int) *diskUsageAlerter {
+	t := make([]int, 0, len(thresholds))
+	for _, v := range thresholds {
+		if v > 0 {
+			t = append(t, v)
+		}
+	}
+	sort.Ints(t)
+	return &diskUsageAlerter{thresholds: t}
+}
+
+// Check returns the threshold that was crossed if the given usage has moved past
+// a threshold that had not already been alerted for. If the usage has dropped
+// below a previously crossed threshold it is reset, so that it will be alerted
+// again the next time it is crossed.
+func (a *diskUsageAlerter) Check(usage, limit int64) (int, bool) {
+	if limit <= 0 {
+		return 0, false
+	}
+
+	var level int
+	for _, t := range a.thresholds {
+		if usage*100 >= limit*int64(t) {
+			level = t
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if level <= a.crossed {
+		a.crossed = level
+		return 0, false
+	}
+	a.crossed = level
+	return level, true
+}
+
+// checkDiskUsageAlerts publishes a disk usage alert for the server if its cached
+// disk usage has crossed one of the configured thresholds of its disk limit.
+func (s *Server) checkDiskUsageAlerts(a *diskUsageAlerter) {
+	usage, limit := s.Filesystem().CachedUsage(), s.Filesystem().MaxDisk()
+	if t, ok := a.Check(usage, limit); ok {
+		s.Log().WithField("threshold", t).WithField("usage", usage).Debug("server has crossed disk usage alert threshold")
+		s.Events().Publish(DiskUsageAlertEvent, DiskUsageAlert{Threshold: t, Usage: usage, Limit: limit})
+	}
+}
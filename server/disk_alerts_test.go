@@ -0,0 +1,49 @@
+package server
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestDiskUsageAlerter(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("diskUsageAlerter#Check", func() {
+		g.It("alerts once for each threshold that is crossed", func() {
+			a := newDiskUsageAlerter([]int{90, 80})
+
+			_, ok := a.Check(50, 100)
+			g.Assert(ok).IsFalse()
+
+			v, ok := a.Check(85, 100)
+			g.Assert(ok).IsTrue()
+			g.Assert(v).Equal(80)
+
+			_, ok = a.Check(86, 100)
+			g.Assert(ok).IsFalse()
+
+			v, ok = a.Check(95, 100)
+			g.Assert(ok).IsTrue()
+			g.Assert(v).Equal(90)
+		})
+
+		g.It("alerts again after the usage drops below a threshold", func() {
+			a := newDiskUsageAlerter([]int{80})
+
+			_, ok := a.Check(80, 100)
+			g.Assert(ok).IsTrue()
+			_, ok = a.Check(70, 100)
+			g.Assert(ok).IsFalse()
+			_, ok = a.Check(81, 100)
+			g.Assert(ok).IsTrue()
+		})
+
+		g.It("does not alert for servers without a disk limit", func() {
+			a := newDiskUsageAlerter([]int{80})
+
+			_, ok := a.Check(1000, 0)
+			g.Assert(ok).IsFalse()
+		})
+	})
+}
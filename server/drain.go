@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/apex/log"
+
+	"github.com/pelican-dev/wings/environment"
+)
+
+// draining tracks if the node is currently being drained for maintenance. While
+// draining, servers cannot be started and new websocket connections will not be
+// accepted.
+var draining atomic.Bool
+
+// IsDraining returns true if the node is currently being drained.
+func IsDraining() bool {
+	return draining.Load()
+}
+
+// DrainStatus describes the current state of a node drain.
+type DrainStatus struct {
+	Draining bool `json:"draining"`
+	// RunningServers is the number of servers with a running process.
+	RunningServers int `json:"running_servers"`
+	// BusyServers is the number of servers that are currently installing, being
+	// transferred, or being restored from a backup.
+	BusyServers int `json:"busy_servers"`
+	// SafeToReboot is true once the node is draining and there are no running or
+	// busy servers remaining.
+	SafeToReboot bool `json:"safe_to_reboot"`
+}
+
+// Drain places the node into drain mode, preventing any servers from being
+// started. If stopServers is true, all running servers are then gracefully
+// stopped using their configured stop grace period. This function blocks until
+// all the servers have been stopped.
+func (m *Manager) Drain(stopServers bool) {
+	if !draining.Swap(true) {
+		log.Info("node is now draining, servers will not be started until drain mode is disabled")
+	}
+	if !stopServers {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, s := range m.All() {
+		if s.Environment.State() == environment.ProcessOfflineState {
+			continue
+		}
+		wg.Add(1)
+		go func(s *Server) {
+			defer wg.Done()
+			s.Log().Info("stopping server for node drain")
+			if err := s.HandlePowerAction(PowerActionStop, 30); err != nil {
+				s.Log().WithField("error", err).Warn("failed to stop server while draining node")
+			}
+		}(s)
+	}
+	wg.Wait()
+}
+
+// Undrain removes the node from drain mode, allowing servers to be started
+// again. Servers stopped by the drain are not automatically started.
+func (m *Manager) Undrain() {
+	if draining.Swap(false) {
+		log.Info("node is no longer draining")
+	}
+}
+
+// DrainStatus returns the current drain status for the node.
+func (m *Manager) DrainStatus(ctx context.Context) DrainStatus {
+	st := DrainStatus{Draining: IsDraining()}
+	for _, s := range m.All() {
+		if s.IsInstalling() || s.IsTransferring() || s.IsRestoring() {
+			st.BusyServers++
+		}
+		if running, err := s.Environment.IsRunning(ctx); err != nil || running {
+			st.RunningServers++
+		}
+	}
+	st.SafeToReboot = st.Draining && st.RunningServers == 0 && st.BusyServers == 0
+	return st
+}
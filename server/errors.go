@@ -10,6 +10,9 @@ var (
 	ErrServerIsInstalling   = errors.New("server is currently installing")
 	ErrServerIsTransferring = errors.New("server is currently being transferred")
 	ErrServerIsRestoring    = errors.New("server is currently being restored")
+	ErrNodeDraining         = errors.New("node is currently draining for maintenance")
+	ErrServerDataMissing    = errors.New("server data directory is missing")
+	ErrInstallCanceled      = errors.New("server installation was canceled")
 )
 
 type crashTooFrequent struct{}
@@ -11,16 +11,38 @@ const (
 	InstallOutputEvent          = "install output"
 	InstallStartedEvent         = "install started"
 	InstallCompletedEvent       = "install completed"
+	ImagePullProgressEvent      = "image pull progress"
 	ConsoleOutputEvent          = "console output"
 	StatusEvent                 = "status"
 	StatsEvent                  = "stats"
 	BackupRestoreCompletedEvent = "backup restore completed"
 	BackupCompletedEvent        = "backup completed"
+	CloneCompletedEvent         = "clone completed"
+	DiskUsageAlertEvent         = "disk usage alert"
+	PidsLimitEvent              = "pids limit"
+	ConfigurationAppliedEvent   = "configuration applied"
 	TransferLogsEvent           = "transfer logs"
 	TransferStatusEvent         = "transfer status"
 	DeletedEvent                = "deleted"
 )
 
+// Defines the lifecycle events for servers that are published to the node event
+// bus by the manager, and by the servers that have been added to it.
+const (
+	ServerCreatedEvent     = "server created"
+	ServerDeletedEvent     = "server deleted"
+	ServerInstalledEvent   = "server installed"
+	ServerTransferredEvent = "server transferred"
+)
+
+// NodeEvent is the data sent along with a server lifecycle event on the node
+// event bus.
+type NodeEvent struct {
+	Server string `json:"server"`
+	// The status of the transfer for a ServerTransferredEvent.
+	Status string `json:"status,omitempty"`
+}
+
 // Events returns the server's emitter instance.
 func (s *Server) Events() *events.Bus {
 	s.emitterLock.Lock()
@@ -33,6 +55,32 @@ func (s *Server) Events() *events.Bus {
 	return s.emitter
 }
 
+// setNodeEvents sets the node event bus that lifecycle events for the server are
+// published to.
+func (s *Server) setNodeEvents(bus *events.Bus) {
+	s.emitterLock.Lock()
+	defer s.emitterLock.Unlock()
+	s.nodeEmitter = bus
+}
+
+// publishNodeEvent publishes a lifecycle event for the server to the node event
+// bus. Nothing is published if the server has not been added to a manager.
+func (s *Server) publishNodeEvent(topic string, data NodeEvent) {
+	s.emitterLock.Lock()
+	bus := s.nodeEmitter
+	s.emitterLock.Unlock()
+	if bus != nil {
+		bus.Publish(topic, data)
+	}
+}
+
+// PublishTransferStatus publishes the status of a transfer for the server to
+// any connected websockets, and to the node event bus.
+func (s *Server) PublishTransferStatus(status string) {
+	s.Events().Publish(TransferStatusEvent, status)
+	s.publishNodeEvent(ServerTransferredEvent, NodeEvent{Server: s.ID(), Status: status})
+}
+
 // Sink returns the instantiated and named sink for a server. If the sink has
 // not been configured yet this function will cause a panic condition.
 func (s *Server) Sink(name system.SinkName) *system.SinkPool {
@@ -0,0 +1,119 @@
+package filesystem
+
+import (
+	"context"
+	"io"
+
+	"emperror.dev/errors"
+
+	"github.com/pelican-dev/wings/config"
+	"github.com/pelican-dev/wings/internal/progress"
+	"github.com/pelican-dev/wings/internal/ufs"
+)
+
+// CopyFrom copies the entire contents of another server's data directory into
+// this filesystem. Files matching the denylist of the source filesystem are not
+// copied, and neither are symlinks or special files such as sockets. Any files
+// that already exist in this filesystem with the same name are overwritten.
+//
+// The progress tracker, if provided, has its total set to the disk usage of the
+// source filesystem and is updated as each file is copied.
+func (fs *Filesystem) CopyFrom(ctx context.Context, src *Filesystem, p *progress.Progress) error {
+	size, err := src.DiskUsage(false)
+	if err != nil {
+		return errors.WrapIf(err, "server/filesystem: copy: failed to determine size of source")
+	}
+	if err := fs.HasSpaceFor(size); err != nil {
+		return err
+	}
+	if p != nil {
+		p.SetTotal(uint64(size))
+	}
+
+	dirfd, name, closeFd, err := src.unixFS.SafePath("/")
+	defer closeFd()
+	if err != nil {
+		return err
+	}
+
+	return src.unixFS.WalkDirat(dirfd, name, func(dirfd int, name, relative string, d ufs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if relative == "." {
+			return nil
+		}
+
+		if err := src.IsIgnored(relative); err != nil {
+			if d.IsDir() {
+				return ufs.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			if err := fs.unixFS.MkdirAll(relative, 0o755); err != nil {
+				return errors.WrapIff(err, "server/filesystem: copy: failed to create directory '%s'", relative)
+			}
+			if fs.isTest {
+				return nil
+			}
+			return fs.unixFS.Lchown(relative, config.Get().System.User.Uid, config.Get().System.User.Gid)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return fs.copyFileFrom(src, dirfd, name, relative, p)
+	})
+}
+
+// Copies a single regular file from the source filesystem into this filesystem
+// at the same relative path.
+func (fs *Filesystem) copyFileFrom(src *Filesystem, dirfd int, name, relative string, p *progress.Progress) error {
+	f, err := src.unixFS.OpenFileat(dirfd, name, ufs.O_RDONLY, 0)
+	if err != nil {
+		if errors.Is(err, ufs.ErrNotExist) {
+			return nil
+		}
+		return errors.WrapIff(err, "server/filesystem: copy: failed to open '%s'", relative)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Any existing file is replaced, so only the difference in size is added to the
+	// disk usage of this filesystem.
+	var existing int64
+	if st, err := fs.unixFS.Lstat(relative); err == nil && st.Mode().IsRegular() {
+		existing = st.Size()
+	}
+
+	dst, err := fs.unixFS.Touch(relative, ufs.O_WRONLY|ufs.O_CREATE|ufs.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return errors.WrapIff(err, "server/filesystem: copy: failed to create '%s'", relative)
+	}
+	defer dst.Close()
+
+	var r io.Reader = io.LimitReader(f, info.Size())
+	if p != nil {
+		r = io.TeeReader(r, p)
+	}
+	n, err := io.Copy(dst, r)
+	fs.unixFS.Add(n - existing)
+	if err != nil {
+		return errors.WrapIff(err, "server/filesystem: copy: failed to copy '%s'", relative)
+	}
+
+	if !fs.isTest {
+		if err := fs.unixFS.Lchown(relative, config.Get().System.User.Uid, config.Get().System.User.Gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -14,7 +14,9 @@ import (
 	"emperror.dev/errors"
 	"github.com/klauspost/compress/zip"
 	"github.com/mholt/archives"
+	"golang.org/x/sync/errgroup"
 
+	"github.com/pelican-dev/wings/config"
 	"github.com/pelican-dev/wings/internal/ufs"
 	"github.com/pelican-dev/wings/server/filesystem/archiverext"
 )
@@ -89,6 +91,10 @@ func (fs *Filesystem) archiverFileSystem(ctx context.Context, p string) (iofs.FS
 	}
 
 	if format != nil {
+		if err := formatAllowed(format); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
 		switch ff := format.(type) {
 		case archives.Zip:
 			// zip.Reader is more performant than ArchiveFS, because zip.Reader caches content information
@@ -108,13 +114,21 @@ func (fs *Filesystem) archiverFileSystem(ctx context.Context, p string) (iofs.FS
 
 // SpaceAvailableForDecompression looks through a given archive and determines
 // if decompressing it would put the server over its allocated disk space limit.
+// If a maximum decompression ratio is configured this will also ensure that the
+// uncompressed size of the archive contents does not exceed that ratio.
 func (fs *Filesystem) SpaceAvailableForDecompression(ctx context.Context, dir string, file string) error {
+	ratio := config.Get().System.MaxDecompressionRatio
 	// Don't waste time trying to determine this if we know the server will have the space for
-	// it since there is no limit.
-	if fs.MaxDisk() <= 0 {
+	// it since there is no limit, and there is no ratio to enforce.
+	if fs.MaxDisk() <= 0 && ratio <= 0 {
 		return nil
 	}
 
+	st, err := fs.unixFS.Stat(filepath.Join(dir, file))
+	if err != nil {
+		return err
+	}
+
 	fsys, err := fs.archiverFileSystem(ctx, filepath.Join(dir, file))
 	if err != nil {
 		if errors.Is(err, archives.NoMatch) {
@@ -138,7 +152,11 @@ func (fs *Filesystem) SpaceAvailableForDecompression(ctx context.Context, dir st
 			if err != nil {
 				return err
 			}
-			if !fs.unixFS.CanFit(size.Add(info.Size())) {
+			total := size.Add(info.Size())
+			if ratio > 0 && st.Size() > 0 && total > st.Size()*ratio {
+				return newFilesystemError(ErrCodeArchiveRatio, nil)
+			}
+			if fs.MaxDisk() > 0 && !fs.unixFS.CanFit(total) {
 				return newFilesystemError(ErrCodeDiskSpace, nil)
 			}
 			return nil
@@ -146,15 +164,34 @@ func (fs *Filesystem) SpaceAvailableForDecompression(ctx context.Context, dir st
 	})
 }
 
+// formatAllowed returns an error if the archive format is not in the list of
+// formats the node allows servers to decompress.
+func formatAllowed(format archives.Format) error {
+	allowed := config.Get().System.AllowedArchiveFormats
+	if len(allowed) == 0 {
+		return nil
+	}
+	name := strings.TrimPrefix(format.Extension(), ".")
+	for _, v := range allowed {
+		if strings.EqualFold(strings.TrimPrefix(v, "."), name) {
+			return nil
+		}
+	}
+	return errors.WithStackDepth(&Error{code: ErrCodeArchiveFormat, resolved: name}, 1)
+}
+
 // DecompressFile will decompress a file in a given directory by using the
 // archiver tool to infer the file type and go from there. This will walk over
 // all the files within the given archive and ensure that there is not a
 // zip-slip attack being attempted by validating that the final path is within
 // the server data directory.
-func (fs *Filesystem) DecompressFile(ctx context.Context, dir string, file string) error {
+//
+// Any files in the archive that match the server's denylist are not extracted,
+// and their paths are returned to the caller.
+func (fs *Filesystem) DecompressFile(ctx context.Context, dir string, file string) ([]string, error) {
 	f, err := fs.unixFS.Open(filepath.Join(dir, file))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer f.Close()
 
@@ -162,9 +199,26 @@ func (fs *Filesystem) DecompressFile(ctx context.Context, dir string, file strin
 	format, input, err := archives.Identify(ctx, filepath.Base(file), f)
 	if err != nil {
 		if errors.Is(err, archives.NoMatch) {
-			return newFilesystemError(ErrCodeUnknownArchive, err)
+			return nil, newFilesystemError(ErrCodeUnknownArchive, err)
 		}
-		return err
+		return nil, err
+	}
+	if err := formatAllowed(format); err != nil {
+		return nil, err
+	}
+
+	// Zip archives can have their files read concurrently, so extract them using
+	// multiple workers if configured to do so.
+	if _, ok := format.(archives.Zip); ok && config.Get().System.DecompressionConcurrency > 1 {
+		st, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		zr, err := zip.NewReader(f, st.Size())
+		if err != nil {
+			return nil, err
+		}
+		return fs.extractZip(ctx, dir, file, zr, config.Get().System.DecompressionConcurrency)
 	}
 
 	return fs.extractStream(ctx, extractStreamOptions{
@@ -175,14 +229,16 @@ func (fs *Filesystem) DecompressFile(ctx context.Context, dir string, file strin
 	})
 }
 
-// ExtractStreamUnsafe .
-func (fs *Filesystem) ExtractStreamUnsafe(ctx context.Context, dir string, r io.Reader) error {
+// ExtractStreamUnsafe extracts the archive from the reader into the given
+// directory. The paths of any files that were skipped because they match the
+// server's denylist are returned.
+func (fs *Filesystem) ExtractStreamUnsafe(ctx context.Context, dir string, r io.Reader) ([]string, error) {
 	format, input, err := archives.Identify(ctx, "archive.tar.gz", r)
 	if err != nil {
 		if errors.Is(err, archives.NoMatch) {
-			return newFilesystemError(ErrCodeUnknownArchive, err)
+			return nil, newFilesystemError(ErrCodeUnknownArchive, err)
 		}
-		return err
+		return nil, err
 	}
 	return fs.extractStream(ctx, extractStreamOptions{
 		Directory: dir,
@@ -202,7 +258,9 @@ type extractStreamOptions struct {
 	Reader io.Reader
 }
 
-func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptions) error {
+// Extracts the archive described by the options, returning the paths of any
+// files that were skipped because they match the server's denylist.
+func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptions) ([]string, error) {
 	// See if it's a compressed archive, such as TAR or a ZIP
 	ex, ok := opts.Format.(archives.Extractor)
 	if !ok {
@@ -210,7 +268,7 @@ func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptio
 		// .log.gz, .sql.gz, and so on
 		de, ok := opts.Format.(archives.Decompressor)
 		if !ok {
-			return nil
+			return nil, nil
 		}
 
 		// Strip the compression suffix
@@ -218,19 +276,19 @@ func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptio
 
 		// Make sure it's not ignored
 		if err := fs.IsIgnored(p); err != nil {
-			return nil
+			return []string{p}, nil
 		}
 
 		reader, err := de.OpenReader(opts.Reader)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		defer reader.Close()
 
 		// Open the file for creation/writing
 		f, err := fs.unixFS.OpenFile(p, ufs.O_WRONLY|ufs.O_CREATE, 0o644)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		defer f.Close()
 
@@ -242,12 +300,12 @@ func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptio
 
 				// Check quota before writing the chunk
 				if quotaErr := fs.HasSpaceFor(int64(n)); quotaErr != nil {
-					return quotaErr
+					return nil, quotaErr
 				}
 
 				// Write the chunk
 				if _, writeErr := f.Write(buf[:n]); writeErr != nil {
-					return writeErr
+					return nil, writeErr
 				}
 
 				// Add to quota
@@ -261,21 +319,23 @@ func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptio
 				}
 
 				// Return any other
-				return err
+				return nil, err
 			}
 		}
 
-		return nil
+		return nil, nil
 	}
 
 	// Decompress and extract archive
-	return ex.Extract(ctx, opts.Reader, func(ctx context.Context, f archives.FileInfo) error {
+	var skipped []string
+	err := ex.Extract(ctx, opts.Reader, func(ctx context.Context, f archives.FileInfo) error {
 		if f.IsDir() {
 			return nil
 		}
 		p := filepath.Join(opts.Directory, f.NameInArchive)
 		// If it is ignored, just don't do anything with the file and skip over it.
 		if err := fs.IsIgnored(p); err != nil {
+			skipped = append(skipped, p)
 			return nil
 		}
 		r, err := f.Open()
@@ -292,4 +352,91 @@ func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptio
 		}
 		return nil
 	})
+	return skipped, err
+}
+
+// Extracts the files in the zip archive to the given directory, using up to the
+// given number of workers to write files at the same time. The paths of any files
+// that were skipped because they match the server's denylist are returned.
+func (fs *Filesystem) extractZip(ctx context.Context, dir string, file string, zr *zip.Reader, workers int) ([]string, error) {
+	// Space can only be reserved for each file once the current usage is known,
+	// otherwise nothing would stop the workers from exceeding the disk limit.
+	if fs.unixFS.Usage() == -1 {
+		if _, err := fs.updateCachedDiskUsage(); err != nil {
+			return nil, errors.Wrap(err, "server/filesystem: failed to determine disk usage")
+		}
+	}
+
+	var skipped []string
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+	for _, zf := range zr.File {
+		if gctx.Err() != nil {
+			break
+		}
+		info := zf.FileInfo()
+		if info.IsDir() {
+			continue
+		}
+		p := filepath.Join(dir, zf.Name)
+		// If it is ignored, just don't do anything with the file and skip over it.
+		if err := fs.IsIgnored(p); err != nil {
+			skipped = append(skipped, p)
+			continue
+		}
+		g.Go(func() error {
+			r, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			if err := fs.writeReserved(p, r, info.Size(), info.Mode()); err != nil {
+				return wrapError(err, file)
+			}
+			// Update the file modification time to the one set in the archive.
+			if err := fs.Chtimes(p, info.ModTime(), info.ModTime()); err != nil {
+				return wrapError(err, file)
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return skipped, err
+	}
+	return skipped, ctx.Err()
+}
+
+// Writes the file to the disk in the same way as Write, however the space for
+// the file is reserved before it is written. This ensures that the disk limit
+// is not exceeded when several files are being written at the same time.
+func (fs *Filesystem) writeReserved(p string, r io.Reader, newSize int64, mode ufs.FileMode) error {
+	var currentSize int64
+	st, err := fs.unixFS.Stat(p)
+	if err != nil && !errors.Is(err, ufs.ErrNotExist) {
+		return errors.Wrap(err, "server/filesystem: writefile: failed to stat file")
+	} else if err == nil {
+		if st.IsDir() {
+			return errors.WithStack(&Error{code: ErrCodeIsDirectory, resolved: ""})
+		}
+		currentSize = st.Size()
+	}
+
+	if !fs.unixFS.Reserve(newSize - currentSize) {
+		return newFilesystemError(ErrCodeDiskSpace, nil)
+	}
+
+	file, err := fs.unixFS.Touch(p, ufs.O_RDWR|ufs.O_TRUNC, mode)
+	if err != nil {
+		fs.unixFS.Add(currentSize - newSize)
+		return err
+	}
+	defer file.Close()
+
+	n, err := io.Copy(file, io.LimitReader(r, newSize))
+	// Release any of the reserved space that was not written to the file.
+	fs.unixFS.Add(n - newSize)
+	if err != nil {
+		return err
+	}
+	return fs.chownFile(p)
 }
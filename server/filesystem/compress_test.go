@@ -1,11 +1,17 @@
 package filesystem
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
 	"os"
 	"testing"
 
+	"emperror.dev/errors"
 	. "github.com/franela/goblin"
+	ignore "github.com/sabhiram/go-gitignore"
+
+	"github.com/pelican-dev/wings/config"
 )
 
 // Given an archive named test.{ext}, with the following file structure:
@@ -30,8 +36,9 @@ func TestFilesystem_DecompressFile(t *testing.T) {
 				g.Assert(err).IsNil()
 
 				// decompress
-				err = fs.DecompressFile(context.Background(), "/", "test."+ext)
+				skipped, err := fs.DecompressFile(context.Background(), "/", "test."+ext)
 				g.Assert(err).IsNil()
+				g.Assert(len(skipped)).Equal(0)
 
 				// make sure everything is where it is supposed to be
 				_, err = rfs.StatServerFile("test/outside.txt")
@@ -47,6 +54,136 @@ func TestFilesystem_DecompressFile(t *testing.T) {
 			})
 		}
 
+		g.It("can decompress a zip using multiple workers", func() {
+			config.Update(func(c *config.Configuration) {
+				c.System.DecompressionConcurrency = 4
+			})
+			defer config.Update(func(c *config.Configuration) {
+				c.System.DecompressionConcurrency = 0
+			})
+
+			c, err := os.ReadFile("./testdata/test.zip")
+			g.Assert(err).IsNil()
+			err = rfs.CreateServerFile("./test.zip", c)
+			g.Assert(err).IsNil()
+
+			skipped, err := fs.DecompressFile(context.Background(), "/", "test.zip")
+			g.Assert(err).IsNil()
+			g.Assert(len(skipped)).Equal(0)
+
+			_, err = rfs.StatServerFile("test/outside.txt")
+			g.Assert(err).IsNil()
+			_, err = rfs.StatServerFile("test/inside/finside.txt")
+			g.Assert(err).IsNil()
+		})
+
+		g.It("tracks disk usage with multiple workers when usage is unknown", func() {
+			config.Update(func(c *config.Configuration) {
+				c.System.DecompressionConcurrency = 4
+			})
+			defer config.Update(func(c *config.Configuration) {
+				c.System.DecompressionConcurrency = 0
+			})
+
+			c, err := os.ReadFile("./testdata/test.zip")
+			g.Assert(err).IsNil()
+			err = rfs.CreateServerFile("./test.zip", c)
+			g.Assert(err).IsNil()
+
+			fs.unixFS.SetUsage(-1)
+			_, err = fs.DecompressFile(context.Background(), "/", "test.zip")
+			g.Assert(err).IsNil()
+
+			size, err := fs.DirectorySize("/")
+			g.Assert(err).IsNil()
+			g.Assert(fs.CachedUsage()).Equal(size)
+		})
+
+		g.It("reports files skipped by the denylist", func() {
+			c, err := os.ReadFile("./testdata/test.zip")
+			g.Assert(err).IsNil()
+			err = rfs.CreateServerFile("./test.zip", c)
+			g.Assert(err).IsNil()
+
+			fs.denylist = ignore.CompileIgnoreLines("outside.txt")
+			defer func() {
+				fs.denylist = ignore.CompileIgnoreLines()
+			}()
+
+			skipped, err := fs.DecompressFile(context.Background(), "/", "test.zip")
+			g.Assert(err).IsNil()
+			g.Assert(skipped).Equal([]string{"/test/outside.txt"})
+
+			_, err = rfs.StatServerFile("test/outside.txt")
+			g.Assert(errors.Is(err, os.ErrNotExist)).IsTrue()
+			_, err = rfs.StatServerFile("test/inside/finside.txt")
+			g.Assert(err).IsNil()
+		})
+
+		g.It("refuses formats that are not allowed", func() {
+			config.Update(func(c *config.Configuration) {
+				c.System.AllowedArchiveFormats = []string{"zip", "tar.gz"}
+			})
+			defer config.Update(func(c *config.Configuration) {
+				c.System.AllowedArchiveFormats = nil
+			})
+
+			c, err := os.ReadFile("./testdata/test.rar")
+			g.Assert(err).IsNil()
+			err = rfs.CreateServerFile("./test.rar", c)
+			g.Assert(err).IsNil()
+
+			_, err = fs.DecompressFile(context.Background(), "/", "test.rar")
+			g.Assert(IsErrorCode(err, ErrCodeArchiveFormat)).IsTrue()
+
+			_, err = rfs.StatServerFile("test/outside.txt")
+			g.Assert(errors.Is(err, os.ErrNotExist)).IsTrue()
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+	})
+}
+
+func TestFilesystem_SpaceAvailableForDecompression(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("SpaceAvailableForDecompression", func() {
+		g.BeforeEach(func() {
+			// A single megabyte of zeros compresses down to almost nothing, giving
+			// us an archive with an extremely high compression ratio.
+			var b bytes.Buffer
+			w := zip.NewWriter(&b)
+			f, err := w.Create("zeros.bin")
+			g.Assert(err).IsNil()
+			_, err = f.Write(make([]byte, 1024*1024))
+			g.Assert(err).IsNil()
+			g.Assert(w.Close()).IsNil()
+
+			err = rfs.CreateServerFile("bomb.zip", b.Bytes())
+			g.Assert(err).IsNil()
+		})
+
+		g.It("allows archives when no ratio is configured", func() {
+			err := fs.SpaceAvailableForDecompression(context.Background(), "/", "bomb.zip")
+			g.Assert(err).IsNil()
+		})
+
+		g.It("refuses archives exceeding the configured ratio", func() {
+			config.Update(func(c *config.Configuration) {
+				c.System.MaxDecompressionRatio = 10
+			})
+			defer config.Update(func(c *config.Configuration) {
+				c.System.MaxDecompressionRatio = 0
+			})
+
+			err := fs.SpaceAvailableForDecompression(context.Background(), "/", "bomb.zip")
+			g.Assert(err).IsNotNil()
+			g.Assert(IsErrorCode(err, ErrCodeArchiveRatio)).IsTrue()
+		})
+
 		g.AfterEach(func() {
 			_ = fs.TruncateRootDirectory()
 		})
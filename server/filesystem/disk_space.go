@@ -1,6 +1,7 @@
 package filesystem
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -158,14 +159,15 @@ func (fs *Filesystem) updateCachedDiskUsage() (int64, error) {
 
 // DirectorySize calculates the size of a directory and its descendants.
 func (fs *Filesystem) DirectorySize(root string) (int64, error) {
-	dirfd, name, closeFd, err := fs.unixFS.SafePath(root)
-	defer closeFd()
-	if err != nil {
-		return 0, err
-	}
+	return fs.DirectorySizeContext(context.Background(), root)
+}
 
+// DirectorySizeContext calculates the size of a directory and its descendants,
+// walking subdirectories in parallel. The calculation is aborted if the context
+// is canceled.
+func (fs *Filesystem) DirectorySizeContext(ctx context.Context, root string) (int64, error) {
 	var size atomic.Int64
-	err = fs.unixFS.WalkDirat(dirfd, name, func(dirfd int, name, _ string, d ufs.DirEntry, err error) error {
+	err := fs.WalkParallel(ctx, root, func(dirfd int, name, _ string, d ufs.DirEntry, err error) error {
 		if err != nil {
 			return errors.Wrap(err, "walkdirat err")
 		}
@@ -189,6 +191,77 @@ func (fs *Filesystem) DirectorySize(root string) (int64, error) {
 	return size.Load(), errors.WrapIf(err, "server/filesystem: directorysize: failed to walk directory")
 }
 
+// DirectorySummary describes the number of entries within a directory and
+// the total size of the files within it.
+type DirectorySummary struct {
+	Files       int64 `json:"files"`
+	Directories int64 `json:"directories"`
+	Size        int64 `json:"size"`
+	// Truncated is set when the directory contained more entries than the limit,
+	// or the context was canceled, before the walk was completed. The values are
+	// then only for the part of the directory that was walked.
+	Truncated bool `json:"truncated"`
+}
+
+// SummarizeDirectory counts the files and directories within a directory and
+// its descendants, along with the total size of the files. The type of each
+// entry is taken from the directory listing so only regular files need to be
+// stat'd. The walk stops once "limit" entries have been found, or the context
+// is canceled, in which case the summary is marked as truncated.
+func (fs *Filesystem) SummarizeDirectory(ctx context.Context, root string, limit int64) (DirectorySummary, error) {
+	var entries, files, dirs, size atomic.Int64
+	var truncated atomic.Bool
+	err := fs.WalkParallel(ctx, root, func(dirfd int, name, relative string, d ufs.DirEntry, err error) error {
+		if err != nil {
+			return errors.Wrap(err, "walkdirat err")
+		}
+		if relative == "." {
+			return nil
+		}
+		if limit > 0 && entries.Add(1) > limit {
+			truncated.Store(true)
+			return ufs.SkipAll
+		}
+		switch {
+		case d.IsDir():
+			dirs.Add(1)
+		case d.Type().IsRegular():
+			files.Add(1)
+			info, err := fs.unixFS.Lstatat(dirfd, name)
+			if err != nil {
+				if errors.Is(err, ufs.ErrNotExist) {
+					return nil
+				}
+				return errors.Wrap(err, "lstatat err")
+			}
+			size.Add(info.Size())
+		default:
+			files.Add(1)
+		}
+		return nil
+	})
+	if err != nil && ctx.Err() != nil {
+		truncated.Store(true)
+		err = nil
+	}
+	return DirectorySummary{
+		Files:       files.Load(),
+		Directories: dirs.Load(),
+		Size:        size.Load(),
+		Truncated:   truncated.Load(),
+	}, errors.WrapIf(err, "server/filesystem: summarizedirectory: failed to walk directory")
+}
+
+// AvailableSpace returns the amount of disk space remaining before the server
+// reaches its disk limit, based on the cached disk usage. If the server does not
+// have a disk limit -1 is returned.
+func (fs *Filesystem) AvailableSpace() int64 {
+	if fs.MaxDisk() <= 0 {
+		return -1
+	}
+	return max(fs.MaxDisk()-fs.CachedUsage(), 0)
+}
+
 func (fs *Filesystem) HasSpaceFor(size int64) error {
 	if !fs.unixFS.CanFit(size) {
 		return newFilesystemError(ErrCodeDiskSpace, nil)
@@ -200,3 +273,42 @@ func (fs *Filesystem) HasSpaceFor(size int64) error {
 func (fs *Filesystem) addDisk(i int64) int64 {
 	return fs.unixFS.Add(i)
 }
+
+// QuotaWriterAt wraps a file that is being written to and enforces the disk
+// space limit of the server as each chunk of data is written, rather than only
+// checking once the write has completed. This is used by writers such as the
+// SFTP subsystem where the final size of the file is not known ahead of time.
+type QuotaWriterAt struct {
+	ufs.File
+	fs   *Filesystem
+	mu   sync.Mutex
+	size int64
+}
+
+// NewQuotaWriterAt returns a new QuotaWriterAt for the given file. The file is
+// expected to have been truncated when opened, so the previous size of the file
+// is released from the disk usage for the server.
+func (fs *Filesystem) NewQuotaWriterAt(f ufs.File, previousSize int64) *QuotaWriterAt {
+	if previousSize > 0 {
+		fs.addDisk(-previousSize)
+	}
+	return &QuotaWriterAt{File: f, fs: fs}
+}
+
+// WriteAt writes the provided bytes to the file at the given offset. If writing
+// the data would grow the file beyond the available disk space for the server
+// an ErrCodeDiskSpace error is returned and nothing is written.
+func (w *QuotaWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	w.mu.Lock()
+	if end := off + int64(len(p)); end > w.size {
+		if err := w.fs.HasSpaceFor(end - w.size); err != nil {
+			w.mu.Unlock()
+			return 0, err
+		}
+		w.fs.addDisk(end - w.size)
+		w.size = end
+	}
+	w.mu.Unlock()
+
+	return w.File.WriteAt(p, off)
+}
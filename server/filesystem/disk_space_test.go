@@ -0,0 +1,80 @@
+package filesystem
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_QuotaWriterAt(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("QuotaWriterAt", func() {
+		g.BeforeEach(func() {
+			fs.SetDiskLimit(10)
+			fs.unixFS.SetUsage(0)
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+			fs.SetDiskLimit(0)
+		})
+
+		g.It("tracks the size of the file as it is written", func() {
+			f, err := fs.Touch("test.txt", os.O_RDWR|os.O_TRUNC)
+			g.Assert(err).IsNil()
+			defer f.Close()
+			w := fs.NewQuotaWriterAt(f, 0)
+
+			n, err := w.WriteAt([]byte("hello"), 0)
+			g.Assert(err).IsNil()
+			g.Assert(n).Equal(5)
+			g.Assert(fs.CachedUsage()).Equal(int64(5))
+
+			// Overwriting existing data does not use any more space.
+			_, err = w.WriteAt([]byte("he"), 0)
+			g.Assert(err).IsNil()
+			g.Assert(fs.CachedUsage()).Equal(int64(5))
+
+			// Writing past the end of the file counts the space up to the end
+			// of the write.
+			_, err = w.WriteAt([]byte("!"), 7)
+			g.Assert(err).IsNil()
+			g.Assert(fs.CachedUsage()).Equal(int64(8))
+		})
+
+		g.It("refuses writes that exceed the disk limit", func() {
+			f, err := fs.Touch("test.txt", os.O_RDWR|os.O_TRUNC)
+			g.Assert(err).IsNil()
+			defer f.Close()
+			w := fs.NewQuotaWriterAt(f, 0)
+
+			_, err = w.WriteAt([]byte("12345678"), 0)
+			g.Assert(err).IsNil()
+
+			n, err := w.WriteAt([]byte("abc"), 8)
+			g.Assert(IsErrorCode(err, ErrCodeDiskSpace)).IsTrue()
+			g.Assert(n).Equal(0)
+			g.Assert(fs.CachedUsage()).Equal(int64(8))
+
+			st, err := rfs.StatServerFile("test.txt")
+			g.Assert(err).IsNil()
+			g.Assert(st.Size()).Equal(int64(8))
+		})
+
+		g.It("releases the previous size of the file", func() {
+			fs.unixFS.SetUsage(8)
+			f, err := fs.Touch("test.txt", os.O_RDWR|os.O_TRUNC)
+			g.Assert(err).IsNil()
+			defer f.Close()
+			w := fs.NewQuotaWriterAt(f, 8)
+			g.Assert(fs.CachedUsage()).Equal(int64(0))
+
+			_, err = w.WriteAt([]byte("0123456789"), 0)
+			g.Assert(err).IsNil()
+			g.Assert(fs.CachedUsage()).Equal(int64(10))
+		})
+	})
+}
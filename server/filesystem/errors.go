@@ -16,6 +16,8 @@ const (
 	ErrCodeIsDirectory    ErrorCode = "E_ISDIR"
 	ErrCodeDiskSpace      ErrorCode = "E_NODISK"
 	ErrCodeUnknownArchive ErrorCode = "E_UNKNFMT"
+	ErrCodeArchiveRatio   ErrorCode = "E_ARCHRATIO"
+	ErrCodeArchiveFormat  ErrorCode = "E_ARCHFMT"
 	ErrCodePathResolution ErrorCode = "E_BADPATH"
 	ErrCodeDenylistFile   ErrorCode = "E_DENYLIST"
 	ErrCodeUnknownError   ErrorCode = "E_UNKNOWN"
@@ -58,6 +60,10 @@ func (e *Error) Error() string {
 		return "filesystem: not enough disk space"
 	case ErrCodeUnknownArchive:
 		return "filesystem: unknown archive format"
+	case ErrCodeArchiveRatio:
+		return "filesystem: archive exceeds the maximum allowed decompression ratio"
+	case ErrCodeArchiveFormat:
+		return fmt.Sprintf("filesystem: archive format [%s] is not allowed on this node", e.resolved)
 	case ErrCodeDenylistFile:
 		r := e.resolved
 		if r == "" {
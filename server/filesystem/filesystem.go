@@ -1,6 +1,7 @@
 package filesystem
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -14,8 +15,8 @@ import (
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
-	"github.com/gabriel-vasile/mimetype"
 	ignore "github.com/sabhiram/go-gitignore"
+	"golang.org/x/sys/unix"
 
 	"github.com/pelican-dev/wings/config"
 	"github.com/pelican-dev/wings/internal/ufs"
@@ -24,21 +25,39 @@ import (
 type Filesystem struct {
 	unixFS *ufs.Quota
 
+	// The symlink in the data directory that points to the root of the filesystem,
+	// if the server data directory is a symlink.
+	link string
+
 	mu                sync.RWMutex
 	lastLookupTime    *usageLookupTime
 	lookupInProgress  atomic.Bool
 	diskCheckInterval time.Duration
 	denylist          *ignore.GitIgnore
+	mimeCache         mimeCache
+	uploads           uploadSessions
 
 	isTest bool
 }
 
+// ErrSymlinkedRoot is returned when the data directory for a server is a symlink
+// and symlinked server data directories are not allowed by the configuration.
+var ErrSymlinkedRoot = errors.Sentinel("filesystem: server data directory is a symlink, which is not allowed by the configuration")
+
 // New creates a new Filesystem instance for a given server.
 func New(root string, size int64, denylist []string) (*Filesystem, error) {
 	if err := os.MkdirAll(root, 0o755); err != nil {
 		return nil, err
 	}
-	unixFS, err := ufs.NewUnixFS(root, config.UseOpenat2())
+	resolved, err := resolveRoot(root)
+	if err != nil {
+		return nil, err
+	}
+	var link string
+	if resolved != root {
+		link = root
+	}
+	unixFS, err := ufs.NewUnixFS(resolved, config.UseOpenat2())
 	if err != nil {
 		return nil, err
 	}
@@ -46,6 +65,7 @@ func New(root string, size int64, denylist []string) (*Filesystem, error) {
 
 	return &Filesystem{
 		unixFS: quota,
+		link:   link,
 
 		diskCheckInterval: time.Duration(config.Get().System.DiskCheckInterval),
 		lastLookupTime:    &usageLookupTime{},
@@ -53,6 +73,64 @@ func New(root string, size int64, denylist []string) (*Filesystem, error) {
 	}, nil
 }
 
+// resolveRoot checks if the server data directory is a symlink, and if so either
+// returns the directory it points to or an error if symlinked data directories
+// are not allowed. The symlink is only resolved here so that the server root
+// cannot be changed by modifying the symlink while the server is loaded.
+func resolveRoot(root string) (string, error) {
+	st, err := os.Lstat(root)
+	if err != nil {
+		return "", err
+	}
+	if st.Mode()&os.ModeSymlink == 0 {
+		return root, nil
+	}
+	if !config.Get().System.AllowSymlinkedServerData {
+		return "", errors.WithDetails(ErrSymlinkedRoot, "root", root)
+	}
+	resolved, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", errors.WrapIf(err, "filesystem: failed to resolve symlinked server data directory")
+	}
+	if st, err := os.Stat(resolved); err != nil {
+		return "", err
+	} else if !st.IsDir() {
+		return "", errors.Errorf("filesystem: server data directory %s does not resolve to a directory", root)
+	}
+	return resolved, nil
+}
+
+// Destroy closes the filesystem and removes all of the server's files from the
+// disk. If the server data directory is a symlink, the symlink is removed along
+// with the contents of the directory it points to, so that the data directory
+// can be created again for the same server.
+func (fs *Filesystem) Destroy() error {
+	_ = fs.unixFS.Close()
+	if err := os.RemoveAll(fs.Path()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if fs.link != "" {
+		if err := os.Remove(fs.link); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reopen closes the filesystem and opens the root directory again, keeping the
+// disk limit. This must be called if the root directory has been replaced
+// outside of the filesystem, for example when a ZFS dataset is received.
+func (fs *Filesystem) Reopen() error {
+	limit := fs.unixFS.Limit()
+	_ = fs.unixFS.Close()
+	unixFS, err := ufs.NewUnixFS(fs.Path(), config.UseOpenat2())
+	if err != nil {
+		return err
+	}
+	fs.unixFS = ufs.NewQuota(unixFS, limit)
+	return nil
+}
+
 // Path returns the root path for the Filesystem instance.
 func (fs *Filesystem) Path() string {
 	return fs.unixFS.BasePath()
@@ -77,7 +155,7 @@ func (fs *Filesystem) File(p string) (ufs.File, Stat, error) {
 	if err != nil {
 		return nil, Stat{}, err
 	}
-	st, err := statFromFile(f)
+	st, err := fs.statFromFile(p, f)
 	if err != nil {
 		_ = f.Close()
 		return nil, Stat{}, err
@@ -192,10 +270,26 @@ func (fs *Filesystem) CreateDirectory(name string, p string) error {
 	return fs.unixFS.MkdirAll(filepath.Join(p, name), 0o755)
 }
 
+// Rename renames (moves) a file or directory, returning an error if something
+// already exists at the new path.
 func (fs *Filesystem) Rename(oldpath, newpath string) error {
 	return fs.unixFS.Rename(oldpath, newpath)
 }
 
+// RenameReplace renames (moves) a file or directory, replacing any file that
+// already exists at the new path. Existing directories are never replaced.
+func (fs *Filesystem) RenameReplace(oldpath, newpath string) error {
+	var replaced int64
+	if st, err := fs.unixFS.Lstat(newpath); err == nil && st.Mode().IsRegular() {
+		replaced = st.Size()
+	}
+	if err := fs.unixFS.RenameReplace(oldpath, newpath); err != nil {
+		return err
+	}
+	fs.unixFS.Add(-replaced)
+	return nil
+}
+
 func (fs *Filesystem) Symlink(oldpath, newpath string) error {
 	return fs.unixFS.Symlink(oldpath, newpath)
 }
@@ -258,6 +352,97 @@ func (fs *Filesystem) Chown(p string) error {
 	return nil
 }
 
+// RepairPermissions recursively resets the ownership of every file and folder
+// in the server's data directory to the configured container user, and applies
+// the default permissions to them. Directories are set to 0755, and files are
+// set to 0644 unless they were already executable, in which case 0755 is used
+// so that startup scripts continue to function. Symlinks are never followed,
+// only their ownership is updated.
+//
+// The total number of entries that had their ownership or permissions changed
+// is returned. The walk is aborted if the provided context is canceled.
+func (fs *Filesystem) RepairPermissions(ctx context.Context) (int64, error) {
+	uid := config.Get().System.User.Uid
+	gid := config.Get().System.User.Gid
+
+	dirfd, name, closeFd, err := fs.unixFS.SafePath("/")
+	defer closeFd()
+	if err != nil {
+		return 0, err
+	}
+
+	var updated int64
+	err = fs.unixFS.WalkDirat(dirfd, name, func(dirfd int, name, _ string, _ ufs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		st, err := fs.unixFS.Lstatat(dirfd, name)
+		if err != nil {
+			return err
+		}
+
+		var changed bool
+		if sys, ok := st.Sys().(*unix.Stat_t); !ok || int(sys.Uid) != uid || int(sys.Gid) != gid {
+			if !fs.isTest {
+				if err := fs.unixFS.Lchownat(dirfd, name, uid, gid); err != nil {
+					return err
+				}
+			}
+			changed = true
+		}
+
+		if st.Mode().IsDir() || st.Mode().IsRegular() {
+			mode := ufs.FileMode(0o644)
+			if st.IsDir() || st.Mode().Perm()&0o111 != 0 {
+				mode = 0o755
+			}
+			if st.Mode().Perm() != mode {
+				if err := fs.unixFS.Lchmodat(dirfd, name, mode); err != nil {
+					return err
+				}
+				changed = true
+			}
+		}
+
+		if changed {
+			updated++
+		}
+		return nil
+	})
+	if err != nil {
+		return updated, errors.WrapIf(err, "server/filesystem: repair permissions: failed to walk directory")
+	}
+	return updated, nil
+}
+
+// Truncate changes the size of a file in place, rather than deleting it and
+// creating a new one. This allows a file that is currently held open by the
+// server process, such as a log file, to be cleared. If the file would grow
+// beyond the disk limit for the server an error is returned.
+func (fs *Filesystem) Truncate(p string, size int64) error {
+	st, err := fs.unixFS.Stat(p)
+	if err != nil {
+		return err
+	}
+	if st.IsDir() {
+		return errors.WithStack(&Error{code: ErrCodeIsDirectory, resolved: p})
+	}
+
+	delta := size - st.Size()
+	if !fs.unixFS.Reserve(delta) {
+		return newFilesystemError(ErrCodeDiskSpace, nil)
+	}
+	if err := fs.unixFS.Truncate(p, size); err != nil {
+		fs.unixFS.Add(-delta)
+		return err
+	}
+	return nil
+}
+
 func (fs *Filesystem) Chmod(path string, mode ufs.FileMode) error {
 	return fs.unixFS.Chmod(path, mode)
 }
@@ -429,34 +614,18 @@ func (fs *Filesystem) ListDirectory(p string) ([]Stat, error) {
 			return Stat{}, err
 		}
 
-		var d string
-		if e.Type().IsDir() {
-			d = "inode/directory"
-		} else {
-			d = "application/octet-stream"
-		}
-		var m *mimetype.MIME
-		if e.Type().IsRegular() {
+		m, err := fs.mimetype(filepath.Join(p, e.Name()), info, func() (io.ReadCloser, error) {
 			// TODO: I should probably find a better way to do this.
 			eO := e.(interface {
 				Open() (ufs.File, error)
 			})
-			f, err := eO.Open()
-			if err != nil {
-				return Stat{}, err
-			}
-			m, err = mimetype.DetectReader(f)
-			if err != nil {
-				log.Error(err.Error())
-			}
-			_ = f.Close()
-		}
-
-		st := Stat{FileInfo: info, Mimetype: d}
-		if m != nil {
-			st.Mimetype = m.String()
+			return eO.Open()
+		})
+		if err != nil {
+			log.Error(err.Error())
+			m = "application/octet-stream"
 		}
-		return st, nil
+		return Stat{FileInfo: info, Mimetype: m}, nil
 	})
 	if err != nil {
 		return nil, err
@@ -3,6 +3,7 @@ package filesystem
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"math/rand"
 	"os"
@@ -608,3 +609,184 @@ func TestFilesystem_Delete(t *testing.T) {
 		})
 	})
 }
+
+func TestFilesystem_Truncate(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("Truncate", func() {
+		g.BeforeEach(func() {
+			if err := rfs.CreateServerFileFromString("latest.log", "test content"); err != nil {
+				panic(err)
+			}
+
+			fs.unixFS.SetUsage(int64(utf8.RuneCountInString("test content")))
+		})
+
+		g.It("truncates the file and updates the disk usage", func() {
+			err := fs.Truncate("latest.log", 0)
+			g.Assert(err).IsNil()
+
+			st, err := rfs.StatServerFile("latest.log")
+			g.Assert(err).IsNil()
+			g.Assert(st.Size()).Equal(int64(0))
+			g.Assert(fs.CachedUsage()).Equal(int64(0))
+		})
+
+		g.It("does not allow the file to grow beyond the disk limit", func() {
+			fs.SetDiskLimit(16)
+			defer fs.SetDiskLimit(0)
+
+			err := fs.Truncate("latest.log", 1024)
+			g.Assert(err).IsNotNil()
+			g.Assert(IsErrorCode(err, ErrCodeDiskSpace)).IsTrue()
+
+			st, err := rfs.StatServerFile("latest.log")
+			g.Assert(err).IsNil()
+			g.Assert(st.Size()).Equal(int64(12))
+		})
+
+		g.It("does not truncate directories", func() {
+			err := os.Mkdir(filepath.Join(rfs.root, "/server/logs"), 0o755)
+			g.Assert(err).IsNil()
+
+			err = fs.Truncate("logs", 0)
+			g.Assert(err).IsNotNil()
+			g.Assert(IsErrorCode(err, ErrCodeIsDirectory)).IsTrue()
+		})
+
+		g.It("does not truncate files outside the root directory", func() {
+			err := rfs.CreateServerFileFromString("/../ext-source.txt", "external content")
+			g.Assert(err).IsNil()
+
+			err = fs.Truncate("../ext-source.txt", 0)
+			g.Assert(err).IsNotNil()
+			g.Assert(errors.Is(err, ufs.ErrBadPathResolution)).IsTrue("err is not ErrBadPathResolution")
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+	})
+}
+
+func TestFilesystem_SummarizeDirectory(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("SummarizeDirectory", func() {
+		g.BeforeEach(func() {
+			if err := os.MkdirAll(filepath.Join(rfs.root, "/server/nested/deeper"), 0o755); err != nil {
+				panic(err)
+			}
+			for _, f := range []string{"one.txt", "nested/two.txt", "nested/deeper/three.txt"} {
+				if err := rfs.CreateServerFileFromString(f, "content"); err != nil {
+					panic(err)
+				}
+			}
+		})
+
+		g.It("counts files and directories and their total size", func() {
+			s, err := fs.SummarizeDirectory(context.Background(), "/", 0)
+			g.Assert(err).IsNil()
+			g.Assert(s.Files).Equal(int64(3))
+			g.Assert(s.Directories).Equal(int64(2))
+			g.Assert(s.Size).Equal(int64(21))
+			g.Assert(s.Truncated).IsFalse()
+		})
+
+		g.It("only counts entries within the given directory", func() {
+			s, err := fs.SummarizeDirectory(context.Background(), "nested", 0)
+			g.Assert(err).IsNil()
+			g.Assert(s.Files).Equal(int64(2))
+			g.Assert(s.Directories).Equal(int64(1))
+		})
+
+		g.It("marks the summary as truncated when the limit is reached", func() {
+			s, err := fs.SummarizeDirectory(context.Background(), "/", 2)
+			g.Assert(err).IsNil()
+			g.Assert(s.Files + s.Directories).Equal(int64(2))
+			g.Assert(s.Truncated).IsTrue()
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+	})
+}
+
+func TestFilesystem_SymlinkedRoot(t *testing.T) {
+	g := Goblin(t)
+	_, rfs := NewFs()
+
+	g.Describe("New", func() {
+		var target, link string
+
+		g.BeforeEach(func() {
+			target = filepath.Join(rfs.root, "target")
+			link = filepath.Join(rfs.root, "link")
+			if err := os.Mkdir(target, 0o755); err != nil {
+				panic(err)
+			}
+			if err := os.Symlink(target, link); err != nil {
+				panic(err)
+			}
+		})
+
+		g.AfterEach(func() {
+			_ = os.RemoveAll(target)
+			_ = os.Remove(link)
+			config.Update(func(c *config.Configuration) {
+				c.System.AllowSymlinkedServerData = false
+			})
+		})
+
+		g.It("uses the target of a symlinked data directory when allowed", func() {
+			config.Update(func(c *config.Configuration) {
+				c.System.AllowSymlinkedServerData = true
+			})
+
+			fs, err := New(link, 0, []string{})
+			g.Assert(err).IsNil()
+			g.Assert(fs.Path()).Equal(target)
+			_ = fs.unixFS.Close()
+		})
+
+		g.It("does not allow a symlinked data directory when disallowed", func() {
+			_, err := New(link, 0, []string{})
+			g.Assert(err).IsNotNil()
+			g.Assert(errors.Is(err, ErrSymlinkedRoot)).IsTrue("err is not ErrSymlinkedRoot")
+		})
+
+		g.It("does not allow a dangling symlinked data directory", func() {
+			config.Update(func(c *config.Configuration) {
+				c.System.AllowSymlinkedServerData = true
+			})
+			_ = os.RemoveAll(target)
+
+			_, err := New(link, 0, []string{})
+			g.Assert(err).IsNotNil()
+		})
+
+		g.It("removes the symlink when the filesystem is destroyed", func() {
+			config.Update(func(c *config.Configuration) {
+				c.System.AllowSymlinkedServerData = true
+			})
+
+			fs, err := New(link, 0, []string{})
+			g.Assert(err).IsNil()
+			g.Assert(fs.Destroy()).IsNil()
+
+			_, err = os.Lstat(link)
+			g.Assert(os.IsNotExist(err)).IsTrue()
+			_, err = os.Stat(target)
+			g.Assert(os.IsNotExist(err)).IsTrue()
+
+			// The data directory can be created again for the same server.
+			fs, err = New(link, 0, []string{})
+			g.Assert(err).IsNil()
+			g.Assert(fs.Path()).Equal(link)
+			_ = fs.unixFS.Close()
+		})
+	})
+}
@@ -0,0 +1,41 @@
+package filesystem
+
+import (
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// The magic number used by ZFS, which is not defined by the unix package since
+// the filesystem is not part of the Linux kernel.
+const zfsSuperMagic = 0x2fc12fc1
+
+// The names of the filesystems that are commonly used for server data, keyed
+// by the magic number reported by statfs.
+var filesystemTypes = map[int64]string{
+	unix.EXT4_SUPER_MAGIC:      "ext4",
+	unix.XFS_SUPER_MAGIC:       "xfs",
+	unix.BTRFS_SUPER_MAGIC:     "btrfs",
+	zfsSuperMagic:              "zfs",
+	unix.F2FS_SUPER_MAGIC:      "f2fs",
+	unix.TMPFS_MAGIC:           "tmpfs",
+	unix.OVERLAYFS_SUPER_MAGIC: "overlayfs",
+	unix.NFS_SUPER_MAGIC:       "nfs",
+	unix.FUSE_SUPER_MAGIC:      "fuse",
+}
+
+// Type returns the type of the filesystem that the server's data directory is
+// stored on, such as "ext4", "xfs" or "btrfs". Features such as reflink copies
+// and project quotas are only available on specific filesystems. If the type
+// is not one that is recognized, the magic number of the filesystem is returned
+// in hexadecimal, and if it cannot be determined an empty string is returned.
+func (fs *Filesystem) Type() string {
+	var st unix.Statfs_t
+	if err := unix.Statfs(fs.Path(), &st); err != nil {
+		return ""
+	}
+	if name, ok := filesystemTypes[int64(st.Type)]; ok {
+		return name
+	}
+	return "0x" + strconv.FormatInt(int64(st.Type), 16)
+}
@@ -0,0 +1,108 @@
+package filesystem
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+
+	"github.com/pelican-dev/wings/internal/ufs"
+)
+
+// The maximum number of mimetypes that will be cached for a single server before
+// the cache is cleared.
+const mimeCacheSize = 4096
+
+// Mimetypes for common game server files that cannot be reliably detected by
+// sniffing the contents of the file, keyed by file extension.
+var mimeExtensions = map[string]string{
+	".jar":        "application/java-archive",
+	".mca":        "application/x-minecraft-region",
+	".mcr":        "application/x-minecraft-region",
+	".nbt":        "application/x-minecraft-nbt",
+	".properties": "text/x-java-properties",
+	".yml":        "text/yaml",
+	".yaml":       "text/yaml",
+	".toml":       "text/x-toml",
+}
+
+type mimeCacheKey struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// mimeCache stores the detected mimetypes of files keyed by their path, size and
+// modification time, so that repeated directory listings do not need to read
+// every file again.
+type mimeCache struct {
+	mu      sync.Mutex
+	entries map[mimeCacheKey]string
+}
+
+func (c *mimeCache) get(k mimeCacheKey) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.entries[k]
+	return v, ok
+}
+
+func (c *mimeCache) set(k mimeCacheKey, v string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil || len(c.entries) >= mimeCacheSize {
+		c.entries = make(map[mimeCacheKey]string)
+	}
+	c.entries[k] = v
+}
+
+// mimetype returns the mimetype for the file at the given path. Directories
+// always return "inode/directory", regular files are sniffed using the first
+// few bytes of their contents falling back to the file extension, and any other
+// type of file is returned as "application/octet-stream". The reader returned by
+// open is closed once the mimetype has been detected.
+func (fs *Filesystem) mimetype(p string, info ufs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+	if info.IsDir() {
+		return "inode/directory", nil
+	}
+	if !info.Mode().IsRegular() {
+		return "application/octet-stream", nil
+	}
+
+	k := mimeCacheKey{path: filepath.Clean(p), size: info.Size(), modTime: info.ModTime()}
+	if v, ok := fs.mimeCache.get(k); ok {
+		return v, nil
+	}
+
+	f, err := open()
+	if err != nil {
+		return "", err
+	}
+	m, err := detectMimetype(f, info.Name())
+	_ = f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	fs.mimeCache.set(k, m)
+	return m, nil
+}
+
+// detectMimetype detects the mimetype of a file by reading the start of the
+// reader. If the detected type is generic, the mimetype is determined using the
+// file extension for known game server file types instead.
+func detectMimetype(r io.Reader, name string) (string, error) {
+	m, err := mimetype.DetectReader(r)
+	if err != nil {
+		return "", err
+	}
+	if m.Is("application/octet-stream") || m.Is("text/plain") || m.Is("application/zip") {
+		if v, ok := mimeExtensions[strings.ToLower(filepath.Ext(name))]; ok {
+			return v, nil
+		}
+	}
+	return m.String(), nil
+}
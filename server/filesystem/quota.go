@@ -0,0 +1,184 @@
+package filesystem
+
+import (
+	"unsafe"
+
+	"emperror.dev/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/pelican-dev/wings/internal/ufs"
+)
+
+// Definitions for the project quota interfaces that are not provided by the
+// unix package.
+const (
+	fsIocFsGetXattr     = 0x801c581f
+	fsIocFsSetXattr     = 0x401c5820
+	fsXflagProjInherit  = 0x00000200
+	quotaTypeProject    = 2
+	quotaCmdSetQuota    = 0x800008
+	quotaValidBlkLimits = 1
+)
+
+// ErrProjectQuotaUnsupported is returned when the filesystem that a server's
+// data directory is stored on does not support project quotas, or they have
+// not been enabled for it.
+var ErrProjectQuotaUnsupported = errors.Sentinel("filesystem: project quotas are not supported")
+
+// Matches "struct fsxattr" from linux/fs.h.
+type fsxattr struct {
+	xflags     uint32
+	extsize    uint32
+	nextents   uint32
+	projid     uint32
+	cowextsize uint32
+	pad        [8]byte
+}
+
+// Matches "struct if_dqblk" from linux/quota.h.
+type dqblk struct {
+	bhardlimit uint64
+	bsoftlimit uint64
+	curspace   uint64
+	ihardlimit uint64
+	isoftlimit uint64
+	curinodes  uint64
+	btime      uint64
+	itime      uint64
+	valid      uint32
+}
+
+// SetProjectQuota assigns the given project ID to the server's data directory
+// and sets a project quota matching the disk limit of the server. The kernel
+// then enforces the limit directly, causing any writes beyond it to fail, rather
+// than relying on the disk usage being checked periodically.
+//
+// This is only supported on filesystems with project quotas enabled, such as
+// xfs and ext4 mounted with the "prjquota" option. If they are not supported
+// ErrProjectQuotaUnsupported is returned. A disk limit of zero removes the limit
+// from the project.
+func (fs *Filesystem) SetProjectQuota(id uint32) error {
+	fd, err := unix.Open(fs.Path(), unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return errors.Wrap(err, "server/filesystem: quota: failed to open data directory")
+	}
+	defer unix.Close(fd)
+
+	var attr fsxattr
+	if err := fsxattrIoctl(fd, fsIocFsGetXattr, &attr); err != nil {
+		return quotaError(err)
+	}
+	// Only walk the entire directory if the project ID has not already been applied
+	// to it, this is only required the first time the quota is set for a server.
+	if attr.projid != id || attr.xflags&fsXflagProjInherit == 0 {
+		if err := setProjectID(fd, id); err != nil {
+			return quotaError(err)
+		}
+		if err := fs.setProjectIDRecursive(id); err != nil {
+			return err
+		}
+	}
+
+	limit := fs.MaxDisk()
+	if limit < 0 {
+		limit = 0
+	}
+	return setProjectLimit(fd, id, limit)
+}
+
+// RemoveProjectQuota removes the limit from the given project ID on the
+// filesystem that the server's data directory is stored on. This should be
+// called when the server is deleted so that the project ID does not keep a
+// limit that would apply to any server that is later given the same ID.
+func (fs *Filesystem) RemoveProjectQuota(id uint32) error {
+	fd, err := unix.Open(fs.Path(), unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return errors.Wrap(err, "server/filesystem: quota: failed to open data directory")
+	}
+	defer unix.Close(fd)
+	return setProjectLimit(fd, id, 0)
+}
+
+// Sets the hard limit for the project ID on the filesystem containing the file
+// descriptor. A limit of zero removes the limit from the project.
+func setProjectLimit(fd int, id uint32, limit int64) error {
+	q := dqblk{
+		// Quota limits are specified in blocks of 1024 bytes.
+		bhardlimit: uint64((limit + 1023) / 1024),
+		valid:      quotaValidBlkLimits,
+	}
+	cmd := quotaCmdSetQuota<<8 | quotaTypeProject
+	if _, _, errno := unix.Syscall6(unix.SYS_QUOTACTL_FD, uintptr(fd), uintptr(cmd), uintptr(id), uintptr(unsafe.Pointer(&q)), 0, 0); errno != 0 {
+		return quotaError(errno)
+	}
+	return nil
+}
+
+// Assigns the project ID to all the files and directories within the server's
+// data directory, so that the existing files count towards the project quota.
+// New files inherit the project ID from their parent directory automatically.
+func (fs *Filesystem) setProjectIDRecursive(id uint32) error {
+	dirfd, name, closeFd, err := fs.unixFS.SafePath("/")
+	defer closeFd()
+	if err != nil {
+		return err
+	}
+	err = fs.unixFS.WalkDirat(dirfd, name, func(dirfd int, name, _ string, d ufs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Project IDs can only be set on regular files and directories.
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+		fd, err := unix.Openat(dirfd, name, unix.O_RDONLY|unix.O_NOFOLLOW|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+		if err != nil {
+			if errors.Is(err, unix.ENOENT) {
+				return nil
+			}
+			return err
+		}
+		defer unix.Close(fd)
+		return setProjectID(fd, id)
+	})
+	return errors.WrapIf(err, "server/filesystem: quota: failed to set project id")
+}
+
+// Sets the project ID on the file descriptor, marking directories so that any
+// files created within them inherit the same project ID.
+func setProjectID(fd int, id uint32) error {
+	var attr fsxattr
+	if err := fsxattrIoctl(fd, fsIocFsGetXattr, &attr); err != nil {
+		return err
+	}
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return err
+	}
+	attr.projid = id
+	if st.Mode&unix.S_IFMT == unix.S_IFDIR {
+		attr.xflags |= fsXflagProjInherit
+	}
+	return fsxattrIoctl(fd, fsIocFsSetXattr, &attr)
+}
+
+func fsxattrIoctl(fd int, req uintptr, attr *fsxattr) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), req, uintptr(unsafe.Pointer(attr))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Converts the errors returned by the kernel when project quotas are not
+// available into ErrProjectQuotaUnsupported.
+func quotaError(err error) error {
+	switch {
+	case errors.Is(err, unix.ENOTTY),
+		errors.Is(err, unix.EOPNOTSUPP),
+		errors.Is(err, unix.ENOSYS),
+		errors.Is(err, unix.ESRCH),
+		errors.Is(err, unix.EINVAL):
+		return errors.WithStack(ErrProjectQuotaUnsupported)
+	}
+	return errors.Wrap(err, "server/filesystem: quota: failed to set project quota")
+}
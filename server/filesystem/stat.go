@@ -6,8 +6,6 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/gabriel-vasile/mimetype"
-
 	"github.com/pelican-dev/wings/internal/ufs"
 )
 
@@ -43,29 +41,31 @@ func (s *Stat) MarshalJSON() ([]byte, error) {
 	})
 }
 
-func statFromFile(f ufs.File) (Stat, error) {
+// ETag returns an opaque value identifying the current version of the file based
+// on its modification time and size. This can be passed back by clients when
+// writing the file to ensure it was not modified since they last read it.
+func (s *Stat) ETag() string {
+	return strconv.Quote(strconv.FormatInt(s.ModTime().UnixNano(), 36) + "-" + strconv.FormatInt(s.Size(), 36))
+}
+
+// statFromFile returns the stat information for an open file, including the
+// mimetype of the file. The file is returned to the start once the mimetype has
+// been detected.
+func (fs *Filesystem) statFromFile(p string, f ufs.File) (Stat, error) {
 	s, err := f.Stat()
 	if err != nil {
 		return Stat{}, err
 	}
-	var m *mimetype.MIME
-	if !s.IsDir() {
-		m, err = mimetype.DetectReader(f)
-		if err != nil {
-			return Stat{}, err
-		}
+	m, err := fs.mimetype(p, s, func() (io.ReadCloser, error) { return io.NopCloser(f), nil })
+	if err != nil {
+		return Stat{}, err
+	}
+	if s.Mode().IsRegular() {
 		if _, err := f.Seek(0, io.SeekStart); err != nil {
 			return Stat{}, err
 		}
 	}
-	st := Stat{
-		FileInfo: s,
-		Mimetype: "inode/directory",
-	}
-	if m != nil {
-		st.Mimetype = m.String()
-	}
-	return st, nil
+	return Stat{FileInfo: s, Mimetype: m}, nil
 }
 
 // Stat stats a file or folder and returns the base stat object from go along
@@ -76,7 +76,7 @@ func (fs *Filesystem) Stat(p string) (Stat, error) {
 		return Stat{}, err
 	}
 	defer f.Close()
-	st, err := statFromFile(f)
+	st, err := fs.statFromFile(p, f)
 	if err != nil {
 		return Stat{}, err
 	}
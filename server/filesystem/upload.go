@@ -0,0 +1,283 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/google/uuid"
+
+	"github.com/pelican-dev/wings/internal/ufs"
+)
+
+// The amount of time that an upload session is kept after the last chunk was
+// received before it is removed along with any data that was uploaded.
+const uploadSessionTimeout = time.Hour
+
+// Matches the names of the temporary files that chunked uploads are written to
+// before they are moved into place.
+var partialUploadRegex = regexp.MustCompile(`^\..+\.[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\.part$`)
+
+var (
+	ErrUploadNotFound = errors.Sentinel("filesystem: upload session not found")
+	ErrUploadOffset   = errors.Sentinel("filesystem: upload chunk offset does not match the received data")
+	ErrUploadSize     = errors.Sentinel("filesystem: upload chunk exceeds the declared size")
+	ErrUploadChecksum = errors.Sentinel("filesystem: upload checksum does not match")
+)
+
+// ChunkedUpload is an upload session for a file that is sent in multiple chunks,
+// allowing an upload to be resumed after a failure rather than being restarted.
+// The chunks are written to a temporary file next to the final file, which is
+// then moved into place once the upload is finalized.
+type ChunkedUpload struct {
+	mu sync.Mutex
+
+	Id        string    `json:"id"`
+	File      string    `json:"file"`
+	Size      int64     `json:"size"`
+	Offset    int64     `json:"offset"`
+	Checksum  string    `json:"checksum,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	partial string
+}
+
+// Status returns a copy of the upload session that is safe to serialize.
+func (u *ChunkedUpload) Status() ChunkedUpload {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return ChunkedUpload{
+		Id:        u.Id,
+		File:      u.File,
+		Size:      u.Size,
+		Offset:    u.Offset,
+		Checksum:  u.Checksum,
+		ExpiresAt: u.ExpiresAt,
+	}
+}
+
+type uploadSessions struct {
+	mu sync.Mutex
+	m  map[string]*ChunkedUpload
+}
+
+// CreateUpload creates a new chunked upload session for the file at the given
+// path. The full size of the file is reserved against the disk limit of the
+// server up front so that the upload cannot fail part way through due to a lack
+// of disk space. If a checksum is provided, the SHA-256 checksum of the uploaded
+// file must match it when the upload is finalized.
+func (fs *Filesystem) CreateUpload(p string, size int64, checksum string) (*ChunkedUpload, error) {
+	fs.expireUploads()
+
+	p = filepath.Clean("/" + strings.TrimLeft(p, "/"))
+	if err := fs.IsIgnored(p); err != nil {
+		return nil, err
+	}
+	if st, err := fs.unixFS.Stat(p); err == nil && st.IsDir() {
+		return nil, errors.WithStack(&Error{code: ErrCodeIsDirectory, resolved: p})
+	}
+	if size < 0 {
+		return nil, errors.New("filesystem: upload size cannot be negative")
+	}
+	if !fs.unixFS.Reserve(size) {
+		return nil, newFilesystemError(ErrCodeDiskSpace, nil)
+	}
+
+	u := &ChunkedUpload{
+		Id:        uuid.NewString(),
+		File:      p,
+		Size:      size,
+		Checksum:  strings.ToLower(checksum),
+		ExpiresAt: time.Now().Add(uploadSessionTimeout),
+	}
+	u.partial = filepath.Join(filepath.Dir(p), "."+filepath.Base(p)+"."+u.Id+".part")
+	f, err := fs.unixFS.Touch(u.partial, ufs.O_RDWR|ufs.O_CREATE|ufs.O_TRUNC, 0o644)
+	if err != nil {
+		fs.unixFS.Add(-size)
+		return nil, err
+	}
+	_ = f.Close()
+
+	fs.uploads.mu.Lock()
+	if fs.uploads.m == nil {
+		fs.uploads.m = make(map[string]*ChunkedUpload)
+	}
+	fs.uploads.m[u.Id] = u
+	fs.uploads.mu.Unlock()
+
+	return u, nil
+}
+
+// Upload returns the upload session with the given ID.
+func (fs *Filesystem) Upload(id string) (*ChunkedUpload, error) {
+	fs.expireUploads()
+
+	fs.uploads.mu.Lock()
+	defer fs.uploads.mu.Unlock()
+	u, ok := fs.uploads.m[id]
+	if !ok {
+		return nil, errors.WithStack(ErrUploadNotFound)
+	}
+	return u, nil
+}
+
+// WriteUploadChunk writes a chunk of data to the upload session at the given
+// offset. The offset cannot be beyond the amount of data received so far, but
+// may be before it to allow a chunk that failed part way through to be sent
+// again. The new offset of the upload is returned.
+func (fs *Filesystem) WriteUploadChunk(id string, offset int64, r io.Reader, size int64) (int64, error) {
+	u, err := fs.Upload(id)
+	if err != nil {
+		return 0, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if offset < 0 || offset > u.Offset {
+		return u.Offset, errors.WithStack(ErrUploadOffset)
+	}
+	if offset+size > u.Size {
+		return u.Offset, errors.WithStack(ErrUploadSize)
+	}
+
+	f, err := fs.unixFS.OpenFile(u.partial, ufs.O_WRONLY, 0)
+	if err != nil {
+		return u.Offset, err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(io.NewOffsetWriter(f, offset), io.LimitReader(r, size))
+	u.Offset = max(u.Offset, offset+n)
+	u.ExpiresAt = time.Now().Add(uploadSessionTimeout)
+	if err != nil {
+		return u.Offset, errors.Wrap(err, "server/filesystem: upload: failed to write chunk")
+	}
+	return u.Offset, nil
+}
+
+// FinalizeUpload verifies that all the data for the upload has been received,
+// and that it matches the checksum of the upload if one was provided, and then
+// moves the file into place, replacing any existing file at the same path.
+func (fs *Filesystem) FinalizeUpload(id string) error {
+	u, err := fs.Upload(id)
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.Offset != u.Size {
+		return errors.WithStack(ErrUploadOffset)
+	}
+	if u.Checksum != "" {
+		f, err := fs.unixFS.Open(u.partial)
+		if err != nil {
+			return err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		_ = f.Close()
+		if err != nil {
+			return errors.Wrap(err, "server/filesystem: upload: failed to compute checksum")
+		}
+		if hex.EncodeToString(h.Sum(nil)) != u.Checksum {
+			return errors.WithStack(ErrUploadChecksum)
+		}
+	}
+
+	// Any file being replaced is removed from the disk usage by RenameReplace, since
+	// the size of the upload was already reserved when it was created.
+	if st, err := fs.unixFS.Lstat(u.File); err == nil && st.IsDir() {
+		return errors.WithStack(&Error{code: ErrCodeIsDirectory, resolved: u.File})
+	}
+	if err := fs.RenameReplace(u.partial, u.File); err != nil {
+		return errors.Wrap(err, "server/filesystem: upload: failed to move file into place")
+	}
+
+	fs.uploads.mu.Lock()
+	delete(fs.uploads.m, u.Id)
+	fs.uploads.mu.Unlock()
+
+	return fs.chownFile(u.File)
+}
+
+// CancelUpload cancels an upload session, removing any data that was uploaded
+// and releasing the disk space that was reserved for it.
+func (fs *Filesystem) CancelUpload(id string) error {
+	fs.uploads.mu.Lock()
+	u, ok := fs.uploads.m[id]
+	delete(fs.uploads.m, id)
+	fs.uploads.mu.Unlock()
+	if !ok {
+		return errors.WithStack(ErrUploadNotFound)
+	}
+	return fs.removeUpload(u)
+}
+
+func (fs *Filesystem) removeUpload(u *ChunkedUpload) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	fs.unixFS.Add(-u.Size)
+	if err := fs.unixFS.Remove(u.partial); err != nil && !errors.Is(err, ufs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// expireUploads removes any upload sessions that have not received any data
+// within the session timeout. Sessions that are currently locked are receiving
+// a chunk, so they are skipped rather than waiting for the chunk to be written.
+func (fs *Filesystem) expireUploads() {
+	var expired []*ChunkedUpload
+	fs.uploads.mu.Lock()
+	for id, u := range fs.uploads.m {
+		if !u.mu.TryLock() {
+			continue
+		}
+		if time.Now().After(u.ExpiresAt) {
+			expired = append(expired, u)
+			delete(fs.uploads.m, id)
+		}
+		u.mu.Unlock()
+	}
+	fs.uploads.mu.Unlock()
+
+	for _, u := range expired {
+		if err := fs.removeUpload(u); err != nil {
+			fs.error(err).WithField("upload", u.Id).Warn("failed to remove expired upload")
+		}
+	}
+}
+
+// RemoveStaleUploads removes the temporary files left behind by any chunked
+// uploads that were in progress when Wings was stopped. Upload sessions are only
+// kept in memory, so these uploads can never be resumed or finalized. This must
+// only be called when the server is first loaded, before any new upload sessions
+// have been created.
+func (fs *Filesystem) RemoveStaleUploads() error {
+	var stale []string
+	err := fs.unixFS.WalkDir(".", func(p string, d ufs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && partialUploadRegex.MatchString(d.Name()) {
+			stale = append(stale, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "server/filesystem: upload: failed to find stale uploads")
+	}
+	for _, p := range stale {
+		if err := fs.unixFS.Remove(p); err != nil && !errors.Is(err, ufs.ErrNotExist) {
+			return errors.Wrap(err, "server/filesystem: upload: failed to remove stale upload")
+		}
+	}
+	return nil
+}
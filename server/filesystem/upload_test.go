@@ -0,0 +1,137 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_ChunkedUpload(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	content := "hello chunked world"
+	sum := sha256.Sum256([]byte(content))
+	checksum := hex.EncodeToString(sum[:])
+
+	g.Describe("ChunkedUpload", func() {
+		g.BeforeEach(func() {
+			fs.unixFS.SetUsage(0)
+		})
+
+		g.It("assembles chunks and moves the file into place", func() {
+			u, err := fs.CreateUpload("/uploads/file.txt", int64(len(content)), checksum)
+			g.Assert(err).IsNil()
+			g.Assert(fs.CachedUsage()).Equal(int64(len(content)))
+
+			offset, err := fs.WriteUploadChunk(u.Id, 0, strings.NewReader(content[:5]), 5)
+			g.Assert(err).IsNil()
+			g.Assert(offset).Equal(int64(5))
+
+			// Resending part of a chunk that was already received is allowed.
+			offset, err = fs.WriteUploadChunk(u.Id, 3, strings.NewReader(content[3:]), int64(len(content)-3))
+			g.Assert(err).IsNil()
+			g.Assert(offset).Equal(int64(len(content)))
+
+			err = fs.FinalizeUpload(u.Id)
+			g.Assert(err).IsNil()
+
+			b, err := os.ReadFile(filepath.Join(rfs.root, "server/uploads/file.txt"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal(content)
+			g.Assert(fs.CachedUsage()).Equal(int64(len(content)))
+
+			_, err = fs.Upload(u.Id)
+			g.Assert(errors.Is(err, ErrUploadNotFound)).IsTrue()
+		})
+
+		g.It("replaces an existing file", func() {
+			err := rfs.CreateServerFileFromString("file.txt", "existing data")
+			g.Assert(err).IsNil()
+			fs.unixFS.SetUsage(int64(len("existing data")))
+
+			u, err := fs.CreateUpload("file.txt", int64(len(content)), "")
+			g.Assert(err).IsNil()
+			_, err = fs.WriteUploadChunk(u.Id, 0, strings.NewReader(content), int64(len(content)))
+			g.Assert(err).IsNil()
+
+			err = fs.FinalizeUpload(u.Id)
+			g.Assert(err).IsNil()
+
+			b, err := os.ReadFile(filepath.Join(rfs.root, "server/file.txt"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal(content)
+			g.Assert(fs.CachedUsage()).Equal(int64(len(content)))
+		})
+
+		g.It("does not allow gaps in the uploaded data", func() {
+			u, err := fs.CreateUpload("file.txt", int64(len(content)), "")
+			g.Assert(err).IsNil()
+
+			_, err = fs.WriteUploadChunk(u.Id, 5, strings.NewReader(content[5:]), int64(len(content)-5))
+			g.Assert(errors.Is(err, ErrUploadOffset)).IsTrue()
+
+			err = fs.FinalizeUpload(u.Id)
+			g.Assert(errors.Is(err, ErrUploadOffset)).IsTrue()
+		})
+
+		g.It("rejects uploads with a mismatched checksum", func() {
+			u, err := fs.CreateUpload("file.txt", 3, checksum)
+			g.Assert(err).IsNil()
+
+			_, err = fs.WriteUploadChunk(u.Id, 0, strings.NewReader("abc"), 3)
+			g.Assert(err).IsNil()
+
+			err = fs.FinalizeUpload(u.Id)
+			g.Assert(errors.Is(err, ErrUploadChecksum)).IsTrue()
+		})
+
+		g.It("reserves the size of the upload against the disk limit", func() {
+			fs.SetDiskLimit(8)
+			defer fs.SetDiskLimit(0)
+
+			_, err := fs.CreateUpload("file.txt", int64(len(content)), "")
+			g.Assert(err).IsNotNil()
+			g.Assert(IsErrorCode(err, ErrCodeDiskSpace)).IsTrue()
+		})
+
+		g.It("releases the reserved space when cancelled", func() {
+			u, err := fs.CreateUpload("file.txt", int64(len(content)), "")
+			g.Assert(err).IsNil()
+			g.Assert(fs.CachedUsage()).Equal(int64(len(content)))
+
+			err = fs.CancelUpload(u.Id)
+			g.Assert(err).IsNil()
+			g.Assert(fs.CachedUsage()).Equal(int64(0))
+
+			entries, err := fs.ReadDir("/")
+			g.Assert(err).IsNil()
+			g.Assert(len(entries)).Equal(0)
+		})
+
+		g.It("removes stale uploads left behind by a restart", func() {
+			u, err := fs.CreateUpload("/uploads/file.txt", int64(len(content)), "")
+			g.Assert(err).IsNil()
+			err = rfs.CreateServerFileFromString("uploads/.file.txt.part", "not an upload")
+			g.Assert(err).IsNil()
+
+			err = fs.RemoveStaleUploads()
+			g.Assert(err).IsNil()
+
+			_, err = os.Stat(filepath.Join(rfs.root, "server", u.partial))
+			g.Assert(errors.Is(err, os.ErrNotExist)).IsTrue()
+			_, err = os.Stat(filepath.Join(rfs.root, "server/uploads/.file.txt.part"))
+			g.Assert(err).IsNil()
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+	})
+}
@@ -0,0 +1,31 @@
+package filesystem
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/pelican-dev/wings/config"
+	"github.com/pelican-dev/wings/internal/ufs"
+)
+
+// walkConcurrency returns the number of goroutines that should be used when
+// walking a directory tree in parallel.
+func walkConcurrency() int {
+	if n := config.Get().System.WalkConcurrency; n > 0 {
+		return n
+	}
+	return runtime.NumCPU()
+}
+
+// WalkParallel walks the directory tree at the given root, reading multiple
+// subdirectories at the same time. The walk function may be called from several
+// goroutines at once, so it must be safe for concurrent use, and entries are
+// not visited in any particular order.
+func (fs *Filesystem) WalkParallel(ctx context.Context, root string, fn ufs.WalkDiratFunc) error {
+	dirfd, name, closeFd, err := fs.unixFS.SafePath(root)
+	defer closeFd()
+	if err != nil {
+		return err
+	}
+	return fs.unixFS.WalkDiratParallel(ctx, dirfd, name, walkConcurrency(), fn)
+}
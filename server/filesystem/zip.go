@@ -0,0 +1,103 @@
+package filesystem
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"path"
+	"strings"
+
+	"emperror.dev/errors"
+
+	"github.com/pelican-dev/wings/internal/ufs"
+)
+
+// StreamZip writes a zip archive containing the given files to the writer. The
+// files are relative to the root directory, and any directories provided are
+// included recursively. Nothing is written to the disk while the archive is
+// being generated, it is streamed directly to the writer.
+//
+// Any files matching the server's denylist are excluded from the archive, as are
+// symlinks and special files such as sockets and named pipes.
+func (fs *Filesystem) StreamZip(ctx context.Context, w io.Writer, root string, files []string) error {
+	zw := zip.NewWriter(w)
+
+	root = strings.TrimPrefix(path.Clean("/"+root), "/")
+	for _, f := range files {
+		f = strings.TrimPrefix(path.Clean("/"+f), "/")
+		if f == "" {
+			continue
+		}
+		if err := fs.addToZip(ctx, zw, root, f); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// Adds the given file, or all the files within the given directory, to the zip
+// archive being generated.
+func (fs *Filesystem) addToZip(ctx context.Context, zw *zip.Writer, root, file string) error {
+	dirfd, name, closeFd, err := fs.unixFS.SafePath(path.Join(root, file))
+	defer closeFd()
+	if err != nil {
+		return err
+	}
+
+	base := path.Base(file)
+	return fs.unixFS.WalkDirat(dirfd, name, func(dirfd int, name, relative string, d ufs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := fs.IsIgnored(path.Join(root, file, relative)); err != nil {
+			if d.IsDir() {
+				return ufs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, ufs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return errors.WrapIff(err, "failed to get zip#FileInfoHeader for '%s'", name)
+		}
+		header.Name = base
+		if relative != "." {
+			header.Name = path.Join(base, relative)
+		}
+		header.Method = zip.Deflate
+
+		f, err := fs.unixFS.OpenFileat(dirfd, name, ufs.O_RDONLY, 0)
+		if err != nil {
+			if errors.Is(err, ufs.ErrNotExist) {
+				return nil
+			}
+			return errors.WrapIff(err, "failed to open '%s' for copying", header.Name)
+		}
+		defer f.Close()
+
+		zf, err := zw.CreateHeader(header)
+		if err != nil {
+			return errors.WrapIff(err, "failed to write zip#FileInfoHeader for '%s'", header.Name)
+		}
+		if _, err := io.Copy(zf, io.LimitReader(f, info.Size())); err != nil {
+			return errors.WrapIff(err, "failed to copy '%s' to archive", header.Name)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+
+	"github.com/pelican-dev/wings/internal/database"
+	"github.com/pelican-dev/wings/internal/models"
+)
+
+// maxServerHistory is the number of history events retained for each server, older
+// events are removed as new ones are recorded.
+const maxServerHistory = 50
+
+// recordHistory stores a crash or power event for the server in the local history table
+// in a background routine, trimming the table so that only the most recent events for the
+// server are retained.
+func (s *Server) recordHistory(event models.Event, metadata models.ActivityMeta) {
+	if !database.Initialized() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(s.Context(), time.Second*3)
+	go func() {
+		defer cancel()
+		db := database.Instance().WithContext(ctx)
+		if err := db.Create(&models.ServerHistory{Server: s.ID(), Event: event, Metadata: metadata}).Error; err != nil {
+			s.Log().WithField("error", errors.WithStack(err)).WithField("event", event).Warn("history: failed to save event")
+			return
+		}
+		keep := db.Model(&models.ServerHistory{}).
+			Select("id").
+			Where("server = ?", s.ID()).
+			Order("id DESC").
+			Limit(maxServerHistory)
+		if err := db.Where("server = ? AND id NOT IN (?)", s.ID(), keep).Delete(&models.ServerHistory{}).Error; err != nil {
+			s.Log().WithField("error", errors.WithStack(err)).Warn("history: failed to trim events")
+		}
+	}()
+}
@@ -13,6 +13,7 @@ import (
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
+	"github.com/buger/jsonparser"
 	"github.com/docker/docker/api/types/container"
 	dockerImage "github.com/docker/docker/api/types/image"
 
@@ -22,6 +23,7 @@ import (
 
 	"github.com/pelican-dev/wings/config"
 	"github.com/pelican-dev/wings/environment"
+	"github.com/pelican-dev/wings/environment/docker"
 	"github.com/pelican-dev/wings/remote"
 	"github.com/pelican-dev/wings/system"
 )
@@ -45,7 +47,7 @@ func (s *Server) install(reinstall bool) error {
 		// install process being executed.
 		s.Events().Publish(InstallStartedEvent, "")
 
-		err = s.internalInstall()
+		err = s.internalInstall(reinstall)
 	} else {
 		s.Log().Info("server configured to skip running installation scripts for this egg, not executing process")
 	}
@@ -72,6 +74,7 @@ func (s *Server) install(reinstall bool) error {
 	// Push an event to the websocket, so we can auto-refresh the information in
 	// the panel once the installation is completed.
 	s.Events().Publish(InstallCompletedEvent, "")
+	s.publishNodeEvent(ServerInstalledEvent, NodeEvent{Server: s.ID()})
 
 	return errors.WithStackIf(err)
 }
@@ -95,9 +98,26 @@ func (s *Server) Reinstall() error {
 	return s.install(true)
 }
 
+// CancelInstall aborts the installation process that is currently running for the
+// server, stopping and removing the installation container. The Panel is notified
+// that the installation was not successful. Returns false if no installation is
+// currently running for the server.
+func (s *Server) CancelInstall() bool {
+	s.Lock()
+	defer s.Unlock()
+	if s.installCancel == nil {
+		return false
+	}
+	s.installCancel()
+	return true
+}
+
 // Internal installation function used to simplify reporting back to the Panel.
-func (s *Server) internalInstall() error {
-	script, err := s.client.GetInstallationScript(s.Context(), s.ID())
+func (s *Server) internalInstall(reinstall bool) error {
+	ctx, cancel := context.WithCancel(s.Context())
+	defer cancel()
+
+	script, err := s.client.GetInstallationScript(ctx, s.ID())
 	if err != nil {
 		return err
 	}
@@ -105,9 +125,19 @@ func (s *Server) internalInstall() error {
 	if err != nil {
 		return err
 	}
+	p.reinstall = reinstall
+	p.ctx = ctx
+	p.cancel = cancel
 
 	s.Log().Info("beginning installation process for server")
 	if err := p.Run(); err != nil {
+		// Only treat this as a cancellation if the install itself was aborted, and not
+		// because the server is being removed.
+		if ctx.Err() != nil && s.Context().Err() == nil {
+			s.Log().Info("installation process for server was canceled")
+			s.PublishConsoleOutputFromDaemon("Installation process was canceled.")
+			return ErrInstallCanceled
+		}
 		return err
 	}
 
@@ -116,9 +146,16 @@ func (s *Server) internalInstall() error {
 }
 
 type InstallationProcess struct {
-	Server *Server
-	Script *remote.InstallationScript
-	client *client.Client
+	Server    *Server
+	Script    *remote.InstallationScript
+	client    *client.Client
+	reinstall bool
+	usage     installUsage
+
+	// The context for the installation process, which is canceled if the install is
+	// aborted by calling Server.CancelInstall.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // NewInstallationProcess returns a new installation process struct that will be
@@ -128,6 +165,7 @@ func NewInstallationProcess(s *Server, script *remote.InstallationScript) (*Inst
 	proc := &InstallationProcess{
 		Script: script,
 		Server: s,
+		ctx:    s.Context(),
 	}
 
 	if c, err := environment.Docker(); err != nil {
@@ -191,6 +229,19 @@ func (ip *InstallationProcess) Run() error {
 		ip.Server.installing.Store(false)
 	}()
 
+	// Only expose the cancel function once the lock is held, so that a second install
+	// which fails to obtain the lock cannot replace or clear it for the running one.
+	if ip.cancel != nil {
+		ip.Server.Lock()
+		ip.Server.installCancel = ip.cancel
+		ip.Server.Unlock()
+		defer func() {
+			ip.Server.Lock()
+			ip.Server.installCancel = nil
+			ip.Server.Unlock()
+		}()
+	}
+
 	if err := ip.BeforeExecute(); err != nil {
 		return err
 	}
@@ -207,6 +258,10 @@ func (ip *InstallationProcess) Run() error {
 		ip.Server.Log().WithField("error", err).Warn("failed to complete after-execute step of installation process")
 	}
 
+	if err := ip.PostInstall(); err != nil {
+		return errors.WithMessage(err, "install: failed to run post-install command")
+	}
+
 	return nil
 }
 
@@ -236,33 +291,22 @@ func (ip *InstallationProcess) writeScriptToDisk() error {
 
 // Pulls the docker image to be used for the installation container.
 func (ip *InstallationProcess) pullInstallationImage() error {
-	// Get a registry auth configuration from the config.
-	var registryAuth *config.RegistryConfiguration
-	for registry, c := range config.Get().Docker.Registries {
-		if !strings.HasPrefix(ip.Script.ContainerImage, registry) {
-			continue
-		}
-
-		log.WithField("registry", registry).Debug("using authentication for registry")
-		registryAuth = &c
-		break
-	}
-
-	// Get the ImagePullOptions.
-	imagePullOptions := dockerImage.PullOptions{All: false}
-	if registryAuth != nil {
-		b64, err := registryAuth.Base64()
-		if err != nil {
-			log.WithError(err).Error("failed to get registry auth credentials")
+	// If a registry mirror is configured for the image try to pull it from there first,
+	// tagging it with the original reference so that the container can be created.
+	if mirror, ok := config.Get().Docker.MirrorImage(ip.Script.ContainerImage); ok {
+		l := log.WithField("image", ip.Script.ContainerImage).WithField("mirror", mirror)
+		if err := ip.pullImage(mirror); err != nil {
+			l.WithField("error", err).Warn("failed to pull installation image from registry mirror, falling back to the original registry")
+		} else if err := ip.client.ImageTag(ip.ctx, mirror, ip.Script.ContainerImage); err != nil {
+			l.WithField("error", err).Warn("failed to tag installation image pulled from registry mirror, falling back to the original registry")
+		} else {
+			return nil
 		}
-
-		// b64 is a string so if there is an error it will just be empty, not nil.
-		imagePullOptions.RegistryAuth = b64
 	}
 
-	r, err := ip.client.ImagePull(ip.Server.Context(), ip.Script.ContainerImage, imagePullOptions)
+	r, err := ip.client.ImagePull(ip.ctx, ip.Script.ContainerImage, docker.ImagePullOptions(ip.Script.ContainerImage))
 	if err != nil {
-		images, ierr := ip.client.ImageList(ip.Server.Context(), dockerImage.ListOptions{})
+		images, ierr := ip.client.ImageList(ip.ctx, dockerImage.ListOptions{})
 		if ierr != nil {
 			// Well damn, something has gone really wrong here, just go ahead and abort there
 			// isn't much anything we can do to try and self-recover from this.
@@ -286,7 +330,10 @@ func (ip *InstallationProcess) pullInstallationImage() error {
 			}
 		}
 
-		return err
+		perr := environment.NewImagePullError(ip.Script.ContainerImage, err)
+		ip.Server.Sink(system.InstallSink).Push([]byte(perr.Message()))
+		ip.Server.PublishConsoleOutputFromDaemon(perr.Message())
+		return perr
 	}
 	defer r.Close()
 
@@ -305,6 +352,23 @@ func (ip *InstallationProcess) pullInstallationImage() error {
 	return nil
 }
 
+// pullImage pulls the given image, blocking until the pull has completed.
+func (ip *InstallationProcess) pullImage(image string) error {
+	r, err := ip.client.ImagePull(ip.ctx, image, docker.ImagePullOptions(image))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if msg, _ := jsonparser.GetString(scanner.Bytes(), "error"); msg != "" {
+			return errors.New(msg)
+		}
+	}
+	return scanner.Err()
+}
+
 // BeforeExecute runs before the container is executed. This pulls down the
 // required docker container image as well as writes the installation script to
 // the disk. This process is executed in an async manner, if either one fails
@@ -324,7 +388,38 @@ func (ip *InstallationProcess) BeforeExecute() error {
 
 // GetLogPath returns the log path for the installation process.
 func (ip *InstallationProcess) GetLogPath() string {
-	return filepath.Join(config.Get().System.LogDirectory, "/install", ip.Server.ID()+".log")
+	return ip.Server.InstallLogPath()
+}
+
+// InstallLogPath returns the path to the most recent installation log for the
+// server on the host system.
+func (s *Server) InstallLogPath() string {
+	return filepath.Join(config.Get().System.LogDirectory, "/install", s.ID()+".log")
+}
+
+// ReadInstallLog returns up to the last n lines of the most recent installation
+// log for the server. If the server has never been installed on this node an
+// error matching os.ErrNotExist is returned.
+func (s *Server) ReadInstallLog(n int) ([]string, error) {
+	f, err := os.Open(s.InstallLogPath())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	out := make([]string, 0, n)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(out) == n {
+			out = out[1:]
+		}
+		out = append(out, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return out, nil
 }
 
 // AfterExecute cleans up after the execution of the installation process.
@@ -374,7 +469,7 @@ func (ip *InstallationProcess) AfterExecute(containerId string) error {
 |
 | Environment Variables
 | ------------------------------
-{{ range $key, $value := .Server.GetEnvironmentVariables }}  {{ $value }}
+{{ range $key, $value := .EnvironmentVariables }}  {{ $value }}
 {{ end }}
 
 |
@@ -388,10 +483,12 @@ func (ip *InstallationProcess) AfterExecute(containerId string) error {
 	// Create a data structure that includes both the InstallationProcess and the kernel version
 	data := struct {
 		*InstallationProcess
-		KernelVersion string
+		KernelVersion        string
+		EnvironmentVariables []string
 	}{
-		InstallationProcess: ip,
-		KernelVersion:       v.String(),
+		InstallationProcess:  ip,
+		KernelVersion:        v.String(),
+		EnvironmentVariables: ip.Server.redactedEnvironmentVariables(),
 	}
 
 	if err := tmpl.Execute(f, data); err != nil {
@@ -402,6 +499,10 @@ func (ip *InstallationProcess) AfterExecute(containerId string) error {
 		return err
 	}
 
+	if _, err := io.WriteString(f, ip.usage.String()); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -411,7 +512,7 @@ func (ip *InstallationProcess) Execute() (string, error) {
 	// Create a child context that is canceled once this function is done running. This
 	// will also be canceled if the parent context (from the Server struct) is canceled
 	// which occurs if the server is deleted.
-	ctx, cancel := context.WithCancel(ip.Server.Context())
+	ctx, cancel := context.WithCancel(ip.ctx)
 	defer cancel()
 
 	conf := &container.Config{
@@ -484,6 +585,7 @@ func (ip *InstallationProcess) Execute() (string, error) {
 	if err := ip.client.ContainerStart(ctx, r.ID, container.StartOptions{}); err != nil {
 		return "", err
 	}
+	go ip.trackUsage(ctx, r.ID)
 
 	// Process the install event in the background by listening to the stream output until the
 	// container has stopped, at which point we'll disconnect from it.
@@ -512,6 +614,118 @@ func (ip *InstallationProcess) Execute() (string, error) {
 	return r.ID, nil
 }
 
+// PostInstall executes the egg defined post-install command, if one is present,
+// inside a fresh container using the installation image. The output of the
+// command is streamed to the installation sink and appended to the end of the
+// installation log. This is skipped for reinstalls unless the egg explicitly
+// requests that it be run for them as well.
+func (ip *InstallationProcess) PostInstall() error {
+	pi := ip.Script.PostInstall
+	if pi == nil || strings.TrimSpace(pi.Command) == "" {
+		return nil
+	}
+	if ip.reinstall && !pi.RunOnReinstall {
+		ip.Server.Log().Debug("skipping post-install command for server reinstall")
+		return nil
+	}
+
+	timeout := time.Minute * 5
+	if pi.Timeout > 0 {
+		timeout = time.Duration(pi.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ip.ctx, timeout)
+	defer cancel()
+
+	name := ip.Server.ID() + "_post_installer"
+	defer func() {
+		// Use the server context here since the timed context may have already expired.
+		err := ip.client.ContainerRemove(ip.Server.Context(), name, container.RemoveOptions{RemoveVolumes: true, Force: true})
+		if err != nil && !client.IsErrNotFound(err) {
+			ip.Server.Log().WithField("error", err).Warn("failed to remove post-install container")
+		}
+	}()
+
+	conf := &container.Config{
+		Hostname:     "installer",
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          true,
+		Cmd:          []string{ip.Script.Entrypoint, "-c", strings.ReplaceAll(pi.Command, "\r\n", "\n")},
+		Image:        ip.Script.ContainerImage,
+		Env:          ip.Server.GetEnvironmentVariables(),
+		WorkingDir:   "/mnt/server",
+		Labels: map[string]string{
+			"Service":       "Pelican",
+			"ContainerType": "server_installer",
+		},
+	}
+
+	cfg := config.Get()
+	hostConf := &container.HostConfig{
+		Mounts: []mount.Mount{
+			{
+				Target:   "/mnt/server",
+				Source:   ip.Server.Filesystem().Path(),
+				Type:     mount.TypeBind,
+				ReadOnly: false,
+			},
+		},
+		Resources:   ip.resourceLimits(),
+		DNS:         cfg.Docker.Network.Dns,
+		LogConfig:   cfg.Docker.ContainerLogConfig(),
+		NetworkMode: container.NetworkMode(cfg.Docker.Network.Mode),
+		UsernsMode:  container.UsernsMode(cfg.Docker.UsernsMode),
+	}
+
+	ip.Server.Log().WithField("timeout", timeout).Info("running post-install command for server")
+	ip.Server.Events().Publish(DaemonMessageEvent, "Running post-install command...")
+
+	r, err := ip.client.ContainerCreate(ctx, conf, hostConf, nil, nil, name)
+	if err != nil {
+		return err
+	}
+	if err := ip.client.ContainerStart(ctx, r.ID, container.StartOptions{}); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(ip.GetLogPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, _ = f.WriteString("\n|\n| Post-Install Output\n| ------------------------------\n")
+
+	reader, err := ip.client.ContainerLogs(ctx, r.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true, Follow: true})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	err = system.ScanReader(reader, func(line []byte) {
+		ip.Server.Sink(system.InstallSink).Push(line)
+		_, _ = f.Write(append(line, '\n'))
+	})
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		ip.Server.Log().WithField("error", err).Warn("error processing post-install output lines")
+	}
+
+	sChan, eChan := ip.client.ContainerWait(ctx, r.ID, container.WaitConditionNotRunning)
+	select {
+	case err := <-eChan:
+		if errors.Is(err, context.DeadlineExceeded) {
+			return errors.Errorf("post-install command did not complete within %s", timeout)
+		}
+		return err
+	case res := <-sChan:
+		if res.StatusCode != 0 {
+			return errors.Errorf("post-install command exited with code %d", res.StatusCode)
+		}
+	}
+
+	ip.Server.Events().Publish(DaemonMessageEvent, "Post-install command completed.")
+	return nil
+}
+
 // StreamOutput streams the output of the installation process to a log file in
 // the server configuration directory, as well as to a websocket listener so
 // that the process can be viewed in the panel by administrators.
@@ -557,6 +771,14 @@ func (ip *InstallationProcess) resourceLimits() container.Resources {
 	} else if cfg.CpuLimit != 0 && cfg.CpuLimit < limits.Cpu {
 		cfg.CpuLimit = limits.Cpu
 	}
+	// Finally, cap the limits to the node maximums so that servers with large or unlimited
+	// resources cannot starve the rest of the node while installing.
+	if limits.MaxMemory > 0 && (cfg.MemoryLimit == 0 || cfg.MemoryLimit > limits.MaxMemory) {
+		cfg.MemoryLimit = limits.MaxMemory
+	}
+	if limits.MaxCpu > 0 && (cfg.CpuLimit == 0 || cfg.CpuLimit > limits.MaxCpu) {
+		cfg.CpuLimit = limits.MaxCpu
+	}
 
 	resources := cfg.AsContainerResources()
 	// Explicitly remove the PID limits for the installation container. These scripts are
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/docker/docker/api/types/container"
+	"github.com/goccy/go-json"
+
+	"github.com/pelican-dev/wings/environment/docker"
+	"github.com/pelican-dev/wings/system"
+)
+
+// installUsage tracks the resources consumed by the installation container for a
+// server so that they can be included in the installation log.
+type installUsage struct {
+	mu          sync.Mutex
+	started     time.Time
+	finished    time.Time
+	peakMemory  uint64
+	memoryLimit uint64
+	peakCpu     float64
+	totalCpu    float64
+	samples     int
+}
+
+// trackUsage reads the resource stats for the installation container until it has
+// stopped running, recording the peak and average usage.
+func (ip *InstallationProcess) trackUsage(ctx context.Context, id string) {
+	ip.usage.mu.Lock()
+	ip.usage.started = time.Now()
+	ip.usage.mu.Unlock()
+	defer func() {
+		ip.usage.mu.Lock()
+		ip.usage.finished = time.Now()
+		ip.usage.mu.Unlock()
+	}()
+
+	stats, err := ip.client.ContainerStats(ctx, id, true)
+	if err != nil {
+		ip.Server.Log().WithField("error", err).Warn("failed to read install container resource usage")
+		return
+	}
+	defer stats.Body.Close()
+
+	dec := json.NewDecoder(stats.Body)
+	for {
+		var v container.StatsResponse
+		if err := dec.Decode(&v); err != nil {
+			if err != io.EOF && !errors.Is(err, context.Canceled) {
+				ip.Server.Log().WithField("error", err).Debug("error while processing install container resource usage")
+			}
+			return
+		}
+		// Docker continues to send empty samples once the container has stopped.
+		if v.Read.IsZero() {
+			return
+		}
+		memory, cpu := docker.CalculateUsage(v)
+		ip.usage.mu.Lock()
+		ip.usage.peakMemory = max(ip.usage.peakMemory, memory)
+		ip.usage.memoryLimit = v.MemoryStats.Limit
+		ip.usage.peakCpu = max(ip.usage.peakCpu, cpu)
+		ip.usage.totalCpu += cpu
+		ip.usage.samples++
+		ip.usage.mu.Unlock()
+	}
+}
+
+// String returns the resource usage formatted as a section of the installation log.
+func (u *installUsage) String() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	out := "\n|\n| Resource Usage\n| ------------------------------\n"
+	if u.samples == 0 {
+		return out + "  No resource usage was recorded for the installation container.\n"
+	}
+	end := u.finished
+	if end.IsZero() {
+		end = time.Now()
+	}
+	out += fmt.Sprintf("  Duration:     %s\n", end.Sub(u.started).Round(time.Second))
+	out += fmt.Sprintf("  Peak Memory:  %s", system.FormatBytes(u.peakMemory))
+	if u.memoryLimit > 0 {
+		out += fmt.Sprintf(" of %s", system.FormatBytes(u.memoryLimit))
+	}
+	out += "\n"
+	out += fmt.Sprintf("  Peak CPU:     %.2f%%\n", u.peakCpu)
+	out += fmt.Sprintf("  Average CPU:  %.2f%%\n", u.totalCpu/float64(u.samples))
+	return out
+}
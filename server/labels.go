@@ -0,0 +1,101 @@
+package server
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+
+	"github.com/pelican-dev/wings/config"
+)
+
+// The maximum number of labels, including those defined by the Panel, that will
+// be applied to a server container.
+const maxContainerLabels = 64
+
+// Docker label keys should be lowercase alphanumeric characters separated by
+// periods, dashes or underscores, and must start and end with an alphanumeric
+// character.
+var labelKeyRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]*[a-z0-9])?$`)
+
+// Characters in a server name that are not in this set are replaced before the
+// name is used in a label. The name is controlled by users, and characters such
+// as backticks or parentheses would otherwise allow them to inject additional
+// rules into tools that parse label values, such as Traefik router rules.
+var labelUnsafeNameRegex = regexp.MustCompile(`[^A-Za-z0-9 ._-]`)
+
+// Label namespaces which are reserved by Docker and may not be used.
+var reservedLabelPrefixes = []string{"com.docker.", "io.docker.", "org.dockerproject."}
+
+// Labels which are set by Wings on every container and cannot be overridden.
+var systemLabels = []string{"Service", "ContainerType"}
+
+// containerLabels returns the labels that should be applied to the container for
+// this server. The labels provided by the Panel for the server are always used,
+// and the labels defined in the Wings configuration are rendered using the
+// server's details and then added alongside them.
+//
+// Configured labels with an invalid key, or that would override a label set by
+// the Panel, are skipped. Once the maximum number of labels has been reached any
+// remaining configured labels are dropped.
+func (s *Server) containerLabels() map[string]string {
+	cfg := s.Config()
+	templates := config.Get().Docker.Labels
+
+	labels := make(map[string]string, len(templates)+len(cfg.Labels))
+	for k, v := range cfg.Labels {
+		labels[k] = v
+	}
+	if len(templates) == 0 {
+		return labels
+	}
+
+	r := strings.NewReplacer(
+		"{{server.uuid}}", cfg.Uuid,
+		"{{server.name}}", sanitizeLabelName(cfg.Meta.Name),
+		"{{server.allocation.ip}}", cfg.Allocations.DefaultMapping.Ip,
+		"{{server.allocation.port}}", strconv.Itoa(cfg.Allocations.DefaultMapping.Port),
+	)
+	for k, v := range templates {
+		key := r.Replace(k)
+		if err := validateLabelKey(key); err != nil {
+			s.Log().WithField("label", key).WithField("error", err).Warn("skipping invalid container label")
+			continue
+		}
+		if _, ok := labels[key]; ok {
+			continue
+		}
+		if len(labels) >= maxContainerLabels {
+			s.Log().WithField("label", key).Warn("skipping container label: maximum number of labels reached")
+			continue
+		}
+		labels[key] = r.Replace(v)
+	}
+	return labels
+}
+
+// Replaces any characters in the server name that could change the meaning of
+// a label value when it is parsed by another tool.
+func sanitizeLabelName(name string) string {
+	return labelUnsafeNameRegex.ReplaceAllString(name, "-")
+}
+
+// Checks that the label key is valid and does not use a namespace reserved by
+// either Wings or Docker.
+func validateLabelKey(key string) error {
+	for _, l := range systemLabels {
+		if key == l {
+			return errors.New("label key is reserved by wings")
+		}
+	}
+	if !labelKeyRegex.MatchString(key) {
+		return errors.New("label key contains invalid characters")
+	}
+	for _, p := range reservedLabelPrefixes {
+		if strings.HasPrefix(key, p) {
+			return errors.New("label key uses a namespace reserved by docker")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package server
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestSanitizeLabelName(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("sanitizeLabelName", func() {
+		g.It("leaves safe server names untouched", func() {
+			g.Assert(sanitizeLabelName("My Server_1.0-beta")).Equal("My Server_1.0-beta")
+		})
+
+		g.It("replaces characters that could inject label rules", func() {
+			g.Assert(sanitizeLabelName("a`) || Host(`evil.com")).Equal("a-- -- Host--evil.com")
+			g.Assert(sanitizeLabelName("name,other=value")).Equal("name-other-value")
+		})
+	})
+}
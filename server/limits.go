@@ -0,0 +1,91 @@
+package server
+
+import (
+	"github.com/docker/go-connections/nat"
+
+	"github.com/pelican-dev/wings/environment"
+)
+
+// EnforcedLimits describes the resource limits and allocations of a server as
+// they are configured on this node, along with the values that are actually
+// applied to the server's container after any overhead has been added.
+type EnforcedLimits struct {
+	// The build settings for the server as they were received from the Panel.
+	Build environment.Limits `json:"build"`
+	// The limits applied to the server's container by Docker.
+	Container ContainerLimits `json:"container"`
+	// The disk space limit in bytes enforced by the server's filesystem. A value
+	// of zero means that the disk space is unlimited.
+	DiskBytes int64 `json:"disk_bytes"`
+	// The allocations assigned to the server, and the resulting port bindings for
+	// the container.
+	Allocations environment.Allocations `json:"allocations"`
+	Bindings    nat.PortMap             `json:"bindings"`
+}
+
+// ContainerLimits are the resource limits that are applied to a server container.
+type ContainerLimits struct {
+	MemoryBytes            int64  `json:"memory_bytes"`
+	MemoryReservationBytes int64  `json:"memory_reservation_bytes"`
+	MemorySwapBytes        int64  `json:"memory_swap_bytes"`
+	CpuQuota               int64  `json:"cpu_quota"`
+	CpuPeriod              int64  `json:"cpu_period"`
+	CpusetCpus             string `json:"cpuset_cpus"`
+	PidsLimit              int64  `json:"pids_limit"`
+	IoWeight               uint16 `json:"io_weight"`
+	IoReadBps              uint64 `json:"io_read_bps"`
+	IoWriteBps             uint64 `json:"io_write_bps"`
+	IoReadIops             uint64 `json:"io_read_iops"`
+	IoWriteIops            uint64 `json:"io_write_iops"`
+	OomKillDisabled        bool   `json:"oom_kill_disabled"`
+	OomScoreAdj            int    `json:"oom_score_adj"`
+	BandwidthIngressMbps   uint64 `json:"bandwidth_ingress_mbps"`
+	BandwidthEgressMbps    uint64 `json:"bandwidth_egress_mbps"`
+}
+
+// EnforcedLimits returns the resource limits and allocations for the server as
+// they will be enforced by Wings.
+func (s *Server) EnforcedLimits() EnforcedLimits {
+	c := s.Config()
+	build := c.Build
+	allocations := c.Allocations
+
+	r := build.AsContainerResources()
+	limits := ContainerLimits{
+		MemoryBytes:            r.Memory,
+		MemoryReservationBytes: r.MemoryReservation,
+		MemorySwapBytes:        r.MemorySwap,
+		CpuQuota:               r.CPUQuota,
+		CpuPeriod:              r.CPUPeriod,
+		CpusetCpus:             r.CpusetCpus,
+		IoWeight:               r.BlkioWeight,
+		OomScoreAdj:            build.OomScoreAdjustment(),
+	}
+	if len(r.BlkioDeviceReadBps) > 0 {
+		limits.IoReadBps = r.BlkioDeviceReadBps[0].Rate
+	}
+	if len(r.BlkioDeviceWriteBps) > 0 {
+		limits.IoWriteBps = r.BlkioDeviceWriteBps[0].Rate
+	}
+	if len(r.BlkioDeviceReadIOps) > 0 {
+		limits.IoReadIops = r.BlkioDeviceReadIOps[0].Rate
+	}
+	if len(r.BlkioDeviceWriteIOps) > 0 {
+		limits.IoWriteIops = r.BlkioDeviceWriteIOps[0].Rate
+	}
+	if r.PidsLimit != nil {
+		limits.PidsLimit = *r.PidsLimit
+	}
+	if r.OomKillDisable != nil {
+		limits.OomKillDisabled = *r.OomKillDisable
+	}
+	limits.BandwidthIngressMbps, limits.BandwidthEgressMbps = build.Bandwidth()
+
+	return EnforcedLimits{
+		Build:       build,
+		Container:   limits,
+		DiskBytes:   s.Filesystem().MaxDisk(),
+		Allocations: allocations,
+		Bindings:    allocations.DockerBindings(),
+	}
+}
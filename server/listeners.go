@@ -2,6 +2,7 @@ package server
 
 import (
 	"bytes"
+	"fmt"
 	"regexp"
 	"strconv"
 	"sync"
@@ -9,7 +10,9 @@ import (
 
 	"github.com/apex/log"
 
+	"github.com/pelican-dev/wings/config"
 	"github.com/pelican-dev/wings/events"
+	"github.com/pelican-dev/wings/internal/logforward"
 	"github.com/pelican-dev/wings/system"
 
 	"github.com/pelican-dev/wings/environment"
@@ -18,36 +21,70 @@ import (
 
 var dockerEvents = []string{
 	environment.DockerImagePullStatus,
+	environment.DockerImagePullProgress,
 	environment.DockerImagePullStarted,
 	environment.DockerImagePullCompleted,
+	environment.DockerImagePullFailed,
 }
 
 type diskSpaceLimiter struct {
 	o      sync.Once
 	mu     sync.Mutex
 	server *Server
+	grace  int
+	// The number of consecutive samples the server has exceeded its disk space limit.
+	over int
 }
 
 func newDiskLimiter(s *Server) *diskSpaceLimiter {
-	return &diskSpaceLimiter{server: s}
+	return &diskSpaceLimiter{server: s, grace: max(config.Get().System.DiskLimiterGraceSamples, 0)}
 }
 
 // Reset the disk space limiter status.
 func (dsl *diskSpaceLimiter) Reset() {
 	dsl.mu.Lock()
 	dsl.o = sync.Once{}
+	dsl.over = 0
 	dsl.mu.Unlock()
 }
 
+// Observe records a resource sample for the server, triggering the limiter once the
+// server has exceeded its disk space limit for more consecutive samples than the
+// configured grace period allows. A warning is sent to the console when the server
+// first exceeds the limit during the grace period.
+func (dsl *diskSpaceLimiter) Observe(hasSpace bool) {
+	dsl.mu.Lock()
+	if hasSpace {
+		if dsl.over > 0 && dsl.over <= dsl.grace {
+			dsl.server.PublishConsoleOutputFromDaemon("Server disk usage is back within the assigned disk space limit.")
+		}
+		dsl.over = 0
+		dsl.mu.Unlock()
+		return
+	}
+	dsl.over++
+	over := dsl.over
+	dsl.mu.Unlock()
+
+	if over <= dsl.grace {
+		if over == 1 {
+			dsl.server.PublishConsoleOutputFromDaemon(fmt.Sprintf("Server is exceeding the assigned disk space limit, the process will be stopped if it does not drop below the limit within %d samples.", dsl.grace))
+		}
+		return
+	}
+	dsl.Trigger()
+}
+
 // Trigger the disk space limiter which will attempt to stop a running server instance within
-// 15 seconds, and terminate it forcefully if it does not stop.
+// the egg defined stop grace period (or one minute if not set), and terminate it forcefully if
+// it does not stop.
 //
 // This function is only executed one time, so whenever a server is marked as booting the limiter
 // should be reset, so it can properly be triggered as needed.
 func (dsl *diskSpaceLimiter) Trigger() {
 	dsl.o.Do(func() {
 		dsl.server.PublishConsoleOutputFromDaemon("Server is exceeding the assigned disk space limit, stopping process now.")
-		if err := dsl.server.Environment.WaitForStop(dsl.server.Context(), time.Minute, true); err != nil {
+		if err := dsl.server.Environment.WaitForStop(dsl.server.Context(), dsl.server.StopGracePeriod(time.Minute), true); err != nil {
 			dsl.server.Log().WithField("error", err).Error("failed to stop server after exceeding space limit!")
 		}
 	})
@@ -76,6 +113,7 @@ func (s *Server) processConsoleOutputEvent(v []byte) {
 	}
 
 	s.Sink(system.LogSink).Push(v)
+	logforward.Push(s.ID(), v)
 }
 
 // StartEventListeners adds all the internal event listeners we want to use for
@@ -84,6 +122,8 @@ func (s *Server) processConsoleOutputEvent(v []byte) {
 func (s *Server) StartEventListeners() {
 	c := make(chan []byte, 8)
 	limit := newDiskLimiter(s)
+	alerts := newDiskUsageAlerter(config.Get().System.DiskUsageAlertThresholds)
+	pids := newPidsLimiter(s, config.Get().System.StopOnPidsLimit)
 
 	s.Log().Debug("registering event listeners: console, state, resources...")
 	s.Environment.Events().On(c)
@@ -110,11 +150,11 @@ func (s *Server) StartEventListeners() {
 								return
 							}
 							s.resources.UpdateStats(stats.Data)
-							// If there is no disk space available at this point, trigger the server
-							// disk limiter logic which will start to stop the running instance.
-							if !s.Filesystem().HasSpaceAvailable(true) {
-								limit.Trigger()
-							}
+							// If there is no disk space available at this point, the disk limiter will
+							// stop the running instance once the grace period has been exceeded.
+							limit.Observe(s.Filesystem().HasSpaceAvailable(true))
+							s.checkDiskUsageAlerts(alerts)
+							pids.Observe(stats.Data.Pids, stats.Data.PidsLimit)
 							s.Events().Publish(StatsEvent, s.Proc())
 						}
 					case environment.StateChangeEvent:
@@ -122,16 +162,25 @@ func (s *Server) StartEventListeners() {
 							// Reset the throttler when the process is started.
 							if e.Data == environment.ProcessStartingState {
 								limit.Reset()
+								pids.Reset()
 								s.Throttler().Reset()
 							}
 							s.OnStateChange()
 						}
 					case environment.DockerImagePullStatus:
 						s.Events().Publish(InstallOutputEvent, e.Data)
+					case environment.DockerImagePullProgress:
+						s.Events().Publish(ImagePullProgressEvent, e.Data)
 					case environment.DockerImagePullStarted:
 						s.PublishConsoleOutputFromDaemon("Pulling Docker container image, this could take a few minutes to complete...")
 					case environment.DockerImagePullCompleted:
 						s.PublishConsoleOutputFromDaemon("Finished pulling Docker container image")
+					case environment.DockerImagePullFailed:
+						if msg, ok := e.Data.(string); ok {
+							s.PublishConsoleOutputFromDaemon(msg)
+						}
+					case environment.StopGracePeriodExpired:
+						s.PublishConsoleOutputFromDaemon(fmt.Sprintf("Server did not stop within the %v grace period, sending SIGKILL to terminate the process.", e.Data))
 					default:
 					}
 				}(v, limit)
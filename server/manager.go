@@ -18,6 +18,7 @@ import (
 	"github.com/pelican-dev/wings/config"
 	"github.com/pelican-dev/wings/environment"
 	"github.com/pelican-dev/wings/environment/docker"
+	"github.com/pelican-dev/wings/events"
 	"github.com/pelican-dev/wings/remote"
 	"github.com/pelican-dev/wings/server/filesystem"
 )
@@ -26,6 +27,7 @@ type Manager struct {
 	mu      sync.RWMutex
 	client  remote.Client
 	servers []*Server
+	events  *events.Bus
 }
 
 // NewManager returns a new server manager instance. This will boot up all the
@@ -43,7 +45,13 @@ func NewManager(ctx context.Context, client remote.Client) (*Manager, error) {
 // loading any of the servers from the disk. This allows the caller to set their
 // own servers into the collection as needed.
 func NewEmptyManager(client remote.Client) *Manager {
-	return &Manager{client: client}
+	return &Manager{client: client, events: events.NewBus()}
+}
+
+// Events returns the event bus for the node, which lifecycle events for all the
+// servers in the manager are published to.
+func (m *Manager) Events() *events.Bus {
+	return m.events
 }
 
 // Client returns the HTTP client interface that allows interaction with the
@@ -87,9 +95,11 @@ func (m *Manager) All() []*Server {
 
 // Add adds an item to the collection store.
 func (m *Manager) Add(s *Server) {
+	s.setNodeEvents(m.events)
 	m.mu.Lock()
 	m.servers = append(m.servers, s)
 	m.mu.Unlock()
+	m.events.Publish(ServerCreatedEvent, NodeEvent{Server: s.ID()})
 }
 
 // Get returns a single server instance and a boolean value indicating if it was
@@ -130,14 +140,20 @@ func (m *Manager) Find(filter func(match *Server) bool) *Server {
 // Remove removes all items from the collection that match the filter function.
 func (m *Manager) Remove(filter func(match *Server) bool) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 	r := make([]*Server, 0)
+	var removed []*Server
 	for _, v := range m.servers {
 		if !filter(v) {
 			r = append(r, v)
+		} else {
+			removed = append(removed, v)
 		}
 	}
 	m.servers = r
+	m.mu.Unlock()
+	for _, v := range removed {
+		m.events.Publish(ServerDeletedEvent, NodeEvent{Server: v.ID()})
+	}
 }
 
 // PersistStates writes the current environment states to the disk for each
@@ -208,7 +224,7 @@ func (m *Manager) InitServer(data remote.ServerConfigurationResponse) (*Server,
 		Mounts:      s.Mounts(),
 		Allocations: s.cfg.Allocations,
 		Limits:      s.cfg.Build,
-		Labels:      s.cfg.Labels,
+		Labels:      s.containerLabels(),
 	}
 
 	envCfg := environment.NewConfiguration(settings, s.GetEnvironmentVariables())
@@ -223,9 +239,15 @@ func (m *Manager) InitServer(data remote.ServerConfigurationResponse) (*Server,
 		s.StartEventListeners()
 	}
 
-	// If the server's data directory exists, force disk usage calculation.
+	// If the server's data directory exists, remove any partial uploads left behind
+	// when Wings was stopped and then force disk usage calculation.
 	if _, err := os.Stat(s.Filesystem().Path()); err == nil {
-		s.Filesystem().HasSpaceAvailable(true)
+		go func(s *Server) {
+			if err := s.Filesystem().RemoveStaleUploads(); err != nil {
+				s.Log().WithField("error", err).Warn("failed to remove stale uploads from server data directory")
+			}
+			s.Filesystem().HasSpaceAvailable(true)
+		}(s)
 	}
 
 	return s, nil
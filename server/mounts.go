@@ -19,7 +19,7 @@ type Mount environment.Mount
 // Returns the default container mounts for the server instance. This includes the data directory
 // for the server. Previously this would also mount in host timezone files, however we've moved from
 // that approach to just setting `TZ=Timezone` environment values in containers which should work
-// in most scenarios.
+// in most scenarios. Eggs that need the timezone files can still opt into having them mounted.
 func (s *Server) Mounts() []environment.Mount {
 	m := []environment.Mount{
 		{
@@ -40,10 +40,39 @@ func (s *Server) Mounts() []environment.Mount {
 
 		m = append(m, passwdMount)
 	}
+
+	if s.Config().Egg.MountTimezoneData {
+		m = append(m, timezoneMounts(s.Log())...)
+	}
+
 	// Also include any of this server's custom mounts when returning them.
 	return append(m, s.customMounts()...)
 }
 
+// The host paths containing timezone data that are mounted into containers for
+// eggs that require them. Each path is mounted at the same location inside the
+// container.
+var timezoneMountPaths = []string{"/etc/localtime", "/usr/share/zoneinfo"}
+
+// Returns read-only mounts for the timezone data on the host system. Any of the
+// paths that do not exist on the host are skipped.
+func timezoneMounts(logger *log.Entry) []environment.Mount {
+	var mounts []environment.Mount
+	for _, source := range timezoneMountPaths {
+		if _, err := os.Stat(source); err != nil {
+			logger.WithField("source_path", source).WithField("error", err).Warn("skipping timezone data mount, source path is not accessible")
+			continue
+		}
+		mounts = append(mounts, environment.Mount{
+			Default:  true,
+			Target:   source,
+			Source:   source,
+			ReadOnly: true,
+		})
+	}
+	return mounts
+}
+
 // Returns the custom mounts for a given server after verifying that they are within a list of
 // allowed mount points for the node.
 func (s *Server) customMounts() []environment.Mount {
@@ -71,6 +100,11 @@ func (s *Server) customMounts() []environment.Mount {
 			continue
 		}
 
+		if denied := deniedMountSource(source); denied != "" {
+			logger.WithField("denied_path", denied).Error("refusing to mount protected host path into server container: check the allowed_mounts configuration")
+			continue
+		}
+
 		mounted := false
 		for _, allowed := range config.Get().AllowedMounts {
 			// Check if the source path is included in the allowed mounts list.
@@ -96,3 +130,64 @@ func (s *Server) customMounts() []environment.Mount {
 
 	return mounts
 }
+
+// Host paths that can never be used as the source of a custom mount, regardless
+// of the allowed mounts configured for the node.
+var protectedMountPaths = []string{
+	"/",
+	"/boot",
+	"/dev",
+	"/etc",
+	"/proc",
+	"/root",
+	"/run/docker.sock",
+	"/sys",
+	"/var/lib/docker",
+	"/var/run/docker.sock",
+}
+
+// Returns the protected path that prevents the given source from being mounted,
+// or an empty string if the source can be mounted. A source is denied if it is
+// a protected path, is inside a protected path, or contains a protected path
+// such as the Wings data directory.
+func deniedMountSource(source string) string {
+	cfg := config.Get()
+	// The root directory itself is not included here, as it is common to have an
+	// allowed mount point within it. Since the data directories are all within the
+	// root directory by default, mounting the root directory is still denied.
+	paths := append([]string{
+		cfg.System.Data,
+		cfg.System.ArchiveDirectory,
+		cfg.System.BackupDirectory,
+		cfg.System.LogDirectory,
+	}, protectedMountPaths...)
+	paths = append(paths, cfg.DeniedMounts...)
+
+	// Resolve any symlinks in the source path so that a link pointing to one of
+	// the protected paths cannot be used to bypass this check.
+	sources := []string{source}
+	if resolved, err := filepath.EvalSymlinks(source); err == nil && resolved != source {
+		sources = append(sources, resolved)
+	}
+
+	for _, src := range sources {
+		for _, p := range paths {
+			if p == "" {
+				continue
+			}
+			p = filepath.Clean(p)
+			if src == p || isWithinPath(p, src) || (p != "/" && isWithinPath(src, p)) {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// Returns true if the path is inside the given parent directory.
+func isWithinPath(path, parent string) bool {
+	if parent == "/" {
+		return path != "/"
+	}
+	return strings.HasPrefix(path, parent+"/")
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PidsLimit is the data sent along with a PidsLimitEvent when a server reaches
+// the maximum number of processes that may run in its container.
+type PidsLimit struct {
+	Current uint64 `json:"current"`
+	Limit   uint64 `json:"limit"`
+}
+
+// pidsLimiter tracks if a server's container has reached its process limit so
+// that the server is only warned once each time the limit is reached, rather
+// than on every resource sample while it remains there.
+type pidsLimiter struct {
+	o      sync.Once
+	mu     sync.Mutex
+	server *Server
+	stop   bool
+	// Set while the container is at its process limit.
+	reached bool
+}
+
+func newPidsLimiter(s *Server, stop bool) *pidsLimiter {
+	return &pidsLimiter{server: s, stop: stop}
+}
+
+// Reset the process limiter status.
+func (pl *pidsLimiter) Reset() {
+	pl.mu.Lock()
+	pl.o = sync.Once{}
+	pl.reached = false
+	pl.mu.Unlock()
+}
+
+// Check records the number of processes running in the container and returns
+// true if the container has just reached its process limit.
+func (pl *pidsLimiter) Check(current, limit uint64) bool {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if limit == 0 || current < limit {
+		pl.reached = false
+		return false
+	}
+	if pl.reached {
+		return false
+	}
+	pl.reached = true
+	return true
+}
+
+// Observe records a resource sample for the server. When the container reaches
+// its process limit an event is published and a warning is sent to the console,
+// and if configured to do so the server is stopped within the egg defined stop
+// grace period (or one minute if not set), and terminated forcefully if it does
+// not stop.
+func (pl *pidsLimiter) Observe(current, limit uint64) {
+	if !pl.Check(current, limit) {
+		return
+	}
+	s := pl.server
+	s.Log().WithField("pids", current).WithField("limit", limit).Warn("server has reached its process limit")
+	s.Events().Publish(PidsLimitEvent, PidsLimit{Current: current, Limit: limit})
+	if !pl.stop {
+		s.PublishConsoleOutputFromDaemon(fmt.Sprintf("Server has reached its limit of %d processes, new processes and threads cannot be started until some exit.", limit))
+		return
+	}
+	pl.o.Do(func() {
+		s.PublishConsoleOutputFromDaemon(fmt.Sprintf("Server has reached its limit of %d processes, stopping process now.", limit))
+		if err := s.Environment.WaitForStop(s.Context(), s.StopGracePeriod(time.Minute), true); err != nil {
+			s.Log().WithField("error", err).Error("failed to stop server after reaching process limit!")
+		}
+	})
+}
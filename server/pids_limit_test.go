@@ -0,0 +1,37 @@
+package server
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestPidsLimiter(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("pidsLimiter#Check", func() {
+		g.It("reports the limit once each time it is reached", func() {
+			pl := newPidsLimiter(nil, false)
+
+			g.Assert(pl.Check(10, 512)).IsFalse()
+			g.Assert(pl.Check(512, 512)).IsTrue()
+			g.Assert(pl.Check(512, 512)).IsFalse()
+			g.Assert(pl.Check(500, 512)).IsFalse()
+			g.Assert(pl.Check(512, 512)).IsTrue()
+		})
+
+		g.It("ignores containers without a limit", func() {
+			pl := newPidsLimiter(nil, false)
+
+			g.Assert(pl.Check(10000, 0)).IsFalse()
+		})
+
+		g.It("reports the limit again after being reset", func() {
+			pl := newPidsLimiter(nil, false)
+
+			g.Assert(pl.Check(512, 512)).IsTrue()
+			pl.Reset()
+			g.Assert(pl.Check(512, 512)).IsTrue()
+		})
+	})
+}
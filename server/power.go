@@ -10,6 +10,7 @@ import (
 
 	"github.com/pelican-dev/wings/config"
 	"github.com/pelican-dev/wings/environment"
+	"github.com/pelican-dev/wings/internal/models"
 )
 
 type PowerAction string
@@ -53,7 +54,33 @@ func (s *Server) ExecutingPowerAction() bool {
 // However, the code design for the daemon does depend on the user correctly calling this
 // function rather than making direct calls to the start/stop/restart functions on the
 // environment struct.
+//
+// The outcome of every power action is recorded in the local server history so that it
+// can be included in diagnostics reports.
 func (s *Server) HandlePowerAction(action PowerAction, waitSeconds ...int) error {
+	return s.recordPowerAction(action, s.handlePowerAction(action, false, waitSeconds...))
+}
+
+// HandleSafeModePowerAction is the same as HandlePowerAction, except that if the action
+// boots the server the configuration files defined by the egg are not updated, so that
+// the server starts with the files exactly as they are on the disk. This only applies
+// to the boot performed by this action.
+func (s *Server) HandleSafeModePowerAction(action PowerAction, waitSeconds ...int) error {
+	return s.recordPowerAction(action, s.handlePowerAction(action, true, waitSeconds...))
+}
+
+// recordPowerAction records the outcome of a power action in the local server history
+// and returns the error it was given.
+func (s *Server) recordPowerAction(action PowerAction, err error) error {
+	meta := models.ActivityMeta{}
+	if err != nil {
+		meta["error"] = err.Error()
+	}
+	s.recordHistory(models.Event(ActivityPowerPrefix+string(action)), meta)
+	return err
+}
+
+func (s *Server) handlePowerAction(action PowerAction, safeMode bool, waitSeconds ...int) error {
 	if s.IsInstalling() || s.IsTransferring() || s.IsRestoring() {
 		if s.IsRestoring() {
 			return ErrServerIsRestoring
@@ -63,6 +90,19 @@ func (s *Server) HandlePowerAction(action PowerAction, waitSeconds ...int) error
 		return ErrServerIsInstalling
 	}
 
+	if action.IsStart() && IsDraining() {
+		return ErrNodeDraining
+	}
+
+	// Don't allow the server to be booted if the data directory has gone missing, otherwise
+	// the container will be started against an empty directory. Stopping or killing the
+	// server is still permitted.
+	if action.IsStart() {
+		if err := s.CheckDataDirectory(); err != nil {
+			return err
+		}
+	}
+
 	lockId, _ := uuid.NewUUID()
 	log := s.Log().WithField("lock_id", lockId.String()).WithField("action", action)
 
@@ -127,7 +167,7 @@ func (s *Server) HandlePowerAction(action PowerAction, waitSeconds ...int) error
 		}
 
 		// Run the pre-boot logic for the server before processing the environment start.
-		if err := s.onBeforeStart(); err != nil {
+		if err := s.onBeforeStart(safeMode); err != nil {
 			return err
 		}
 
@@ -137,7 +177,7 @@ func (s *Server) HandlePowerAction(action PowerAction, waitSeconds ...int) error
 	case PowerActionRestart:
 		// We're specifically waiting for the process to be stopped here, otherwise the lock is
 		// released too soon, and you can rack up all sorts of issues.
-		if err := s.Environment.WaitForStop(s.Context(), time.Minute*10, true); err != nil {
+		if err := s.Environment.WaitForStop(s.Context(), s.StopGracePeriod(time.Minute*10), true); err != nil {
 			// Even timeout errors should be bubbled back up the stack. If the process didn't stop
 			// nicely, but the terminate argument was passed then the server is stopped without an
 			// error being returned.
@@ -154,7 +194,7 @@ func (s *Server) HandlePowerAction(action PowerAction, waitSeconds ...int) error
 		}
 
 		// Now actually try to start the process by executing the normal pre-boot logic.
-		if err := s.onBeforeStart(); err != nil {
+		if err := s.onBeforeStart(safeMode); err != nil {
 			return err
 		}
 
@@ -166,14 +206,30 @@ func (s *Server) HandlePowerAction(action PowerAction, waitSeconds ...int) error
 	return errors.New("attempting to handle unknown power action")
 }
 
+// StopGracePeriod returns the amount of time a server process is given to stop
+// on its own before it is forcefully terminated with a SIGKILL. This is defined
+// by the egg stop configuration, and falls back to the provided duration if the
+// egg does not define one.
+func (s *Server) StopGracePeriod(fallback time.Duration) time.Duration {
+	if pc := s.ProcessConfiguration(); pc != nil && pc.Stop.GracePeriod > 0 {
+		return time.Duration(pc.Stop.GracePeriod) * time.Second
+	}
+	return fallback
+}
+
 // Execute a few functions before actually calling the environment start commands. This ensures
 // that everything is ready to go for environment booting, and that the server can even be started.
-func (s *Server) onBeforeStart() error {
+// If safeMode is true the configuration files defined by the egg are left untouched.
+func (s *Server) onBeforeStart(safeMode bool) error {
 	s.Log().Info("syncing server configuration with panel")
 	if err := s.Sync(); err != nil {
 		return errors.WithMessage(err, "unable to sync server data from Panel instance")
 	}
 
+	for _, l := range s.InvalidDoneLines() {
+		s.PublishConsoleOutputFromDaemon(fmt.Sprintf("Ignoring invalid startup detection pattern %q: %s", l.Line, l.Error))
+	}
+
 	// Disallow start & restart if the server is suspended. Do this check after performing a sync
 	// action with the Panel to ensure that we have the most up-to-date information for that server.
 	if s.IsSuspended() {
@@ -200,10 +256,18 @@ func (s *Server) onBeforeStart() error {
 	// is complete. Any errors as a result of this will just be bubbled out in the logger,
 	// we don't need to actively do anything about it at this point, worse comes to worst the
 	// server starts in a weird state and the user can manually adjust.
-	s.PublishConsoleOutputFromDaemon("Updating process configuration files...")
-	s.Log().Debug("updating server configuration files...")
-	s.UpdateConfigurationFiles()
-	s.Log().Debug("updated server configuration files")
+	//
+	// When booting in safe mode this is skipped so that the server starts with the
+	// configuration files exactly as they are on the disk.
+	if safeMode {
+		s.PublishConsoleOutputFromDaemon("Starting in safe mode, skipping updates to process configuration files.")
+		s.Log().Info("skipping server configuration file updates for safe mode boot")
+	} else {
+		s.PublishConsoleOutputFromDaemon("Updating process configuration files...")
+		s.Log().Debug("updating server configuration files...")
+		s.UpdateConfigurationFiles()
+		s.Log().Debug("updated server configuration files")
+	}
 
 	if config.Get().System.CheckPermissionsOnBoot {
 		s.PublishConsoleOutputFromDaemon("Ensuring file permissions are set correctly, this could take a few seconds...")
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"os"
+
+	"emperror.dev/errors"
+	"gorm.io/gorm"
+
+	"github.com/pelican-dev/wings/config"
+	"github.com/pelican-dev/wings/internal/database"
+	"github.com/pelican-dev/wings/internal/models"
+	"github.com/pelican-dev/wings/server/filesystem"
+)
+
+// projectID returns the filesystem project ID used for the server's project
+// quota. IDs are allocated sequentially the first time they are needed and then
+// stored in the local database, so that a server keeps the same ID between
+// restarts of Wings and no two servers ever share the same quota.
+func (s *Server) projectID() (uint32, error) {
+	var pq models.ProjectQuota
+	err := database.Instance().Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("server = ?", s.ID()).Take(&pq).Error
+		if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		// Project ID 0 is the default project that all files belong to, so the first
+		// server is always given an ID of 1.
+		var last uint32
+		if err := tx.Model(&models.ProjectQuota{}).Select("COALESCE(MAX(project_id), 0)").Scan(&last).Error; err != nil {
+			return err
+		}
+		if last == ^uint32(0) {
+			return errors.New("no project ids are available")
+		}
+		pq = models.ProjectQuota{Server: s.ID(), ProjectID: last + 1}
+		return tx.Create(&pq).Error
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "server: failed to allocate project id")
+	}
+	return pq.ProjectID, nil
+}
+
+// applyProjectQuota sets a project quota on the server's data directory that
+// matches its disk limit if project quotas are enabled. Any failure is logged and
+// the disk limiter continues to be used to enforce the limit instead.
+func (s *Server) applyProjectQuota() {
+	if !config.Get().System.ProjectQuotas {
+		return
+	}
+	id, err := s.projectID()
+	if err != nil {
+		s.Log().WithField("error", err).Warn("server: failed to apply project quota to data directory")
+		return
+	}
+	if err := s.fs.SetProjectQuota(id); err != nil {
+		// The quota is applied when the data directory is created.
+		if errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		if errors.Is(err, filesystem.ErrProjectQuotaUnsupported) {
+			s.Log().Debug("server: project quotas are not supported for the data directory, using disk limiter")
+			return
+		}
+		s.Log().WithField("error", err).Warn("server: failed to apply project quota to data directory")
+	}
+}
+
+// RemoveProjectQuota removes the limit from the project quota assigned to the
+// server and releases its project ID. This should be called when the server is
+// being deleted from the node, before its data directory is removed.
+func (s *Server) RemoveProjectQuota() {
+	var pq models.ProjectQuota
+	if err := database.Instance().Where("server = ?", s.ID()).Take(&pq).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.Log().WithField("error", err).Warn("server: failed to look up project quota for server")
+		}
+		return
+	}
+	if err := s.fs.RemoveProjectQuota(pq.ProjectID); err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, filesystem.ErrProjectQuotaUnsupported) {
+		s.Log().WithField("error", err).Warn("server: failed to remove project quota from data directory")
+	}
+	if err := database.Instance().Delete(&pq).Error; err != nil {
+		s.Log().WithField("error", err).Warn("server: failed to release project id for server")
+	}
+}
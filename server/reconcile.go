@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+
+	"github.com/pelican-dev/wings/environment"
+	"github.com/pelican-dev/wings/remote"
+)
+
+// ReconcileContainers compares the server containers that exist in Docker against
+// the servers tracked by this instance of Wings.
+//
+// Containers belonging to a server that no longer exists are removed, but only
+// once the Panel has confirmed that the server has been deleted. Containers that
+// are running for a known server that Wings believes to be offline are re-attached
+// so that their state and console output is tracked again.
+func (m *Manager) ReconcileContainers(ctx context.Context) error {
+	cli, err := environment.Docker()
+	if err != nil {
+		return err
+	}
+
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", "Service=Pelican"),
+			filters.Arg("label", "ContainerType=server_process"),
+		),
+	})
+	if err != nil {
+		return errors.Wrap(err, "server: failed to list server containers")
+	}
+
+	for _, c := range containers {
+		if len(c.Names) == 0 {
+			continue
+		}
+		id := strings.TrimPrefix(c.Names[0], "/")
+		l := log.WithFields(log.Fields{"subsystem": "reconcile", "container": id})
+
+		if s, ok := m.Get(id); ok {
+			if c.State != "running" || s.Environment.State() != environment.ProcessOfflineState || s.IsInstalling() || s.IsTransferring() || s.IsRestoring() {
+				continue
+			}
+			s.Log().Info("detected running container for server that is not being tracked, re-attaching to process...")
+			s.Environment.SetState(environment.ProcessRunningState)
+			if err := s.Environment.Attach(ctx); err != nil {
+				s.Log().WithField("error", err).Warn("failed to attach to running server environment")
+			}
+			continue
+		}
+
+		// Confirm with the Panel that the server has actually been deleted before
+		// removing the container, any other response leaves the container alone.
+		if _, err := m.client.GetServerConfiguration(ctx, id); err == nil {
+			l.Warn("found container for server that exists on the Panel but is not loaded by this instance, ignoring...")
+			continue
+		} else if rerr := remote.AsRequestError(err); rerr == nil || rerr.StatusCode() != http.StatusNotFound {
+			l.WithField("error", err).Warn("failed to confirm server state with the Panel, not removing container")
+			continue
+		}
+
+		l.Info("removing container for server that no longer exists")
+		if err := cli.ContainerRemove(ctx, c.ID, container.RemoveOptions{RemoveVolumes: true, Force: true}); err != nil && !client.IsErrNotFound(err) {
+			l.WithField("error", err).Error("failed to remove orphaned server container")
+		}
+	}
+
+	return nil
+}
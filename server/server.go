@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,6 +17,7 @@ import (
 	"github.com/apex/log"
 	"github.com/creasty/defaults"
 	"github.com/goccy/go-json"
+	"golang.org/x/time/rate"
 
 	"github.com/pelican-dev/wings/config"
 	"github.com/pelican-dev/wings/environment"
@@ -52,28 +54,43 @@ type Server struct {
 
 	// Events emitted by the server instance.
 	emitter *events.Bus
+	// The node event bus that lifecycle events for the server are published to,
+	// which is set once the server is added to a manager.
+	nodeEmitter *events.Bus
 
 	// Defines the process configuration for the server instance. This is dynamically
 	// fetched from the Pelican Server instance each time the server process is
 	// started, and then cached here.
 	procConfig *remote.ProcessConfiguration
+	// Any startup lines from the process configuration that could not be used
+	// because their regex is invalid.
+	invalidDoneLines []remote.InvalidOutputLineMatcher
 
 	// Tracks the installation process for this server and prevents a server from running
 	// two installer processes at the same time. This also allows us to cancel a running
 	// installation process, for example when a server is deleted from the panel while the
 	// installer process is still running.
-	installing   *system.AtomicBool
-	transferring *system.AtomicBool
-	restoring    *system.AtomicBool
+	installing    *system.AtomicBool
+	installCancel context.CancelFunc
+	transferring  *system.AtomicBool
+	restoring     *system.AtomicBool
 
 	// The console throttler instance used to control outputs.
 	throttler    *ConsoleThrottle
 	throttleOnce sync.Once
 
+	// Limits the rate at which commands can be sent to the server.
+	commandLimiter     *rate.Limiter
+	commandLimiterOnce sync.Once
+
 	// Tracks open websocket connections for the server.
 	wsBag       *WebsocketBag
 	wsBagLocker sync.Mutex
 
+	// Tracks the downloads and SFTP sessions that are currently open for the server.
+	downloads    sessionCounter
+	sftpSessions sessionCounter
+
 	sinks map[system.SinkName]*system.SinkPool
 
 	logSink     *system.SinkPool
@@ -208,14 +225,54 @@ func parseInvocation(invocation string, envvars map[string]interface{}, memory i
 }
 
 // Returns all of the environment variables that should be assigned to a running
-// server instance.
+// server instance. The following variables are always set by Wings and cannot be
+// overridden by the server's own variables:
+//
+//	TZ                  the timezone for the server
+//	STARTUP             the parsed startup command for the server
+//	SERVER_MEMORY       the memory limit for the server in MiB
+//	SERVER_IP           the IP address of the primary allocation
+//	SERVER_PORT         the port of the primary allocation
+//	SERVER_ALLOCATIONS  every allocation as a comma separated list of "ip:port"
+//	                    pairs, starting with the primary allocation
+//
+// Any node-wide container variables that the server does not define itself are
+// appended after the server's own variables.
 func (s *Server) GetEnvironmentVariables() []string {
+	out := s.serverEnvironmentVariables()
+
+	// Finally, apply any node-wide variables that have not been defined by the server
+	// itself, sorted so that the container environment remains stable between syncs.
+	env := config.Get().Docker.ContainerEnv
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+nloop:
+	for _, k := range keys {
+		for _, e := range out {
+			if strings.HasPrefix(e, strings.ToUpper(k)+"=") {
+				continue nloop
+			}
+		}
+
+		out = append(out, fmt.Sprintf("%s=%s", strings.ToUpper(k), env[k]))
+	}
+
+	return out
+}
+
+// serverEnvironmentVariables returns the environment variables defined by Wings
+// and the server itself, without any of the node-wide container variables.
+func (s *Server) serverEnvironmentVariables() []string {
 	out := []string{
 		fmt.Sprintf("TZ=%s", DetermineServerTimezone(s.Config().EnvVars, config.Get().System.Timezone)),
 		fmt.Sprintf("STARTUP=%s", parseInvocation(s.Config().Invocation, s.Config().EnvVars, s.MemoryLimit(), s.Config().Allocations.DefaultMapping.Port, s.Config().Allocations.DefaultMapping.Ip)),
 		fmt.Sprintf("SERVER_MEMORY=%d", s.MemoryLimit()),
 		fmt.Sprintf("SERVER_IP=%s", s.Config().Allocations.DefaultMapping.Ip),
 		fmt.Sprintf("SERVER_PORT=%d", s.Config().Allocations.DefaultMapping.Port),
+		fmt.Sprintf("SERVER_ALLOCATIONS=%s", strings.Join(s.Config().Allocations.List(), ",")),
 	}
 
 eloop:
@@ -233,6 +290,22 @@ eloop:
 	return out
 }
 
+// redactedEnvironmentVariables returns the environment variables for the server with
+// the value of any variable sharing a name with a node-wide container variable
+// redacted, since those may contain secrets that should not be written to logs.
+func (s *Server) redactedEnvironmentVariables() []string {
+	out := s.GetEnvironmentVariables()
+	for k := range config.Get().Docker.ContainerEnv {
+		prefix := strings.ToUpper(k) + "="
+		for i, e := range out {
+			if strings.HasPrefix(e, prefix) {
+				out[i] = prefix + "{redacted}"
+			}
+		}
+	}
+	return out
+}
+
 func (s *Server) Log() *log.Entry {
 	return log.WithField("server", s.ID())
 }
@@ -252,13 +325,16 @@ func (s *Server) Sync() error {
 		return errors.WithStackIf(err)
 	}
 
+	before, _ := json.Marshal(s.Config())
 	if err := s.SyncWithConfiguration(cfg); err != nil {
 		return errors.WithStackIf(err)
 	}
+	s.PublishConfigurationApplied("server", ChangedFields(json.RawMessage(before), s.Config()))
 
 	// Update the disk space limits for the server whenever the configuration for
 	// it changes.
 	s.fs.SetDiskLimit(s.DiskSpace())
+	s.applyProjectQuota()
 
 	s.SyncWithEnvironment()
 
@@ -291,8 +367,18 @@ func (s *Server) SyncWithConfiguration(cfg remote.ServerConfigurationResponse) e
 	//goland:noinspection GoVetCopyLock
 	s.cfg = c
 
+	var invalid []remote.InvalidOutputLineMatcher
+	if cfg.ProcessConfiguration != nil {
+		invalid = cfg.ProcessConfiguration.ValidateDoneLines()
+		for _, l := range invalid {
+			s.Log().WithFields(log.Fields{"line": l.Line, "error": l.Error}).
+				Error("ignoring startup done line with an invalid regex, this line will never mark the server as running")
+		}
+	}
+
 	s.Lock()
 	s.procConfig = cfg.ProcessConfiguration
+	s.invalidDoneLines = invalid
 	s.Unlock()
 
 	return nil
@@ -326,11 +412,33 @@ func (s *Server) ProcessConfiguration() *remote.ProcessConfiguration {
 	return s.procConfig
 }
 
+// InvalidDoneLines returns the startup done lines for the server that were
+// ignored because they are marked as a regex that could not be compiled.
+func (s *Server) InvalidDoneLines() []remote.InvalidOutputLineMatcher {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.invalidDoneLines
+}
+
 // Filesystem returns an instance of the filesystem for this server.
 func (s *Server) Filesystem() *filesystem.Filesystem {
 	return s.fs
 }
 
+// CheckDataDirectory returns ErrServerDataMissing if the data directory for the
+// server no longer exists on the disk, for example because it was removed outside
+// of Wings or the underlying mount was not available when the node booted.
+func (s *Server) CheckDataDirectory() error {
+	if _, err := os.Lstat(s.fs.Path()); err != nil {
+		if os.IsNotExist(err) {
+			return ErrServerDataMissing
+		}
+		return errors.WrapIf(err, "server: failed to stat server root directory")
+	}
+	return nil
+}
+
 // EnsureDataDirectoryExists ensures that the data directory for the server
 // instance exists.
 func (s *Server) EnsureDataDirectoryExists() error {
@@ -343,6 +451,7 @@ func (s *Server) EnsureDataDirectoryExists() error {
 			if err := s.fs.Chown("/"); err != nil {
 				s.Log().WithField("error", err).Warn("server: failed to chown server data directory")
 			}
+			s.applyProjectQuota()
 		} else {
 			return errors.WrapIf(err, "server: failed to stat server root directory")
 		}
@@ -417,20 +526,22 @@ func (s *Server) IsRunning() bool {
 // instance on Wings. This includes the information needed by the Panel in order
 // to show resource utilization and the current state on this system.
 type APIResponse struct {
-	State         string        `json:"state"`
-	IsSuspended   bool          `json:"is_suspended"`
-	Utilization   ResourceUsage `json:"utilization"`
-	Configuration Configuration `json:"configuration"`
+	State          string        `json:"state"`
+	IsSuspended    bool          `json:"is_suspended"`
+	Utilization    ResourceUsage `json:"utilization"`
+	Configuration  Configuration `json:"configuration"`
+	FilesystemType string        `json:"filesystem_type"`
 }
 
 // ToAPIResponse returns the server struct as an API object that can be consumed
 // by callers.
 func (s *Server) ToAPIResponse() APIResponse {
 	return APIResponse{
-		State:         s.Environment.State(),
-		IsSuspended:   s.IsSuspended(),
-		Utilization:   s.Proc(),
-		Configuration: *s.Config(),
+		State:          s.Environment.State(),
+		IsSuspended:    s.IsSuspended(),
+		Utilization:    s.Proc(),
+		Configuration:  *s.Config(),
+		FilesystemType: s.Filesystem().Type(),
 	}
 }
 
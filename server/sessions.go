@@ -0,0 +1,73 @@
+package server
+
+import (
+	"sync/atomic"
+
+	"github.com/pelican-dev/wings/config"
+)
+
+// ActiveSessions is the number of connections and transfers that are currently
+// open for a server, each of which holds resources on the node.
+type ActiveSessions struct {
+	Websockets int `json:"websockets"`
+	Downloads  int `json:"downloads"`
+	Sftp       int `json:"sftp"`
+}
+
+// sessionCounter counts the number of sessions of a given type that are open,
+// optionally refusing new sessions once a limit is reached.
+type sessionCounter struct {
+	n atomic.Int64
+}
+
+// acquire increments the counter if doing so does not exceed the limit. A limit
+// of zero or less means there is no limit.
+func (c *sessionCounter) acquire(limit int) bool {
+	for {
+		n := c.n.Load()
+		if limit > 0 && n >= int64(limit) {
+			return false
+		}
+		if c.n.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+func (c *sessionCounter) release() {
+	c.n.Add(-1)
+}
+
+func (c *sessionCounter) count() int {
+	return int(c.n.Load())
+}
+
+// ActiveSessions returns the number of websockets, downloads and SFTP sessions
+// that are currently open for the server.
+func (s *Server) ActiveSessions() ActiveSessions {
+	return ActiveSessions{
+		Websockets: s.Websockets().Len(),
+		Downloads:  s.downloads.count(),
+		Sftp:       s.sftpSessions.count(),
+	}
+}
+
+// AcquireDownload tracks a new download for the server, returning false if the
+// server already has the maximum number of downloads in progress. The returned
+// function must be called once the download is complete.
+func (s *Server) AcquireDownload() (func(), bool) {
+	if !s.downloads.acquire(config.Get().System.MaxDownloadsPerServer) {
+		return nil, false
+	}
+	return s.downloads.release, true
+}
+
+// AcquireSftpSession tracks a new SFTP session for the server, returning false
+// if the server already has the maximum number of sessions open. The returned
+// function must be called once the session is closed.
+func (s *Server) AcquireSftpSession() (func(), bool) {
+	if !s.sftpSessions.acquire(config.Get().System.Sftp.MaxSessionsPerServer) {
+		return nil, false
+	}
+	return s.sftpSessions.release, true
+}
@@ -102,7 +102,7 @@ func (t *Transfer) SetStatus(s Status) {
 	// If we are cancelling, then we can't go back to processing.
 	t.status.Store(s)
 
-	t.Server.Events().Publish(server.TransferStatusEvent, s)
+	t.Server.PublishTransferStatus(string(s))
 }
 
 // SendMessage sends a message to the server's console.
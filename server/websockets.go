@@ -44,6 +44,14 @@ func (w *WebsocketBag) Remove(u uuid.UUID) {
 	w.mu.Unlock()
 }
 
+// Len returns the number of open websocket connections.
+func (w *WebsocketBag) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.conns)
+}
+
 // CancelAll cancels all the stored cancel functions which has the effect of
 // disconnecting every listening websocket for the server.
 func (w *WebsocketBag) CancelAll() {
@@ -114,7 +114,7 @@ func (h *Handler) Filewrite(request *sftp.Request) (io.WriterAt, error) {
 	// The specific permission required to perform this action. If the file exists on the
 	// system already it only needs to be an update, otherwise we'll check for a create.
 	permission := PermissionFileUpdate
-	_, sterr := h.fs.Stat(request.Filepath)
+	st, sterr := h.fs.Stat(request.Filepath)
 	if sterr != nil {
 		if !errors.Is(sterr, os.ErrNotExist) {
 			l.WithField("error", sterr).Error("error while getting file reader")
@@ -141,7 +141,13 @@ func (h *Handler) Filewrite(request *sftp.Request) (io.WriterAt, error) {
 		event = server.ActivitySftpCreate
 	}
 	h.events.MustLog(event, FileAction{Entity: request.Filepath})
-	return f, nil
+	// Track the size of the file as it is written so that uploads which would
+	// exceed the disk limit are rejected in the middle of the transfer.
+	var previous int64
+	if sterr == nil {
+		previous = st.Size()
+	}
+	return &quotaWriterAt{h.fs.NewQuotaWriterAt(f, previous)}, nil
 }
 
 // Filecmd hander for basic SFTP system calls related to files, but not anything to do with reading
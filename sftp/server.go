@@ -31,19 +31,23 @@ var validUsernameRegexp = regexp.MustCompile(`^(?i)(.+)\.([a-z0-9]{8})$`)
 
 //goland:noinspection GoNameStartsWithPackageName
 type SFTPServer struct {
-	manager  *server.Manager
-	BasePath string
-	ReadOnly bool
-	Listen   string
+	manager      *server.Manager
+	BasePath     string
+	ReadOnly     bool
+	Listen       string
+	AllowedCIDRs []string
+
+	allowed []*net.IPNet
 }
 
 func New(m *server.Manager) *SFTPServer {
 	cfg := config.Get().System
 	return &SFTPServer{
-		manager:  m,
-		BasePath: cfg.Data,
-		ReadOnly: cfg.Sftp.ReadOnly,
-		Listen:   cfg.Sftp.Address + ":" + strconv.Itoa(cfg.Sftp.Port),
+		manager:      m,
+		BasePath:     cfg.Data,
+		ReadOnly:     cfg.Sftp.ReadOnly,
+		Listen:       cfg.Sftp.Address + ":" + strconv.Itoa(cfg.Sftp.Port),
+		AllowedCIDRs: cfg.Sftp.AllowedCIDRs,
 	}
 }
 
@@ -94,6 +98,22 @@ func (c *SFTPServer) Run() error {
 	}
 	conf.AddHostKey(private)
 
+	for _, cidr := range c.AllowedCIDRs {
+		// Allow single IP addresses to be provided without a prefix length.
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return errors.Wrap(err, "sftp: invalid allowed_cidrs value")
+		}
+		c.allowed = append(c.allowed, n)
+	}
+
 	listener, err := net.Listen("tcp", c.Listen)
 	if err != nil {
 		return err
@@ -104,6 +124,11 @@ func (c *SFTPServer) Run() error {
 
 	for {
 		if conn, _ := listener.Accept(); conn != nil {
+			if !c.isAllowed(conn.RemoteAddr()) {
+				log.WithField("ip", conn.RemoteAddr().String()).Debug("sftp: rejected connection from address outside of allowed ranges")
+				_ = conn.Close()
+				continue
+			}
 			go func(conn net.Conn) {
 				defer conn.Close()
 				if err := c.AcceptInbound(conn, conf); err != nil {
@@ -114,6 +139,25 @@ func (c *SFTPServer) Run() error {
 	}
 }
 
+// isAllowed checks if a connection from the given address is allowed based on
+// the configured allow-list. If no ranges are configured all addresses are
+// allowed to connect.
+func (c *SFTPServer) isAllowed(addr net.Addr) bool {
+	if len(c.allowed) == 0 {
+		return true
+	}
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, n := range c.allowed {
+		if n.Contains(tcp.IP) {
+			return true
+		}
+	}
+	return false
+}
+
 // AcceptInbound handles an inbound connection to the instance and determines if we should
 // serve the request or not.
 func (c *SFTPServer) AcceptInbound(conn net.Conn, config *ssh.ServerConfig) error {
@@ -164,16 +208,25 @@ func (c *SFTPServer) AcceptInbound(conn net.Conn, config *ssh.ServerConfig) erro
 			continue
 		}
 
+		release, ok := srv.AcquireSftpSession()
+		if !ok {
+			srv.Log().WithField("user", sconn.User()).Warn("sftp: refusing session, server has reached the maximum number of open sessions")
+			_ = channel.Close()
+			continue
+		}
+
 		// Spin up a SFTP server instance for the authenticated user's server allowing
 		// them access to the underlying filesystem.
 		handler, err := NewHandler(sconn, srv)
 		if err != nil {
+			release()
 			return errors.WithStackIf(err)
 		}
 		rs := sftp.NewRequestServer(channel, handler.Handlers())
 		if err := rs.Serve(); err == io.EOF {
 			_ = rs.Close()
 		}
+		release()
 	}
 
 	return nil
@@ -3,6 +3,8 @@ package sftp
 import (
 	"io"
 	"os"
+
+	"github.com/pelican-dev/wings/server/filesystem"
 )
 
 const (
@@ -38,3 +40,18 @@ func (e fxErr) Error() string {
 		return "Failure"
 	}
 }
+
+// quotaWriterAt converts disk space errors returned by the filesystem while
+// writing a file into the SFTP quota exceeded error so that clients are given
+// a meaningful reason for the failed upload.
+type quotaWriterAt struct {
+	*filesystem.QuotaWriterAt
+}
+
+func (w *quotaWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	n, err := w.QuotaWriterAt.WriteAt(p, off)
+	if err != nil && filesystem.IsErrorCode(err, filesystem.ErrCodeDiskSpace) {
+		return n, ErrSSHQuotaExceeded
+	}
+	return n, err
+}
@@ -0,0 +1,31 @@
+package system
+
+import (
+	"github.com/docker/docker/api/types/system"
+)
+
+// CgroupWarnings returns a list of human-readable warnings describing any
+// limitations of the cgroup configuration on the node that would prevent the
+// resource limits assigned to servers from being enforced correctly.
+func CgroupWarnings(info system.Info) []string {
+	var warnings []string
+	if !info.MemoryLimit {
+		warnings = append(warnings, "memory cgroup controller is not available: server memory limits will not be enforced")
+	} else if !info.SwapLimit {
+		if info.CgroupVersion == "1" {
+			warnings = append(warnings, "swap accounting is disabled: server swap limits will not be enforced, add \"swapaccount=1\" to the kernel command line to enable it")
+		} else {
+			warnings = append(warnings, "swap accounting is disabled: server swap limits will not be enforced")
+		}
+	}
+	if !info.CPUCfsQuota {
+		warnings = append(warnings, "cpu cfs quota is not supported: server cpu limits will not be enforced")
+	}
+	if !info.PidsLimit {
+		warnings = append(warnings, "pids cgroup controller is not available: server process limits will not be enforced")
+	}
+	if info.CgroupVersion == "1" {
+		warnings = append(warnings, "the node is using cgroup v1 which is deprecated: consider switching to cgroup v2")
+	}
+	return warnings
+}
@@ -0,0 +1,36 @@
+package system
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/system"
+	. "github.com/franela/goblin"
+)
+
+func TestCgroupWarnings(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("CgroupWarnings", func() {
+		g.It("returns no warnings when all limits are supported", func() {
+			w := CgroupWarnings(system.Info{CgroupVersion: "2", MemoryLimit: true, SwapLimit: true, CPUCfsQuota: true, PidsLimit: true})
+			g.Assert(len(w)).Equal(0)
+		})
+
+		g.It("warns when swap accounting is disabled", func() {
+			w := CgroupWarnings(system.Info{CgroupVersion: "2", MemoryLimit: true, CPUCfsQuota: true, PidsLimit: true})
+			g.Assert(len(w)).Equal(1)
+			g.Assert(w[0]).Equal("swap accounting is disabled: server swap limits will not be enforced")
+		})
+
+		g.It("does not warn about swap when the memory controller is missing", func() {
+			w := CgroupWarnings(system.Info{CgroupVersion: "2", CPUCfsQuota: true, PidsLimit: true})
+			g.Assert(len(w)).Equal(1)
+			g.Assert(w[0]).Equal("memory cgroup controller is not available: server memory limits will not be enforced")
+		})
+
+		g.It("warns about cgroup v1", func() {
+			w := CgroupWarnings(system.Info{CgroupVersion: "1", MemoryLimit: true, SwapLimit: true, CPUCfsQuota: true, PidsLimit: true})
+			g.Assert(len(w)).Equal(1)
+		})
+	})
+}
@@ -2,6 +2,7 @@ package system
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,6 +21,9 @@ const (
 type SinkPool struct {
 	mu    sync.RWMutex
 	sinks []chan []byte
+	// dropped tracks the number of messages that have been discarded for each
+	// channel because it was not being drained fast enough.
+	dropped map[chan []byte]*atomic.Uint64
 }
 
 // NewSinkPool returns a new empty SinkPool. A sink pool generally lives with a
@@ -32,6 +36,10 @@ func NewSinkPool() *SinkPool {
 func (p *SinkPool) On(c chan []byte) {
 	p.mu.Lock()
 	p.sinks = append(p.sinks, c)
+	if p.dropped == nil {
+		p.dropped = make(map[chan []byte]*atomic.Uint64)
+	}
+	p.dropped[c] = &atomic.Uint64{}
 	p.mu.Unlock()
 }
 
@@ -54,6 +62,7 @@ func (p *SinkPool) Off(c chan []byte) {
 		sinks[len(sinks)-1] = nil
 		sinks = sinks[:len(sinks)-1]
 		p.sinks = sinks
+		delete(p.dropped, c)
 
 		// Avoid a panic if the sink channel is nil at this point.
 		if c != nil {
@@ -77,6 +86,19 @@ func (p *SinkPool) Destroy() {
 	}
 
 	p.sinks = nil
+	p.dropped = nil
+}
+
+// TakeDropped returns the number of messages that have been discarded for the
+// given channel since the last call to this function because the channel was not
+// being drained fast enough.
+func (p *SinkPool) TakeDropped(c chan []byte) uint64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if d, ok := p.dropped[c]; ok {
+		return d.Swap(0)
+	}
+	return 0
 }
 
 // Push sends a given message to each of the channels registered in the pool.
@@ -114,6 +136,9 @@ func (p *SinkPool) Push(data []byte) {
 				}
 				<-c
 				c <- data
+				if d, ok := p.dropped[c]; ok {
+					d.Add(1)
+				}
 			}
 		}(c)
 	}
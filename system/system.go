@@ -32,8 +32,9 @@ type DockerInformation struct {
 }
 
 type DockerCgroups struct {
-	Driver  string `json:"driver"`
-	Version string `json:"version"`
+	Driver   string   `json:"driver"`
+	Version  string   `json:"version"`
+	Warnings []string `json:"warnings"`
 }
 
 type DockerContainers struct {
@@ -59,6 +60,7 @@ type System struct {
 	KernelVersion string `json:"kernel_version"`
 	OS            string `json:"os"`
 	OSType        string `json:"os_type"`
+	OpenatMode    string `json:"openat_mode"`
 }
 
 type IpAddresses struct {
@@ -125,8 +127,9 @@ func GetSystemInformation() (*Information, error) {
 		Docker: DockerInformation{
 			Version: version.Version,
 			Cgroups: DockerCgroups{
-				Driver:  info.CgroupDriver,
-				Version: info.CgroupVersion,
+				Driver:   info.CgroupDriver,
+				Version:  info.CgroupVersion,
+				Warnings: CgroupWarnings(info),
 			},
 			Containers: DockerContainers{
 				Total:   info.Containers,
@@ -0,0 +1,106 @@
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// TailFile calls the callback with each line that is appended to the file at
+// the given path, starting from the current end of the file, until the context
+// is canceled or the callback returns an error. The file is checked for new
+// data at the given interval. If the file is replaced, such as when it is
+// rotated, the new file is opened and read from the start. If it is truncated
+// it is read again from the start.
+func TailFile(ctx context.Context, path string, interval time.Duration, callback func(line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	var partial []byte
+	// Reads every complete line that is currently available in the file. Any data
+	// following the last newline is kept until the rest of the line is written.
+	drain := func() error {
+		for {
+			line, err := r.ReadBytes('\n')
+			offset += int64(len(line))
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					partial = append(partial, line...)
+					return nil
+				}
+				return err
+			}
+			if len(partial) > 0 {
+				line = append(partial, line...)
+				partial = nil
+			}
+			if err := callback(bytes.TrimRight(line, "\r\n")); err != nil {
+				return err
+			}
+		}
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := drain(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+
+		st, err := os.Stat(path)
+		if err != nil {
+			// The file may briefly not exist while it is being rotated.
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		cur, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if !os.SameFile(st, cur) {
+			// Read anything written to the old file before it was replaced.
+			if err := drain(); err != nil {
+				return err
+			}
+			nf, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			_ = f.Close()
+			f = nf
+		} else if st.Size() >= offset {
+			continue
+		} else if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r.Reset(f)
+		offset = 0
+		if len(partial) > 0 {
+			if err := callback(partial); err != nil {
+				return err
+			}
+			partial = nil
+		}
+	}
+}
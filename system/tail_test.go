@@ -0,0 +1,101 @@
+package system
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+)
+
+func TestTailFile(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("TailFile", func() {
+		var (
+			p      string
+			mu     sync.Mutex
+			lines  []string
+			cancel context.CancelFunc
+			done   chan error
+		)
+
+		appendTo := func(s string) {
+			f, err := os.OpenFile(p, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o600)
+			g.Assert(err).IsNil()
+			_, err = f.WriteString(s)
+			g.Assert(err).IsNil()
+			g.Assert(f.Close()).IsNil()
+		}
+
+		waitFor := func(n int) []string {
+			for i := 0; i < 200; i++ {
+				mu.Lock()
+				l := len(lines)
+				mu.Unlock()
+				if l >= n {
+					break
+				}
+				time.Sleep(time.Millisecond * 5)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string{}, lines...)
+		}
+
+		g.BeforeEach(func() {
+			p = filepath.Join(t.TempDir(), "wings.log")
+			lines = nil
+			appendTo("existing line\n")
+
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			done = make(chan error, 1)
+			go func() {
+				done <- TailFile(ctx, p, time.Millisecond*5, func(line []byte) error {
+					mu.Lock()
+					defer mu.Unlock()
+					lines = append(lines, string(line))
+					return nil
+				})
+			}()
+			// Give the tail a moment to open the file and seek to the end.
+			time.Sleep(time.Millisecond * 20)
+		})
+
+		g.AfterEach(func() {
+			cancel()
+			g.Assert(<-done).IsNil()
+		})
+
+		g.It("returns lines appended to the file", func() {
+			appendTo("first\nsec")
+			appendTo("ond\n")
+
+			g.Assert(waitFor(2)).Equal([]string{"first", "second"})
+		})
+
+		g.It("reopens the file when it is rotated", func() {
+			appendTo("before\n")
+			g.Assert(waitFor(1)).Equal([]string{"before"})
+
+			g.Assert(os.Rename(p, p+".1")).IsNil()
+			appendTo("after\n")
+
+			g.Assert(waitFor(2)).Equal([]string{"before", "after"})
+		})
+
+		g.It("reads the file from the start when it is truncated", func() {
+			appendTo("before\n")
+			g.Assert(waitFor(1)).Equal([]string{"before"})
+
+			g.Assert(os.Truncate(p, 0)).IsNil()
+			appendTo("x\n")
+
+			g.Assert(waitFor(2)).Equal([]string{"before", "x"})
+		})
+	})
+}